@@ -1,5 +1,12 @@
 package gofeat
 
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
 // Aggregator computes a value from a sequence of inputs.
 type Aggregator interface {
 	Add(e Event)
@@ -9,6 +16,33 @@ type Aggregator interface {
 // AggregatorFactory creates new Aggregator instances.
 type AggregatorFactory = func() Aggregator
 
+// Merger is implemented by aggregators whose partial states can be combined,
+// e.g. states computed for different time buckets, shards or batches.
+// The result must not depend on the order of Add and Merge calls.
+type Merger interface {
+	// Merge folds the state of other into the receiver. other must be
+	// created by the same factory and is not modified.
+	Merge(other Aggregator) error
+}
+
+// StateMarshaler is implemented by aggregators that can serialize their
+// partial state, e.g. to combine results computed on another node.
+// UnmarshalState must be called on an aggregator created by the same
+// factory as the one that produced the data; it replaces the current state.
+type StateMarshaler interface {
+	MarshalState() ([]byte, error)
+	UnmarshalState(data []byte) error
+}
+
+// ErrIncompatibleAggregator is returned by Merge when the aggregators
+// were not created by the same kind of factory.
+var ErrIncompatibleAggregator = errors.New("gofeat: incompatible aggregator")
+
+// ErrStateUnsupported is returned by MarshalState and UnmarshalState of
+// wrappers such as Where when the wrapped aggregator does not implement
+// StateMarshaler.
+var ErrStateUnsupported = errors.New("gofeat: aggregator state not serializable")
+
 // Count counts the number of events.
 func Count() Aggregator { return &countAgg{} }
 
@@ -17,6 +51,25 @@ type countAgg struct{ n int }
 func (a *countAgg) Add(Event)   { a.n++ }
 func (a *countAgg) Result() any { return a.n }
 
+func (a *countAgg) Merge(other Aggregator) error {
+	o, ok := other.(*countAgg)
+	if !ok {
+		return mergeError(a, other)
+	}
+	a.n += o.n
+	return nil
+}
+
+func (a *countAgg) MarshalState() ([]byte, error) {
+	return binary.AppendVarint(nil, int64(a.n)), nil
+}
+
+func (a *countAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		a.n = int(d.varint())
+	})
+}
+
 // Sum computes the sum of float64 values.
 func Sum(field string) AggregatorFactory {
 	return func() Aggregator {
@@ -40,6 +93,25 @@ func (a *sumAgg) Add(e Event) {
 }
 func (a *sumAgg) Result() any { return a.sum }
 
+func (a *sumAgg) Merge(other Aggregator) error {
+	o, ok := other.(*sumAgg)
+	if !ok {
+		return mergeError(a, other)
+	}
+	a.sum += o.sum
+	return nil
+}
+
+func (a *sumAgg) MarshalState() ([]byte, error) {
+	return appendFloat64(nil, a.sum), nil
+}
+
+func (a *sumAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		a.sum = d.float64()
+	})
+}
+
 // Min computes the minimum float64 value.
 func Min(field string) AggregatorFactory {
 	return func() Aggregator {
@@ -75,6 +147,29 @@ func (a *minAgg) Result() any {
 	return a.min
 }
 
+func (a *minAgg) Merge(other Aggregator) error {
+	o, ok := other.(*minAgg)
+	if !ok {
+		return mergeError(a, other)
+	}
+	if o.valid && (!a.valid || o.min < a.min) {
+		a.min = o.min
+		a.valid = true
+	}
+	return nil
+}
+
+func (a *minAgg) MarshalState() ([]byte, error) {
+	return appendFloat64(appendBool(nil, a.valid), a.min), nil
+}
+
+func (a *minAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		a.valid = d.bool()
+		a.min = d.float64()
+	})
+}
+
 // Max computes the maximum float64 value.
 func Max(field string) AggregatorFactory {
 	return func() Aggregator {
@@ -110,6 +205,29 @@ func (a *maxAgg) Result() any {
 	return a.max
 }
 
+func (a *maxAgg) Merge(other Aggregator) error {
+	o, ok := other.(*maxAgg)
+	if !ok {
+		return mergeError(a, other)
+	}
+	if o.valid && (!a.valid || o.max > a.max) {
+		a.max = o.max
+		a.valid = true
+	}
+	return nil
+}
+
+func (a *maxAgg) MarshalState() ([]byte, error) {
+	return appendFloat64(appendBool(nil, a.valid), a.max), nil
+}
+
+func (a *maxAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		a.valid = d.bool()
+		a.max = d.float64()
+	})
+}
+
 // Last returns the last non-nil value.
 func Last(field string) AggregatorFactory {
 	return func() Aggregator {
@@ -119,6 +237,8 @@ func Last(field string) AggregatorFactory {
 
 type lastAgg struct {
 	last  any
+	at    time.Time // timestamp of the event last came from
+	set   bool
 	field string
 }
 
@@ -127,12 +247,44 @@ func (a *lastAgg) Add(e Event) {
 	if !ok {
 		return
 	}
+	if a.set && e.Timestamp.Before(a.at) {
+		return
+	}
 	a.last = v
+	a.at = e.Timestamp
+	a.set = true
 }
 
 func (a *lastAgg) Result() any { return a.last }
 
-// DistinctCount counts unique values.
+func (a *lastAgg) Merge(other Aggregator) error {
+	o, ok := other.(*lastAgg)
+	if !ok {
+		return mergeError(a, other)
+	}
+	if o.set && (!a.set || !o.at.Before(a.at)) {
+		a.last = o.last
+		a.at = o.at
+		a.set = true
+	}
+	return nil
+}
+
+func (a *lastAgg) MarshalState() ([]byte, error) {
+	b := appendTimestamp(appendBool(nil, a.set), a.at)
+	return appendValue(b, a.last)
+}
+
+func (a *lastAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		a.set = d.bool()
+		a.at = d.timestamp()
+		a.last = d.value()
+	})
+}
+
+// DistinctCount counts unique values. It keeps every value seen; use
+// ApproxDistinctCount to bound memory for high-cardinality fields.
 func DistinctCount(field string) AggregatorFactory {
 	return func() Aggregator {
 		return &distinctCount{field: field, seen: make(map[any]struct{})}
@@ -154,6 +306,27 @@ func (a *distinctCount) Add(e Event) {
 }
 func (a *distinctCount) Result() any { return len(a.seen) }
 
+func (a *distinctCount) Merge(other Aggregator) error {
+	o, ok := other.(*distinctCount)
+	if !ok {
+		return mergeError(a, other)
+	}
+	for v := range o.seen {
+		a.seen[v] = struct{}{}
+	}
+	return nil
+}
+
+func (a *distinctCount) MarshalState() ([]byte, error) {
+	return appendSet(nil, a.seen)
+}
+
+func (a *distinctCount) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		a.seen = d.set()
+	})
+}
+
 func toFloat64(v any) (float64, bool) {
 	switch n := v.(type) {
 	case float64:
@@ -169,3 +342,58 @@ func toFloat64(v any) (float64, bool) {
 	}
 	return 0, false
 }
+
+func mergeError(a, other Aggregator) error {
+	return fmt.Errorf("%w: cannot merge %T into %T", ErrIncompatibleAggregator, other, a)
+}
+
+// decodeState runs fn over data and fails if the input is malformed or
+// not fully consumed.
+func decodeState(data []byte, fn func(d *decoder)) error {
+	d := &decoder{b: data}
+	fn(d)
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.b) != 0 {
+		return fmt.Errorf("gofeat: %d trailing bytes in aggregator state", len(d.b))
+	}
+	return nil
+}
+
+func appendSet(b []byte, set map[any]struct{}) ([]byte, error) {
+	b = binary.AppendUvarint(b, uint64(len(set)))
+	var err error
+	for v := range set {
+		if b, err = appendValue(b, v); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func appendFloats(b []byte, values []float64) []byte {
+	b = binary.AppendUvarint(b, uint64(len(values)))
+	for _, f := range values {
+		b = appendFloat64(b, f)
+	}
+	return b
+}
+
+func (d *decoder) floats() []float64 {
+	n := d.length()
+	values := make([]float64, 0, n)
+	for range n {
+		values = append(values, d.float64())
+	}
+	return values
+}
+
+func (d *decoder) set() map[any]struct{} {
+	n := d.length()
+	set := make(map[any]struct{}, n)
+	for range n {
+		set[d.value()] = struct{}{}
+	}
+	return set
+}
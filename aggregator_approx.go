@@ -0,0 +1,515 @@
+package gofeat
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// Precision bounds of ApproxDistinctCount and ApproxUniqueRatio.
+const (
+	MinApproxPrecision     = 4
+	MaxApproxPrecision     = 16
+	DefaultApproxPrecision = 14
+)
+
+// ApproxDistinctCount estimates the number of unique values with a
+// HyperLogLog sketch of 2^precision registers, as int. The standard error
+// is 1.04/sqrt(2^precision): about 1.6% at precision 12 and 0.8% at 14.
+// The state takes at most 2^precision bytes whatever the number of values,
+// and less while few values were added. precision is clamped to
+// [MinApproxPrecision, MaxApproxPrecision].
+//
+// Values are hashed by type and value, like the map keys of DistinctCount:
+// int(1) and float64(1) are different values.
+func ApproxDistinctCount(field string, precision int) AggregatorFactory {
+	return func() Aggregator {
+		return &approxDistinctAgg{field: field, hll: newHyperLogLog(precision)}
+	}
+}
+
+type approxDistinctAgg struct {
+	field string
+	hll   hyperLogLog
+}
+
+func (a *approxDistinctAgg) Add(e Event) {
+	v, ok := e.Data[a.field]
+	if !ok {
+		return
+	}
+	a.hll.add(hashValue(v))
+}
+
+func (a *approxDistinctAgg) Result() any { return int(math.Round(a.hll.estimate())) }
+
+func (a *approxDistinctAgg) Merge(other Aggregator) error {
+	o, ok := other.(*approxDistinctAgg)
+	if !ok {
+		return mergeError(a, other)
+	}
+	return a.hll.merge(&o.hll)
+}
+
+func (a *approxDistinctAgg) MarshalState() ([]byte, error) {
+	return a.hll.appendState(nil), nil
+}
+
+func (a *approxDistinctAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, a.hll.decode)
+}
+
+// ApproxUniqueRatio estimates the ratio of unique values to total events
+// like UniqueRatio, counting unique values with ApproxDistinctCount. The
+// total is exact, so the ratio has the relative error of the estimate and
+// is capped at 1.0.
+func ApproxUniqueRatio(field string, precision int) AggregatorFactory {
+	return func() Aggregator {
+		return &approxUniqueRatioAgg{field: field, hll: newHyperLogLog(precision)}
+	}
+}
+
+type approxUniqueRatioAgg struct {
+	field string
+	hll   hyperLogLog
+	total int
+}
+
+func (a *approxUniqueRatioAgg) Add(e Event) {
+	v, ok := e.Data[a.field]
+	if !ok {
+		return
+	}
+	a.hll.add(hashValue(v))
+	a.total++
+}
+
+func (a *approxUniqueRatioAgg) Result() any {
+	if a.total == 0 {
+		return 0.0
+	}
+	return min(math.Round(a.hll.estimate())/float64(a.total), 1.0)
+}
+
+func (a *approxUniqueRatioAgg) Merge(other Aggregator) error {
+	o, ok := other.(*approxUniqueRatioAgg)
+	if !ok {
+		return mergeError(a, other)
+	}
+	if err := a.hll.merge(&o.hll); err != nil {
+		return err
+	}
+	a.total += o.total
+	return nil
+}
+
+func (a *approxUniqueRatioAgg) MarshalState() ([]byte, error) {
+	return a.hll.appendState(binary.AppendVarint(nil, int64(a.total))), nil
+}
+
+func (a *approxUniqueRatioAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		a.total = int(d.varint())
+		a.hll.decode(d)
+	})
+}
+
+// hyperLogLog is a HyperLogLog sketch (Flajolet et al., 2007). Registers are
+// kept in a map until more than 1/16 of them are set, so that sketches of
+// entities with few values stay small, then in a slice of 2^p bytes.
+type hyperLogLog struct {
+	p      uint8
+	sparse map[uint16]uint8
+	dense  []uint8
+}
+
+func newHyperLogLog(precision int) hyperLogLog {
+	return hyperLogLog{p: uint8(min(max(precision, MinApproxPrecision), MaxApproxPrecision))}
+}
+
+func (h *hyperLogLog) registers() int { return 1 << h.p }
+
+// add adds a 64-bit hash: the first p bits select a register, which keeps
+// the highest rank (position of the first 1 bit) seen in the others.
+func (h *hyperLogLog) add(x uint64) {
+	idx := uint16(x >> (64 - h.p))
+	rank := uint8(bits.LeadingZeros64(x<<h.p|1<<(h.p-1))) + 1 //nolint:gosec // at most 65-p
+	h.set(idx, rank)
+}
+
+func (h *hyperLogLog) set(idx uint16, rank uint8) {
+	if h.dense != nil {
+		h.dense[idx] = max(h.dense[idx], rank)
+		return
+	}
+	if h.sparse == nil {
+		h.sparse = make(map[uint16]uint8)
+	}
+	if rank > h.sparse[idx] {
+		h.sparse[idx] = rank
+	}
+	if len(h.sparse) > h.registers()/16 {
+		h.densify()
+	}
+}
+
+func (h *hyperLogLog) densify() {
+	h.dense = make([]uint8, h.registers())
+	for idx, rank := range h.sparse {
+		h.dense[idx] = rank
+	}
+	h.sparse = nil
+}
+
+func (h *hyperLogLog) merge(o *hyperLogLog) error {
+	if o.p != h.p {
+		return fmt.Errorf("%w: cannot merge HyperLogLog precision %d into %d", ErrIncompatibleAggregator, o.p, h.p)
+	}
+	if o.dense != nil {
+		if h.dense == nil {
+			h.densify()
+		}
+		for idx, rank := range o.dense {
+			h.dense[idx] = max(h.dense[idx], rank)
+		}
+		return nil
+	}
+	for idx, rank := range o.sparse {
+		h.set(idx, rank)
+	}
+	return nil
+}
+
+func (h *hyperLogLog) estimate() float64 {
+	m := float64(h.registers())
+	var sum, zeros float64
+	if h.dense != nil {
+		for _, rank := range h.dense {
+			sum += math.Ldexp(1, -int(rank))
+			if rank == 0 {
+				zeros++
+			}
+		}
+	} else {
+		zeros = m - float64(len(h.sparse))
+		sum = zeros
+		for _, rank := range h.sparse {
+			sum += math.Ldexp(1, -int(rank))
+		}
+	}
+
+	var alpha float64
+	switch h.p {
+	case 4:
+		alpha = 0.673
+	case 5:
+		alpha = 0.697
+	case 6:
+		alpha = 0.709
+	default:
+		alpha = 0.7213 / (1 + 1.079/m)
+	}
+	e := alpha * m * m / sum
+	// Linear counting is more accurate for small cardinalities; with 64-bit
+	// hashes no large range correction is needed.
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/zeros)
+	}
+	return e
+}
+
+// appendState encodes the precision, then either the dense registers or
+// the sparse ones sorted by index.
+func (h *hyperLogLog) appendState(b []byte) []byte {
+	b = append(b, h.p)
+	if h.dense != nil {
+		return append(appendBool(b, true), h.dense...)
+	}
+	b = binary.AppendUvarint(appendBool(b, false), uint64(len(h.sparse)))
+	for _, idx := range slices.Sorted(maps.Keys(h.sparse)) {
+		b = binary.AppendUvarint(b, uint64(idx))
+		b = append(b, h.sparse[idx])
+	}
+	return b
+}
+
+func (h *hyperLogLog) decode(d *decoder) {
+	if p := d.byte(); d.err == nil && p != h.p {
+		d.fail(fmt.Errorf("%w: HyperLogLog state has precision %d, want %d", ErrIncompatibleAggregator, p, h.p))
+		return
+	}
+	h.sparse, h.dense = nil, nil
+	if d.bool() {
+		if p := d.bytes(uint64(h.registers())); p != nil {
+			h.dense = slices.Clone(p)
+		}
+		return
+	}
+	n := d.length()
+	if n > 0 {
+		h.sparse = make(map[uint16]uint8, n)
+	}
+	for range n {
+		idx := d.uvarint()
+		rank := d.byte()
+		if idx >= uint64(h.registers()) {
+			d.fail(fmt.Errorf("gofeat: HyperLogLog register %d out of range", idx))
+			return
+		}
+		h.sparse[uint16(idx)] = rank
+	}
+}
+
+// hashValue hashes the binary encoding of v, which includes its type, with
+// FNV-1a. Values the encoding does not support are hashed by type and
+// fmt representation.
+func hashValue(v any) uint64 {
+	var buf [32]byte
+	b, err := appendValue(buf[:0], v)
+	if err != nil {
+		b = fmt.Appendf(buf[:0], "%T:%v", v, v)
+	}
+
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	x := uint64(offset64)
+	for _, c := range b {
+		x ^= uint64(c)
+		x *= prime64
+	}
+
+	// FNV spreads short inputs poorly over the high bits, which select the
+	// register: finish with the MurmurHash3 finalizer.
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// ApproxPercentile estimates several percentiles of a numeric field in one
+// pass with a t-digest sketch, as []float64 in the order of ps. Each p
+// should be between 0.0 and 1.0.
+//
+// The sketch keeps at most a few hundred centroids whatever the number of
+// values, so it suits long windows where Percentile would store and sort
+// every value. Estimates are interpolated between centroids; the error in
+// rank is typically below 0.5% around the median and much lower towards
+// the tails, where centroids are smaller. Minimum and maximum are exact.
+func ApproxPercentile(field string, ps ...float64) AggregatorFactory {
+	ps = slices.Clone(ps)
+	return func() Aggregator {
+		return &approxPercentileAgg{field: field, ps: ps}
+	}
+}
+
+type approxPercentileAgg struct {
+	field  string
+	ps     []float64
+	digest tDigest
+}
+
+func (a *approxPercentileAgg) Add(e Event) {
+	v, ok := e.Data[a.field]
+	if !ok {
+		return
+	}
+	if f, okF := toFloat64(v); okF {
+		a.digest.add(f)
+	}
+}
+
+func (a *approxPercentileAgg) Result() any {
+	centroids := a.digest.merged()
+	values := make([]float64, len(a.ps))
+	for i, p := range a.ps {
+		values[i] = a.digest.quantile(centroids, p)
+	}
+	return values
+}
+
+func (a *approxPercentileAgg) Merge(other Aggregator) error {
+	o, ok := other.(*approxPercentileAgg)
+	if !ok {
+		return mergeError(a, other)
+	}
+	a.digest.merge(&o.digest)
+	return nil
+}
+
+func (a *approxPercentileAgg) MarshalState() ([]byte, error) {
+	return a.digest.appendState(nil), nil
+}
+
+func (a *approxPercentileAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, a.digest.decode)
+}
+
+// tDigestCompression bounds the number of centroids of a tDigest to about
+// tDigestCompression/2 after compression.
+const tDigestCompression = 100
+
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// tDigest is a merging t-digest (Dunning and Ertl, 2019) with the k1 scale
+// function. Values are buffered and merged into the centroids when the
+// buffer is full, or on a copy when a result is needed, so that Result and
+// Merge do not modify the state they read.
+type tDigest struct {
+	centroids []centroid // sorted by mean
+	buffer    []float64
+	count     float64
+	min, max  float64
+}
+
+func (t *tDigest) add(f float64) {
+	if t.count == 0 || f < t.min {
+		t.min = f
+	}
+	if t.count == 0 || f > t.max {
+		t.max = f
+	}
+	t.count++
+	t.buffer = append(t.buffer, f)
+	if len(t.buffer) >= 5*tDigestCompression {
+		t.centroids = t.merged()
+		t.buffer = t.buffer[:0]
+	}
+}
+
+func (t *tDigest) merge(o *tDigest) {
+	if o.count == 0 {
+		return
+	}
+	if t.count == 0 || o.min < t.min {
+		t.min = o.min
+	}
+	if t.count == 0 || o.max > t.max {
+		t.max = o.max
+	}
+	t.count += o.count
+	t.centroids = compressCentroids(slices.Concat(t.centroids, bufferCentroids(t.buffer), o.centroids, bufferCentroids(o.buffer)))
+	t.buffer = t.buffer[:0]
+}
+
+// merged returns the centroids with the buffer merged in.
+func (t *tDigest) merged() []centroid {
+	if len(t.buffer) == 0 {
+		return t.centroids
+	}
+	return compressCentroids(slices.Concat(t.centroids, bufferCentroids(t.buffer)))
+}
+
+func bufferCentroids(buffer []float64) []centroid {
+	centroids := make([]centroid, len(buffer))
+	for i, f := range buffer {
+		centroids[i] = centroid{mean: f, weight: 1}
+	}
+	return centroids
+}
+
+// compressCentroids sorts centroids and merges neighbours as long as the
+// merged centroid spans at most one unit of the scale function
+// k(q) = δ/(2π)·asin(2q-1), which keeps centroids small near the tails.
+func compressCentroids(centroids []centroid) []centroid {
+	if len(centroids) == 0 {
+		return nil
+	}
+	slices.SortFunc(centroids, func(a, b centroid) int { return cmp.Compare(a.mean, b.mean) })
+
+	var total float64
+	for _, c := range centroids {
+		total += c.weight
+	}
+
+	out := centroids[:1]
+	var before float64 // weight of the centroids before the current one
+	limit := total * tDigestQ(tDigestK(0)+1)
+	for _, c := range centroids[1:] {
+		cur := &out[len(out)-1]
+		if before+cur.weight+c.weight <= limit {
+			cur.weight += c.weight
+			cur.mean += (c.mean - cur.mean) * c.weight / cur.weight
+			continue
+		}
+		before += cur.weight
+		limit = total * tDigestQ(tDigestK(before/total)+1)
+		out = append(out, c)
+	}
+	return out
+}
+
+func tDigestK(q float64) float64 {
+	return tDigestCompression / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+func tDigestQ(k float64) float64 {
+	angle := min(k*2*math.Pi/tDigestCompression, math.Pi/2)
+	return (math.Sin(angle) + 1) / 2
+}
+
+// quantile interpolates between the centroid means, each placed at the
+// middle of its weight, and the exact minimum and maximum.
+func (t *tDigest) quantile(centroids []centroid, q float64) float64 {
+	switch {
+	case t.count == 0:
+		return 0
+	case q <= 0 || len(centroids) == 0:
+		return t.min
+	case q >= 1:
+		return t.max
+	}
+
+	index := q * t.count
+	first := centroids[0]
+	if index < first.weight/2 {
+		return t.min + (first.mean-t.min)*index/(first.weight/2)
+	}
+	var before float64
+	for i := range len(centroids) - 1 {
+		c, next := centroids[i], centroids[i+1]
+		lo := before + c.weight/2
+		hi := before + c.weight + next.weight/2
+		if index < hi {
+			return c.mean + (next.mean-c.mean)*(index-lo)/(hi-lo)
+		}
+		before += c.weight
+	}
+	last := centroids[len(centroids)-1]
+	lo := t.count - last.weight/2
+	return last.mean + (t.max-last.mean)*(index-lo)/(last.weight/2)
+}
+
+// appendState encodes the count, minimum and maximum, then the centroids
+// with the buffer merged in.
+func (t *tDigest) appendState(b []byte) []byte {
+	centroids := t.merged()
+	b = appendFloat64(appendFloat64(appendFloat64(b, t.count), t.min), t.max)
+	b = binary.AppendUvarint(b, uint64(len(centroids)))
+	for _, c := range centroids {
+		b = appendFloat64(appendFloat64(b, c.mean), c.weight)
+	}
+	return b
+}
+
+func (t *tDigest) decode(d *decoder) {
+	t.count = d.float64()
+	t.min = d.float64()
+	t.max = d.float64()
+	n := d.length()
+	t.centroids = make([]centroid, 0, n)
+	t.buffer = nil
+	for range n {
+		t.centroids = append(t.centroids, centroid{mean: d.float64(), weight: d.float64()})
+	}
+}
@@ -0,0 +1,271 @@
+package gofeat_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+func TestApproxDistinctCount(t *testing.T) {
+	tests := []struct {
+		name      string
+		precision int
+		distinct  int
+		repeat    int
+	}{
+		{name: "empty", precision: 14},
+		{name: "few values", precision: 14, distinct: 10, repeat: 3},
+		{name: "sparse to dense", precision: 10, distinct: 500, repeat: 2},
+		{name: "large", precision: 14, distinct: 100000, repeat: 1},
+		{name: "low precision", precision: 8, distinct: 10000, repeat: 1},
+		{name: "precision clamped", precision: 1, distinct: 1000, repeat: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg := gofeat.ApproxDistinctCount("ip", tt.precision)()
+			for range tt.repeat {
+				for i := range tt.distinct {
+					agg.Add(gofeat.Event{Data: map[string]any{"ip": "10.0." + strconv.Itoa(i)}})
+				}
+			}
+			agg.Add(gofeat.Event{Data: map[string]any{"other": 1}})
+
+			got, ok := agg.Result().(int)
+			if !ok {
+				t.Fatalf("result %T, want int", agg.Result())
+			}
+			// 4 standard errors, with the precision clamped to at least 4
+			p := max(tt.precision, gofeat.MinApproxPrecision)
+			tolerance := 4 * 1.04 / math.Sqrt(float64(int(1)<<p)) * float64(tt.distinct)
+			if math.Abs(float64(got-tt.distinct)) > math.Max(tolerance, 1) {
+				t.Errorf("estimate %d, want %d ± %.0f", got, tt.distinct, tolerance)
+			}
+		})
+	}
+}
+
+func TestApproxDistinctCount_Types(t *testing.T) {
+	agg := gofeat.ApproxDistinctCount("v", gofeat.DefaultApproxPrecision)()
+	for _, v := range []any{1, 1.0, "1", int64(1), true, 1, "1"} {
+		agg.Add(gofeat.Event{Data: map[string]any{"v": v}})
+	}
+	if got := agg.Result(); got != 5 {
+		t.Errorf("got %v, want 5 distinct typed values", got)
+	}
+}
+
+func TestApproxUniqueRatio(t *testing.T) {
+	agg := gofeat.ApproxUniqueRatio("card", gofeat.DefaultApproxPrecision)()
+	if got := agg.Result(); got != 0.0 {
+		t.Errorf("empty ratio %v, want 0", got)
+	}
+	for i := range 1000 {
+		agg.Add(gofeat.Event{Data: map[string]any{"card": i % 250}})
+	}
+	got, _ := agg.Result().(float64)
+	if math.Abs(got-0.25) > 0.01 {
+		t.Errorf("ratio %v, want about 0.25", got)
+	}
+
+	unique := gofeat.ApproxUniqueRatio("card", 4)()
+	for i := range 3 {
+		unique.Add(gofeat.Event{Data: map[string]any{"card": i}})
+	}
+	if got := unique.Result(); got != 1.0 {
+		t.Errorf("ratio %v, want 1", got)
+	}
+}
+
+func TestApproxDistinctCount_MergePrecision(t *testing.T) {
+	a := gofeat.ApproxDistinctCount("ip", 10)()
+	b := gofeat.ApproxDistinctCount("ip", 12)()
+	if err := a.(gofeat.Merger).Merge(b); !errors.Is(err, gofeat.ErrIncompatibleAggregator) {
+		t.Errorf("expected ErrIncompatibleAggregator, got %v", err)
+	}
+
+	b.Add(gofeat.Event{Data: map[string]any{"ip": "10.0.0.1"}})
+	data, err := b.(gofeat.StateMarshaler).MarshalState()
+	if err != nil {
+		t.Fatalf("MarshalState failed: %v", err)
+	}
+	if err := a.(gofeat.StateMarshaler).UnmarshalState(data); !errors.Is(err, gofeat.ErrIncompatibleAggregator) {
+		t.Errorf("expected ErrIncompatibleAggregator, got %v", err)
+	}
+}
+
+func TestApproxDistinctCount_MergeDense(t *testing.T) {
+	whole := gofeat.ApproxDistinctCount("ip", 8)()
+	sparse := gofeat.ApproxDistinctCount("ip", 8)()
+	dense := gofeat.ApproxDistinctCount("ip", 8)()
+	for i := range 1000 {
+		e := gofeat.Event{Data: map[string]any{"ip": i}}
+		whole.Add(e)
+		if i < 5 {
+			sparse.Add(e)
+		} else {
+			dense.Add(e)
+		}
+	}
+
+	if err := sparse.(gofeat.Merger).Merge(dense); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if sparse.Result() != whole.Result() {
+		t.Errorf("merged %v, want %v", sparse.Result(), whole.Result())
+	}
+}
+
+func TestApproxDistinctCount_Store(t *testing.T) {
+	cfg, err := gofeat.LoadConfig(strings.NewReader(`{"features": [
+		{"name": "ips", "aggregate": {"kind": "approx_distinct_count", "field": "ip"}},
+		{"name": "ip_ratio", "aggregate": {"kind": "approx_unique_ratio", "field": "ip", "precision": 12},
+		 "window": {"kind": "sliding", "size": "1h"}},
+		{"name": "exact_ratio", "aggregate": {"kind": "unique_ratio", "field": "ip"}, "window": {"kind": "sliding", "size": "1h"}}
+	]}`))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	cfg.BucketSize = time.Minute
+	store, err := gofeat.New(cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := range 100 {
+		e := gofeat.Event{Timestamp: base.Add(time.Duration(i) * time.Minute), Data: map[string]any{"ip": strconv.Itoa(i % 50)}}
+		if err := store.Push(ctx, "u1", e); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+	}
+
+	result, err := store.GetAt(ctx, "u1", base.Add(99*time.Minute))
+	if err != nil {
+		t.Fatalf("GetAt failed: %v", err)
+	}
+	if got := result.IntOr("ips", 0); got != 50 {
+		t.Errorf("ips %d, want 50", got)
+	}
+	exact := result.FloatOr("exact_ratio", 0)
+	if got := result.FloatOr("ip_ratio", 0); math.Abs(got-exact) > 0.03 {
+		t.Errorf("ip_ratio %v, want about %v", got, exact)
+	}
+}
+
+func TestApproxPercentile(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	ps := []float64{0, 0.01, 0.25, 0.5, 0.9, 0.99, 0.999, 1}
+
+	tests := []struct {
+		name string
+		gen  func() float64
+	}{
+		{name: "uniform", gen: func() float64 { return rng.Float64() * 1000 }},
+		{name: "exponential", gen: rng.ExpFloat64},
+		{name: "normal", gen: func() float64 { return 100 + 15*rng.NormFloat64() }},
+		{name: "discrete", gen: func() float64 { return float64(rng.IntN(5)) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Build from bucket-sized parts, as incremental aggregation does
+			const n = 100000
+			agg := gofeat.ApproxPercentile("amount", ps...)()
+			values := make([]float64, 0, n)
+			for range n / 1000 {
+				part := gofeat.ApproxPercentile("amount", ps...)()
+				for range 1000 {
+					v := tt.gen()
+					values = append(values, v)
+					part.Add(gofeat.Event{Data: map[string]any{"amount": v}})
+				}
+				if err := agg.(gofeat.Merger).Merge(part); err != nil {
+					t.Fatalf("Merge failed: %v", err)
+				}
+			}
+			slices.Sort(values)
+
+			got, ok := agg.Result().([]float64)
+			if !ok || len(got) != len(ps) {
+				t.Fatalf("result %v, want %d percentiles", agg.Result(), len(ps))
+			}
+			for i, p := range ps {
+				// the estimate must fall between the values at ranks p ± 0.5%
+				lo := values[max(int(math.Floor((p-0.005)*n)), 0)]
+				hi := values[min(int(math.Ceil((p+0.005)*n)), n-1)]
+				if got[i] < lo || got[i] > hi {
+					t.Errorf("p%v: got %v, want between %v and %v", p*100, got[i], lo, hi)
+				}
+			}
+			if got[0] != values[0] || got[len(got)-1] != values[n-1] {
+				t.Errorf("min and max %v, %v, want %v, %v", got[0], got[len(got)-1], values[0], values[n-1])
+			}
+		})
+	}
+}
+
+func TestApproxPercentile_Small(t *testing.T) {
+	agg := gofeat.ApproxPercentile("amount", 0, 0.5, 1)()
+	if got := agg.Result(); !slices.Equal(got.([]float64), []float64{0, 0, 0}) {
+		t.Errorf("empty result %v, want zeros", got)
+	}
+
+	for _, v := range []float64{30, 10, 20} {
+		agg.Add(gofeat.Event{Data: map[string]any{"amount": v}})
+	}
+	agg.Add(gofeat.Event{Data: map[string]any{"amount": "n/a"}})
+	if got := agg.Result(); !slices.Equal(got.([]float64), []float64{10, 20, 30}) {
+		t.Errorf("got %v, want [10 20 30]", got)
+	}
+}
+
+func TestApproxPercentile_Store(t *testing.T) {
+	cfg, err := gofeat.LoadConfig(strings.NewReader(`{"bucket_size": "1m", "features": [
+		{"name": "amount_pcts", "aggregate": {"kind": "approx_percentile", "field": "amount", "ps": [0.5, 0.95]},
+		 "window": {"kind": "sliding", "size": "1h"}},
+		{"name": "amount_p50", "aggregate": {"kind": "percentile", "field": "amount", "p": 0.5, "interpolation": "linear"},
+		 "window": {"kind": "sliding", "size": "1h"}}
+	]}`))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	store, err := gofeat.New(cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := range 120 {
+		e := gofeat.Event{Timestamp: base.Add(time.Duration(i) * time.Minute), Data: map[string]any{"amount": float64(i)}}
+		if err := store.Push(ctx, "u1", e); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+	}
+
+	result, err := store.GetAt(ctx, "u1", base.Add(119*time.Minute))
+	if err != nil {
+		t.Fatalf("GetAt failed: %v", err)
+	}
+	pcts, err := result.Floats("amount_pcts")
+	if err != nil {
+		t.Fatalf("Floats failed: %v", err)
+	}
+	p50 := result.FloatOr("amount_p50", 0)
+	if len(pcts) != 2 || math.Abs(pcts[0]-p50) > 1 || pcts[1] < 114 || pcts[1] > 117 {
+		t.Errorf("percentiles %v, exact p50 %v", pcts, p50)
+	}
+	if _, err := result.Floats("amount_p50"); err == nil {
+		t.Error("expected error for a scalar feature")
+	}
+}
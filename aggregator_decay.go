@@ -0,0 +1,146 @@
+package gofeat
+
+import (
+	"math"
+	"time"
+)
+
+// TimeAware is implemented by aggregators whose result depends on the time
+// it is evaluated at, such as decayed aggregates. The Store calls ResultAt
+// with the query time of GetAt, GetEntityAt or BuildTrainingSet instead of
+// Result; Result evaluates at a time chosen by the aggregator.
+type TimeAware interface {
+	ResultAt(t time.Time) any
+}
+
+// resultAt returns the result of agg evaluated at t.
+func resultAt(agg Aggregator, t time.Time) any {
+	if ta, ok := agg.(TimeAware); ok {
+		return ta.ResultAt(t)
+	}
+	return agg.Result()
+}
+
+// EWMA computes an exponentially weighted moving average of a numeric
+// field: each value is weighted by exp(-λ·age) with λ = ln 2 / halfLife, so
+// a value halfLife older than another counts half as much. Unlike Mean over
+// a sliding window, values fade out smoothly instead of dropping out of the
+// window at once. A non-positive halfLife disables decay, giving Mean.
+//
+// The average does not depend on the evaluation time, since all weights
+// decay at the same rate; the Store still evaluates it at the query time.
+func EWMA(field string, halfLife time.Duration) AggregatorFactory {
+	return func() Aggregator {
+		return &decayAgg{field: field, lambda: decayRate(halfLife), average: true}
+	}
+}
+
+// DecayedCount counts events weighted by exp(-λ·age) with
+// λ = ln 2 / halfLife, where age is measured from the query time: an event
+// counts 1 when it happens and 0.5 after halfLife. The result is a float64.
+// Result without a query time measures ages from the latest event. A
+// non-positive halfLife disables decay, giving Count as float64.
+func DecayedCount(halfLife time.Duration) AggregatorFactory {
+	return func() Aggregator {
+		return &decayAgg{lambda: decayRate(halfLife)}
+	}
+}
+
+func decayRate(halfLife time.Duration) float64 {
+	if halfLife <= 0 {
+		return 0
+	}
+	return math.Ln2 / halfLife.Seconds()
+}
+
+// decayAgg keeps the weights relative to the latest event, ref, so that
+// they stay close to 1 whatever the timestamps: weight is the sum of the
+// decayed weights at ref and sum the sum of the weighted values.
+type decayAgg struct {
+	field   string
+	lambda  float64
+	average bool // EWMA rather than DecayedCount
+
+	ref    time.Time
+	weight float64
+	sum    float64
+}
+
+// decay returns the factor weights at from are multiplied by at to.
+func (a *decayAgg) decay(from, to time.Time) float64 {
+	return math.Exp(-a.lambda * to.Sub(from).Seconds())
+}
+
+func (a *decayAgg) Add(e Event) {
+	x := 0.0
+	if a.average {
+		v, ok := e.Data[a.field]
+		if !ok {
+			return
+		}
+		if x, ok = toFloat64(v); !ok {
+			return
+		}
+	}
+	a.add(e.Timestamp, 1, x)
+}
+
+// add adds weight w at time t, and w*x to the sum.
+func (a *decayAgg) add(t time.Time, w, x float64) {
+	if a.weight == 0 || t.After(a.ref) {
+		if a.weight != 0 {
+			f := a.decay(a.ref, t)
+			a.weight *= f
+			a.sum *= f
+		}
+		a.ref = t
+	} else {
+		w *= a.decay(t, a.ref)
+	}
+	a.weight += w
+	a.sum += w * x
+}
+
+func (a *decayAgg) Result() any { return a.ResultAt(a.ref) }
+
+func (a *decayAgg) ResultAt(t time.Time) any {
+	if a.average {
+		if a.weight == 0 {
+			return 0.0
+		}
+		return a.sum / a.weight
+	}
+	if a.weight == 0 || !t.After(a.ref) {
+		return a.weight
+	}
+	return a.weight * a.decay(a.ref, t)
+}
+
+func (a *decayAgg) Merge(other Aggregator) error {
+	o, ok := other.(*decayAgg)
+	if !ok || o.average != a.average {
+		return mergeError(a, other)
+	}
+	if o.weight == 0 {
+		return nil
+	}
+	mean := 0.0
+	if a.average {
+		mean = o.sum / o.weight
+	}
+	a.add(o.ref, o.weight, mean)
+	return nil
+}
+
+func (a *decayAgg) MarshalState() ([]byte, error) {
+	b := appendTimestamp(nil, a.ref)
+	return appendFloat64(appendFloat64(b, a.weight), a.sum), nil
+}
+
+func (a *decayAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		a.ref = d.timestamp()
+		a.weight = d.float64()
+		a.sum = d.float64()
+	})
+}
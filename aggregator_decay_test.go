@@ -0,0 +1,110 @@
+package gofeat_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+func TestDecayAggregators(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []gofeat.Event{
+		{Timestamp: base.Add(time.Hour), Data: map[string]any{"amount": 20.0}},
+		{Timestamp: base, Data: map[string]any{"amount": 10}}, // out of order
+		{Timestamp: base.Add(time.Hour), Data: map[string]any{"other": 1.0}},
+	}
+
+	tests := []struct {
+		name    string
+		factory gofeat.AggregatorFactory
+		at      time.Time
+		want    float64
+	}{
+		{"ewma", gofeat.EWMA("amount", time.Hour), base.Add(time.Hour), (0.5*10 + 20) / 1.5},
+		{"ewma later", gofeat.EWMA("amount", time.Hour), base.Add(10 * time.Hour), (0.5*10 + 20) / 1.5},
+		{"ewma no decay", gofeat.EWMA("amount", 0), base.Add(time.Hour), 15},
+		{"decayed count", gofeat.DecayedCount(time.Hour), base.Add(time.Hour), 0.5 + 2},
+		{"decayed count later", gofeat.DecayedCount(time.Hour), base.Add(3 * time.Hour), (0.5 + 2) / 4},
+		{"decayed count no decay", gofeat.DecayedCount(-time.Hour), base.Add(3 * time.Hour), 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg := tt.factory()
+			if got := agg.(gofeat.TimeAware).ResultAt(tt.at).(float64); got != 0 {
+				t.Errorf("empty result %v, want 0", got)
+			}
+			for _, e := range events {
+				agg.Add(e)
+			}
+			got, ok := agg.(gofeat.TimeAware).ResultAt(tt.at).(float64)
+			if !ok || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecayedCount_Result(t *testing.T) {
+	// Without a query time, ages are measured from the latest event
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	agg := gofeat.DecayedCount(time.Minute)()
+	agg.Add(gofeat.Event{Timestamp: base})
+	agg.Add(gofeat.Event{Timestamp: base.Add(2 * time.Minute)})
+	if got := agg.Result(); got != 1.25 {
+		t.Errorf("got %v, want 1.25", got)
+	}
+}
+
+func TestDecayAggregators_Store(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	features := []gofeat.Feature{
+		{Name: "ewma", Aggregate: gofeat.EWMA("amount", 30*time.Minute)},
+		{Name: "decayed", Aggregate: gofeat.DecayedCount(time.Hour), Window: gofeat.Sliding(24 * time.Hour)},
+		{Name: "decayed_large", Aggregate: gofeat.DecayedCount(time.Hour), Filter: gofeat.FieldGreaterThan("amount", 50)},
+	}
+
+	// The incremental path must give the same results as a full replay
+	results := make([]map[string]any, 0, 2)
+	for _, bucket := range []time.Duration{0, 10 * time.Minute} {
+		store, err := gofeat.New(gofeat.Config{Features: features, BucketSize: bucket})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		ctx := context.Background()
+		for i := range 48 {
+			e := gofeat.Event{
+				Timestamp: base.Add(time.Duration(i) * 7 * time.Minute),
+				Data:      map[string]any{"amount": float64(i % 10 * 10)},
+			}
+			if err := store.Push(ctx, "u1", e); err != nil {
+				t.Fatalf("Push failed: %v", err)
+			}
+		}
+
+		last := base.Add(47 * 7 * time.Minute)
+		now, err := store.GetAt(ctx, "u1", last)
+		if err != nil {
+			t.Fatalf("GetAt failed: %v", err)
+		}
+		later, err := store.GetAt(ctx, "u1", last.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("GetAt failed: %v", err)
+		}
+		for _, name := range []string{"decayed", "decayed_large"} {
+			if got, want := later.FloatOr(name, 0), now.FloatOr(name, 0)/2; math.Abs(got-want) > 1e-9 {
+				t.Errorf("bucket %v: %s an hour later %v, want half of %v", bucket, name, got, now.FloatOr(name, 0))
+			}
+		}
+		results = append(results, now.All())
+	}
+
+	for name, v := range results[0] {
+		if got := results[1][name].(float64); math.Abs(got-v.(float64)) > 1e-9 {
+			t.Errorf("%s: incremental %v, full replay %v", name, got, v)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package gofeat
 
 import (
+	"encoding/binary"
 	"math"
 	"sort"
 	"time"
@@ -55,6 +56,38 @@ func (a *velocityAgg) Result() any {
 	return float64(a.count) / minutes
 }
 
+func (a *velocityAgg) Merge(other Aggregator) error {
+	o, ok := other.(*velocityAgg)
+	if !ok {
+		return mergeError(a, other)
+	}
+	if o.count == 0 {
+		return nil
+	}
+	if a.firstTime.IsZero() || o.firstTime.Before(a.firstTime) {
+		a.firstTime = o.firstTime
+	}
+	if a.lastTime.IsZero() || o.lastTime.After(a.lastTime) {
+		a.lastTime = o.lastTime
+	}
+	a.count += o.count
+	return nil
+}
+
+func (a *velocityAgg) MarshalState() ([]byte, error) {
+	b := binary.AppendVarint(nil, int64(a.count))
+	b = appendTimestamp(b, a.firstTime)
+	return appendTimestamp(b, a.lastTime), nil
+}
+
+func (a *velocityAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		a.count = int(d.varint())
+		a.firstTime = d.timestamp()
+		a.lastTime = d.timestamp()
+	})
+}
+
 // Entropy computes Shannon entropy for a field's values.
 // High entropy = many different values (suspicious for device_id, IP, etc).
 func Entropy(field string) AggregatorFactory {
@@ -96,10 +129,49 @@ func (a *entropyAgg) Result() any {
 	return entropy
 }
 
+func (a *entropyAgg) Merge(other Aggregator) error {
+	o, ok := other.(*entropyAgg)
+	if !ok {
+		return mergeError(a, other)
+	}
+	for v, n := range o.counts {
+		a.counts[v] += n
+	}
+	a.total += o.total
+	return nil
+}
+
+func (a *entropyAgg) MarshalState() ([]byte, error) {
+	b := binary.AppendUvarint(nil, uint64(len(a.counts)))
+	var err error
+	for v, n := range a.counts {
+		if b, err = appendValue(b, v); err != nil {
+			return nil, err
+		}
+		b = binary.AppendVarint(b, int64(n))
+	}
+	return b, nil
+}
+
+func (a *entropyAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		n := d.length()
+		a.counts = make(map[any]int, n)
+		a.total = 0
+		for range n {
+			v := d.value()
+			c := int(d.varint())
+			a.counts[v] = c
+			a.total += c
+		}
+	})
+}
+
 // UniqueRatio computes the ratio of unique values to total events.
 // Returns float64 from 0.0 to 1.0.
 // 1.0 = all values unique (suspicious for cards, emails, etc)
 // 0.0 = all values same.
+// It keeps every value seen; see ApproxUniqueRatio.
 func UniqueRatio(field string) AggregatorFactory {
 	return func() Aggregator {
 		return &uniqueRatioAgg{
@@ -131,6 +203,29 @@ func (a *uniqueRatioAgg) Result() any {
 	return float64(len(a.seen)) / float64(a.total)
 }
 
+func (a *uniqueRatioAgg) Merge(other Aggregator) error {
+	o, ok := other.(*uniqueRatioAgg)
+	if !ok {
+		return mergeError(a, other)
+	}
+	for v := range o.seen {
+		a.seen[v] = struct{}{}
+	}
+	a.total += o.total
+	return nil
+}
+
+func (a *uniqueRatioAgg) MarshalState() ([]byte, error) {
+	return appendSet(binary.AppendVarint(nil, int64(a.total)), a.seen)
+}
+
+func (a *uniqueRatioAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		a.total = int(d.varint())
+		a.seen = d.set()
+	})
+}
+
 // TimeSinceFirst returns duration since the first event.
 // Useful for account age, time since first transaction, etc.
 func TimeSinceFirst() AggregatorFactory {
@@ -163,22 +258,92 @@ func (a *timeSinceFirstAgg) Result() any {
 	return a.lastTime.Sub(a.firstTime)
 }
 
+func (a *timeSinceFirstAgg) Merge(other Aggregator) error {
+	o, ok := other.(*timeSinceFirstAgg)
+	if !ok {
+		return mergeError(a, other)
+	}
+	if o.firstTime.IsZero() {
+		return nil
+	}
+	if a.firstTime.IsZero() || o.firstTime.Before(a.firstTime) {
+		a.firstTime = o.firstTime
+	}
+	if a.lastTime.IsZero() || o.lastTime.After(a.lastTime) {
+		a.lastTime = o.lastTime
+	}
+	return nil
+}
+
+func (a *timeSinceFirstAgg) MarshalState() ([]byte, error) {
+	return appendTimestamp(appendTimestamp(nil, a.firstTime), a.lastTime), nil
+}
+
+func (a *timeSinceFirstAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		a.firstTime = d.timestamp()
+		a.lastTime = d.timestamp()
+	})
+}
+
 // Percentile computes the percentile value for a numeric field.
 // p should be between 0.0 and 1.0 (e.g., 0.95 for p95, 0.99 for p99).
 // Use this for outlier detection.
-func Percentile(field string, p float64) AggregatorFactory {
+//
+// The rank of the percentile is p*(n-1) over the n sorted values; by
+// default the value at the rank rounded down is returned, see
+// WithInterpolation. Percentile keeps every value; ApproxPercentile computes
+// several percentiles in bounded memory.
+func Percentile(field string, p float64, opts ...PercentileOption) AggregatorFactory {
+	o := percentileOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
 	return func() Aggregator {
 		return &percentileAgg{
-			field: field,
-			p:     p,
+			field:         field,
+			p:             p,
+			interpolation: o.interpolation,
 		}
 	}
 }
 
+// Interpolation selects the value Percentile returns when the rank of the
+// percentile falls between two values i < j.
+type Interpolation int
+
+const (
+	// InterpolationLower returns value i. It is the default.
+	InterpolationLower Interpolation = iota
+	// InterpolationHigher returns value j.
+	InterpolationHigher
+	// InterpolationNearest returns the value closest to the rank, i on ties.
+	InterpolationNearest
+	// InterpolationLinear interpolates linearly between i and j.
+	InterpolationLinear
+	// InterpolationMidpoint returns the mean of i and j.
+	InterpolationMidpoint
+)
+
+// PercentileOption configures optional Percentile parameters.
+type PercentileOption func(*percentileOptions)
+
+type percentileOptions struct {
+	interpolation Interpolation
+}
+
+// WithInterpolation sets how Percentile picks a value between two ranks.
+func WithInterpolation(mode Interpolation) PercentileOption {
+	return func(o *percentileOptions) {
+		o.interpolation = mode
+	}
+}
+
 type percentileAgg struct {
-	field  string
-	p      float64
-	values []float64
+	field         string
+	p             float64
+	interpolation Interpolation
+	values        []float64
 }
 
 func (a *percentileAgg) Add(e Event) {
@@ -200,15 +365,42 @@ func (a *percentileAgg) Result() any {
 	copy(sorted, a.values)
 	sort.Float64s(sorted)
 
-	index := int(float64(len(sorted)-1) * a.p)
-	if index < 0 {
-		index = 0
+	rank := min(max(float64(len(sorted)-1)*a.p, 0), float64(len(sorted)-1))
+	lo, hi := sorted[int(math.Floor(rank))], sorted[int(math.Ceil(rank))]
+	switch a.interpolation {
+	case InterpolationHigher:
+		return hi
+	case InterpolationNearest:
+		if rank-math.Floor(rank) > 0.5 {
+			return hi
+		}
+		return lo
+	case InterpolationLinear:
+		return lo + (rank-math.Floor(rank))*(hi-lo)
+	case InterpolationMidpoint:
+		return (lo + hi) / 2
+	default:
+		return lo
 	}
-	if index >= len(sorted) {
-		index = len(sorted) - 1
+}
+
+func (a *percentileAgg) Merge(other Aggregator) error {
+	o, ok := other.(*percentileAgg)
+	if !ok {
+		return mergeError(a, other)
 	}
+	a.values = append(a.values, o.values...)
+	return nil
+}
 
-	return sorted[index]
+func (a *percentileAgg) MarshalState() ([]byte, error) {
+	return appendFloats(nil, a.values), nil
+}
+
+func (a *percentileAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		a.values = d.floats()
+	})
 }
 
 // StandardDeviation computes the standard deviation for a numeric field.
@@ -220,8 +412,10 @@ func StandardDeviation(field string) AggregatorFactory {
 }
 
 type stdDevAgg struct {
-	field  string
-	values []float64
+	field string
+	count int
+	mean  float64
+	m2    float64 // sum of squared differences from the mean
 }
 
 func (a *stdDevAgg) Add(e Event) {
@@ -229,33 +423,52 @@ func (a *stdDevAgg) Add(e Event) {
 	if !ok {
 		return
 	}
-	if f, okF := toFloat64(v); okF {
-		a.values = append(a.values, f)
+	f, ok := toFloat64(v)
+	if !ok {
+		return
 	}
+
+	// Welford's online algorithm keeps the state constant-size and mergeable
+	a.count++
+	delta := f - a.mean
+	a.mean += delta / float64(a.count)
+	a.m2 += delta * (f - a.mean)
 }
 
 func (a *stdDevAgg) Result() any {
-	if len(a.values) == 0 {
+	if a.count == 0 {
 		return 0.0
 	}
+	return math.Sqrt(a.m2 / float64(a.count))
+}
 
-	// Calculate mean
-	var sum float64
-	for _, v := range a.values {
-		sum += v
+func (a *stdDevAgg) Merge(other Aggregator) error {
+	o, ok := other.(*stdDevAgg)
+	if !ok {
+		return mergeError(a, other)
 	}
-	mean := sum / float64(len(a.values))
-
-	// Calculate variance
-	var variance float64
-	for _, v := range a.values {
-		diff := v - mean
-		variance += diff * diff
+	if o.count == 0 {
+		return nil
 	}
-	variance /= float64(len(a.values))
+	n := a.count + o.count
+	delta := o.mean - a.mean
+	a.mean += delta * float64(o.count) / float64(n)
+	a.m2 += o.m2 + delta*delta*float64(a.count)*float64(o.count)/float64(n)
+	a.count = n
+	return nil
+}
 
-	// Standard deviation is square root of variance
-	return math.Sqrt(variance)
+func (a *stdDevAgg) MarshalState() ([]byte, error) {
+	b := binary.AppendVarint(nil, int64(a.count))
+	return appendFloat64(appendFloat64(b, a.mean), a.m2), nil
+}
+
+func (a *stdDevAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		a.count = int(d.varint())
+		a.mean = d.float64()
+		a.m2 = d.float64()
+	})
 }
 
 // Mean computes the average value for a numeric field.
@@ -288,3 +501,24 @@ func (a *meanAgg) Result() any {
 	}
 	return a.sum / float64(a.count)
 }
+
+func (a *meanAgg) Merge(other Aggregator) error {
+	o, ok := other.(*meanAgg)
+	if !ok {
+		return mergeError(a, other)
+	}
+	a.sum += o.sum
+	a.count += o.count
+	return nil
+}
+
+func (a *meanAgg) MarshalState() ([]byte, error) {
+	return binary.AppendVarint(appendFloat64(nil, a.sum), int64(a.count)), nil
+}
+
+func (a *meanAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		a.sum = d.float64()
+		a.count = int(d.varint())
+	})
+}
@@ -277,6 +277,38 @@ func TestPercentile(t *testing.T) {
 	}
 }
 
+func TestPercentile_Interpolation(t *testing.T) {
+	tests := []struct {
+		name string
+		mode gofeat.Interpolation
+		p    float64
+		want float64
+	}{
+		{"lower", gofeat.InterpolationLower, 0.25, 30.0},
+		{"higher", gofeat.InterpolationHigher, 0.25, 40.0},
+		{"nearest down", gofeat.InterpolationNearest, 0.25, 30.0},
+		{"nearest up", gofeat.InterpolationNearest, 0.95, 100.0},
+		{"linear", gofeat.InterpolationLinear, 0.25, 32.5},
+		{"linear p95", gofeat.InterpolationLinear, 0.95, 95.5},
+		{"midpoint", gofeat.InterpolationMidpoint, 0.25, 35.0},
+		{"exact rank", gofeat.InterpolationMidpoint, 1.0, 100.0},
+		{"out of range", gofeat.InterpolationLinear, -0.5, 10.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg := gofeat.Percentile("amount", tt.p, gofeat.WithInterpolation(tt.mode))()
+			// added out of order: the values are sorted on Result
+			for _, v := range []float64{100, 30, 70, 10, 50, 90, 20, 60, 40, 80} {
+				agg.Add(gofeat.Event{Data: map[string]any{"amount": v}})
+			}
+			if got := agg.Result().(float64); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestStandardDeviation(t *testing.T) {
 	tests := []struct {
 		name   string
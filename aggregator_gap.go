@@ -0,0 +1,152 @@
+package gofeat
+
+import (
+	"math"
+	"time"
+)
+
+// MinGap returns the shortest time between two consecutive events as
+// time.Duration, 0 with fewer than two events. Bots often act at a steady
+// pace, which shows as gaps that are short and regular compared to a human.
+// Events must be added in timestamp order, as Store does.
+func MinGap() AggregatorFactory {
+	return func() Aggregator { return &gapAgg{kind: gapMin} }
+}
+
+// MeanGap returns the mean time between consecutive events as
+// time.Duration. See MinGap.
+func MeanGap() AggregatorFactory {
+	return func() Aggregator { return &gapAgg{kind: gapMean} }
+}
+
+// GapStdDev returns the standard deviation of the times between
+// consecutive events as time.Duration. See MinGap.
+func GapStdDev() AggregatorFactory {
+	return func() Aggregator { return &gapAgg{kind: gapStdDev} }
+}
+
+// GapCoefficientOfVariation returns the standard deviation of the times
+// between consecutive events divided by their mean, as float64: close to 0
+// for events at a regular pace, around 1 for random (Poisson) arrivals and
+// above for bursts. It is 0 when the mean gap is 0. See MinGap.
+func GapCoefficientOfVariation() AggregatorFactory {
+	return func() Aggregator { return &gapAgg{kind: gapCV} }
+}
+
+type gapKind int
+
+const (
+	gapMin gapKind = iota
+	gapMean
+	gapStdDev
+	gapCV
+)
+
+// gapAgg keeps running statistics of the gaps in seconds. It does not
+// implement Merger: its state depends on the order events are added in,
+// which buckets do not preserve.
+type gapAgg struct {
+	kind gapKind
+
+	last time.Time
+	seen bool
+	n    int     // number of gaps
+	mean float64 // mean gap in seconds
+	m2   float64 // sum of squared differences from the mean
+	min  float64
+}
+
+func (a *gapAgg) Add(e Event) {
+	if a.seen {
+		a.addGap(e.Timestamp.Sub(a.last).Seconds())
+	}
+	a.last = e.Timestamp
+	a.seen = true
+}
+
+// addGap updates the statistics with Welford's online algorithm.
+func (a *gapAgg) addGap(gap float64) {
+	if a.n == 0 || gap < a.min {
+		a.min = gap
+	}
+	a.n++
+	delta := gap - a.mean
+	a.mean += delta / float64(a.n)
+	a.m2 += delta * (gap - a.mean)
+}
+
+func (a *gapAgg) stdDev() float64 {
+	if a.n == 0 {
+		return 0
+	}
+	return math.Sqrt(a.m2 / float64(a.n))
+}
+
+func (a *gapAgg) Result() any {
+	switch a.kind {
+	case gapMin:
+		return secondsDuration(a.min)
+	case gapMean:
+		return secondsDuration(a.mean)
+	case gapStdDev:
+		return secondsDuration(a.stdDev())
+	default:
+		if a.mean == 0 {
+			return 0.0
+		}
+		return a.stdDev() / a.mean
+	}
+}
+
+func secondsDuration(s float64) time.Duration {
+	return time.Duration(math.Round(s * float64(time.Second)))
+}
+
+// TimeSinceLast returns the time between the last event and the query time
+// as time.Duration, e.g. how long an account has been idle. Unlike
+// TimeSinceFirst, which measures the span from the first to the last event,
+// it keeps growing while no event arrives. It is 0 without events, and
+// Result without a query time returns 0 as well.
+func TimeSinceLast() AggregatorFactory {
+	return func() Aggregator { return &timeSinceLastAgg{} }
+}
+
+type timeSinceLastAgg struct {
+	last time.Time
+}
+
+func (a *timeSinceLastAgg) Add(e Event) {
+	if a.last.IsZero() || e.Timestamp.After(a.last) {
+		a.last = e.Timestamp
+	}
+}
+
+func (a *timeSinceLastAgg) Result() any { return a.ResultAt(a.last) }
+
+func (a *timeSinceLastAgg) ResultAt(t time.Time) any {
+	if a.last.IsZero() || t.Before(a.last) {
+		return time.Duration(0)
+	}
+	return t.Sub(a.last)
+}
+
+func (a *timeSinceLastAgg) Merge(other Aggregator) error {
+	o, ok := other.(*timeSinceLastAgg)
+	if !ok {
+		return mergeError(a, other)
+	}
+	if a.last.IsZero() || o.last.After(a.last) {
+		a.last = o.last
+	}
+	return nil
+}
+
+func (a *timeSinceLastAgg) MarshalState() ([]byte, error) {
+	return appendTimestamp(nil, a.last), nil
+}
+
+func (a *timeSinceLastAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		a.last = d.timestamp()
+	})
+}
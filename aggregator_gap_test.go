@@ -0,0 +1,185 @@
+package gofeat_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+func gapEvents(base time.Time, offsets ...time.Duration) []gofeat.Event {
+	events := make([]gofeat.Event, 0, len(offsets))
+	for _, d := range offsets {
+		events = append(events, gofeat.Event{Timestamp: base.Add(d)})
+	}
+	return events
+}
+
+func TestGapAggregators(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	// gaps of 10s, 10s and 40s: mean 20s, standard deviation sqrt(200)s
+	events := gapEvents(base, 0, 10*time.Second, 20*time.Second, time.Minute)
+	stdDev := time.Duration(math.Round(math.Sqrt(200) * float64(time.Second)))
+
+	tests := []struct {
+		name    string
+		factory gofeat.AggregatorFactory
+		events  []gofeat.Event
+		want    any
+	}{
+		{"min gap", gofeat.MinGap(), events, 10 * time.Second},
+		{"mean gap", gofeat.MeanGap(), events, 20 * time.Second},
+		{"gap std dev", gofeat.GapStdDev(), events, stdDev},
+		{"gap cv", gofeat.GapCoefficientOfVariation(), events, math.Sqrt(200) / 20},
+		{"single event", gofeat.MeanGap(), events[:1], time.Duration(0)},
+		{"no events", gofeat.GapCoefficientOfVariation(), nil, 0.0},
+		{"same timestamp", gofeat.GapCoefficientOfVariation(), gapEvents(base, 0, 0, 0), 0.0},
+		{"regular pace", gofeat.GapCoefficientOfVariation(), gapEvents(base, 0, time.Second, 2*time.Second, 3*time.Second), 0.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg := tt.factory()
+			for _, e := range tt.events {
+				agg.Add(e)
+			}
+			if got := agg.Result(); !sameResult(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGapCoefficientOfVariation_Bursts(t *testing.T) {
+	// Bursts of quick actions separated by long pauses vary more than
+	// events at a steady pace
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	agg := gofeat.GapCoefficientOfVariation()()
+	for _, e := range gapEvents(base, 0, time.Second, 2*time.Second, time.Hour, time.Hour+time.Second, 2*time.Hour) {
+		agg.Add(e)
+	}
+	if got := agg.Result().(float64); got <= 1 {
+		t.Errorf("got %v, want above 1", got)
+	}
+}
+
+func TestGapAggregators_OutOfOrderPush(t *testing.T) {
+	// Buckets receive events in push order, so gap statistics must be
+	// computed from the sorted events rather than merged from buckets
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store, err := gofeat.New(gofeat.Config{
+		BucketSize: 10 * time.Minute,
+		Features: []gofeat.Feature{
+			{Name: "min_gap", Aggregate: gofeat.MinGap(), Window: gofeat.Sliding(time.Hour)},
+			{Name: "mean_gap", Aggregate: gofeat.MeanGap()},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ctx := context.Background()
+	for _, m := range []time.Duration{25, 21, 23, 22, 5, 40} {
+		if err := store.Push(ctx, "u1", gofeat.Event{Timestamp: base.Add(m * time.Minute)}); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+	}
+
+	result, err := store.GetAt(ctx, "u1", base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GetAt failed: %v", err)
+	}
+	if got := result.DurationOr("min_gap", 0); got != time.Minute {
+		t.Errorf("min_gap: got %v, want 1m", got)
+	}
+	if got := result.DurationOr("mean_gap", 0); got != 7*time.Minute {
+		t.Errorf("mean_gap: got %v, want 7m", got)
+	}
+}
+
+func TestTimeSinceLast(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	agg := gofeat.TimeSinceLast()()
+	if got := agg.(gofeat.TimeAware).ResultAt(base); got != time.Duration(0) {
+		t.Errorf("empty result %v, want 0", got)
+	}
+	for _, e := range gapEvents(base, time.Hour, 0) { // out of order
+		agg.Add(e)
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want time.Duration
+	}{
+		{"at last event", base.Add(time.Hour), 0},
+		{"later", base.Add(3 * time.Hour), 2 * time.Hour},
+		{"before last event", base, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := agg.(gofeat.TimeAware).ResultAt(tt.at); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if got := agg.Result(); got != time.Duration(0) {
+		t.Errorf("Result: got %v, want 0", got)
+	}
+}
+
+func TestGapAggregators_Store(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	features := []gofeat.Feature{
+		{Name: "min_gap", Aggregate: gofeat.MinGap(), Window: gofeat.Sliding(time.Hour)},
+		{Name: "mean_gap", Aggregate: gofeat.MeanGap()},
+		{Name: "gap_std_dev", Aggregate: gofeat.GapStdDev()},
+		{Name: "gap_cv", Aggregate: gofeat.GapCoefficientOfVariation(), Window: gofeat.Sliding(time.Hour)},
+		{Name: "idle", Aggregate: gofeat.TimeSinceLast()},
+		{Name: "idle_hour", Aggregate: gofeat.TimeSinceLast(), Window: gofeat.Sliding(time.Hour)},
+	}
+
+	// The incremental path must give the same results as a full replay
+	results := make([]map[string]any, 0, 2)
+	for _, bucket := range []time.Duration{0, 10 * time.Minute} {
+		store, err := gofeat.New(gofeat.Config{Features: features, BucketSize: bucket})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		ctx := context.Background()
+		for i := range 40 {
+			e := gofeat.Event{Timestamp: base.Add(time.Duration(i*i) * 20 * time.Second)}
+			if err := store.Push(ctx, "u1", e); err != nil {
+				t.Fatalf("Push failed: %v", err)
+			}
+		}
+
+		last := base.Add(39 * 39 * 20 * time.Second)
+		later, err := store.GetAt(ctx, "u1", last.Add(30*time.Minute))
+		if err != nil {
+			t.Fatalf("GetAt failed: %v", err)
+		}
+		if got := later.DurationOr("idle", 0); got != 30*time.Minute {
+			t.Errorf("bucket %v: idle %v, want 30m", bucket, got)
+		}
+		if got := later.DurationOr("idle_hour", 0); got != 30*time.Minute {
+			t.Errorf("bucket %v: idle_hour %v, want 30m", bucket, got)
+		}
+
+		now, err := store.GetAt(ctx, "u1", last)
+		if err != nil {
+			t.Fatalf("GetAt failed: %v", err)
+		}
+		if got := now.DurationOr("mean_gap", 0); got != last.Sub(base)/39 {
+			t.Errorf("bucket %v: mean_gap %v, want %v", bucket, got, last.Sub(base)/39)
+		}
+		results = append(results, now.All())
+	}
+
+	for name, v := range results[0] {
+		if got := results[1][name]; !sameResult(got, v) {
+			t.Errorf("%s: incremental %v, full replay %v", name, got, v)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package gofeat_test
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+func mergeTestFactories() map[string]gofeat.AggregatorFactory {
+	return map[string]gofeat.AggregatorFactory{
+		"Count":               gofeat.Count,
+		"Sum":                 gofeat.Sum("amount"),
+		"Min":                 gofeat.Min("amount"),
+		"Max":                 gofeat.Max("amount"),
+		"Last":                gofeat.Last("country"),
+		"DistinctCount":       gofeat.DistinctCount("country"),
+		"Velocity":            gofeat.Velocity(time.Hour),
+		"Entropy":             gofeat.Entropy("country"),
+		"UniqueRatio":         gofeat.UniqueRatio("country"),
+		"TimeSinceFirst":      gofeat.TimeSinceFirst(),
+		"Percentile":          gofeat.Percentile("amount", 0.9),
+		"StandardDeviation":   gofeat.StandardDeviation("amount"),
+		"Mean":                gofeat.Mean("amount"),
+		"ApproxDistinctCount": gofeat.ApproxDistinctCount("country", 10),
+		"ApproxUniqueRatio":   gofeat.ApproxUniqueRatio("country", 10),
+		"ApproxPercentile":    gofeat.ApproxPercentile("amount", 0.5, 0.9),
+		"TopK":                gofeat.TopK("country", 2),
+		"ApproxTopK":          gofeat.ApproxTopK("country", 2, 8),
+		"Histogram":           gofeat.Histogram("amount", 15, 30),
+		"EWMA":                gofeat.EWMA("amount", 2*time.Minute),
+		"DecayedCount":        gofeat.DecayedCount(2 * time.Minute),
+		"TimeSinceLast":       gofeat.TimeSinceLast(),
+	}
+}
+
+func mergeTestEvents() []gofeat.Event {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	countries := []string{"US", "DE", "US", "FR", "BR", "US", "DE"}
+	events := make([]gofeat.Event, 0, len(countries))
+	for i, c := range countries {
+		events = append(events, gofeat.Event{
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+			Data:      map[string]any{"amount": float64(10 + i*i), "country": c},
+		})
+	}
+	return events
+}
+
+func sameResult(a, b any) bool {
+	fa, okA := a.(float64)
+	fb, okB := b.(float64)
+	if okA && okB {
+		return math.Abs(fa-fb) < 1e-9
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func TestAggregators_Merge(t *testing.T) {
+	events := mergeTestEvents()
+
+	for name, factory := range mergeTestFactories() {
+		t.Run(name, func(t *testing.T) {
+			whole := factory()
+			for _, e := range events {
+				whole.Add(e)
+			}
+
+			// Merge out of order to make sure results do not depend on it
+			left, right := factory(), factory()
+			for _, e := range events[:3] {
+				left.Add(e)
+			}
+			for _, e := range events[3:] {
+				right.Add(e)
+			}
+			merged := factory()
+			m, ok := merged.(gofeat.Merger)
+			if !ok {
+				t.Fatalf("%T does not implement Merger", merged)
+			}
+			if err := m.Merge(right); err != nil {
+				t.Fatalf("Merge failed: %v", err)
+			}
+			if err := m.Merge(left); err != nil {
+				t.Fatalf("Merge failed: %v", err)
+			}
+			if err := m.Merge(factory()); err != nil {
+				t.Fatalf("Merge of empty state failed: %v", err)
+			}
+
+			if !sameResult(merged.Result(), whole.Result()) {
+				t.Errorf("merged result %v, want %v", merged.Result(), whole.Result())
+			}
+			if !sameResult(right.Result(), func() any {
+				agg := factory()
+				for _, e := range events[3:] {
+					agg.Add(e)
+				}
+				return agg.Result()
+			}()) {
+				t.Error("Merge modified its argument")
+			}
+		})
+	}
+}
+
+func TestAggregators_MarshalState(t *testing.T) {
+	events := mergeTestEvents()
+
+	for name, factory := range mergeTestFactories() {
+		t.Run(name, func(t *testing.T) {
+			agg := factory()
+			for _, e := range events {
+				agg.Add(e)
+			}
+
+			data, err := agg.(gofeat.StateMarshaler).MarshalState()
+			if err != nil {
+				t.Fatalf("MarshalState failed: %v", err)
+			}
+			restored := factory()
+			restored.Add(events[0]) // state must be replaced, not merged
+			if err := restored.(gofeat.StateMarshaler).UnmarshalState(data); err != nil {
+				t.Fatalf("UnmarshalState failed: %v", err)
+			}
+			if !sameResult(restored.Result(), agg.Result()) {
+				t.Errorf("restored result %v, want %v", restored.Result(), agg.Result())
+			}
+
+			// A restored state must keep merging correctly
+			extra := factory()
+			extra.Add(events[len(events)-1])
+			agg.(gofeat.Merger).Merge(extra)
+			restored.(gofeat.Merger).Merge(extra)
+			if !sameResult(restored.Result(), agg.Result()) {
+				t.Errorf("after merge: restored result %v, want %v", restored.Result(), agg.Result())
+			}
+
+			if len(data) > 0 {
+				if err := factory().(gofeat.StateMarshaler).UnmarshalState(data[:len(data)-1]); err == nil {
+					t.Error("expected error for truncated state")
+				}
+			}
+		})
+	}
+}
+
+func TestAggregators_MergeIncompatible(t *testing.T) {
+	err := gofeat.Count().(gofeat.Merger).Merge(gofeat.Sum("amount")())
+	if !errors.Is(err, gofeat.ErrIncompatibleAggregator) {
+		t.Errorf("expected ErrIncompatibleAggregator, got %v", err)
+	}
+}
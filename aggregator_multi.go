@@ -0,0 +1,380 @@
+package gofeat
+
+import (
+	"cmp"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ValueCount is a value of a field and the number of events holding it,
+// as returned by TopK and ApproxTopK.
+type ValueCount struct {
+	Value any `json:"value"`
+	Count int `json:"count"`
+}
+
+// TopK returns the k most frequent values of a field as []ValueCount,
+// ordered by decreasing count. Values with the same count are ordered by
+// value. Like DistinctCount it keeps a counter per value; ApproxTopK
+// bounds memory for high-cardinality fields.
+func TopK(field string, k int) AggregatorFactory {
+	return func() Aggregator {
+		return &topKAgg{field: field, k: k, counts: make(map[any]int)}
+	}
+}
+
+type topKAgg struct {
+	field  string
+	k      int
+	counts map[any]int
+}
+
+func (a *topKAgg) Add(e Event) {
+	v, ok := e.Data[a.field]
+	if !ok {
+		return
+	}
+	a.counts[v]++
+}
+
+func (a *topKAgg) Result() any {
+	top := make([]ValueCount, 0, len(a.counts))
+	for v, n := range a.counts {
+		top = append(top, ValueCount{Value: v, Count: n})
+	}
+	return topValues(top, a.k)
+}
+
+func (a *topKAgg) Merge(other Aggregator) error {
+	o, ok := other.(*topKAgg)
+	if !ok {
+		return mergeError(a, other)
+	}
+	for v, n := range o.counts {
+		a.counts[v] += n
+	}
+	return nil
+}
+
+func (a *topKAgg) MarshalState() ([]byte, error) {
+	b := binary.AppendUvarint(nil, uint64(len(a.counts)))
+	var err error
+	for v, n := range a.counts {
+		if b, err = appendValue(b, v); err != nil {
+			return nil, err
+		}
+		b = binary.AppendVarint(b, int64(n))
+	}
+	return b, nil
+}
+
+func (a *topKAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		n := d.length()
+		a.counts = make(map[any]int, n)
+		for range n {
+			v := d.value()
+			a.counts[v] = int(d.varint())
+		}
+	})
+}
+
+// ApproxTopK returns the k most frequent values of a field like TopK, using
+// the Space-Saving algorithm (Metwally et al., 2005) with at most capacity
+// counters. When a new value arrives and all counters are taken, it
+// replaces the value with the lowest count and inherits that count, so
+// counts may be overestimated by at most the number of events divided by
+// capacity, and any value more frequent than that is in the result. A
+// capacity of a few times k gives good results; it is raised to k if lower.
+func ApproxTopK(field string, k, capacity int) AggregatorFactory {
+	capacity = max(capacity, k, 1)
+	return func() Aggregator {
+		return &approxTopKAgg{field: field, k: k, capacity: capacity, counters: make(map[any]*ssCounter)}
+	}
+}
+
+// ssCounter is a Space-Saving counter: count overestimates the number of
+// events holding the value by at most err.
+type ssCounter struct {
+	value any
+	count int
+	err   int
+	seq   uint64 // creation order, the oldest counter is replaced on ties
+	index int    // position in the heap
+}
+
+// ssHeap is a min-heap of counters by count, then creation order.
+type ssHeap []*ssCounter
+
+func (h ssHeap) Len() int { return len(h) }
+func (h ssHeap) Less(i, j int) bool {
+	if h[i].count != h[j].count {
+		return h[i].count < h[j].count
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h ssHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *ssHeap) Push(x any) {
+	c, _ := x.(*ssCounter)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *ssHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+type approxTopKAgg struct {
+	field    string
+	k        int
+	capacity int
+	counters map[any]*ssCounter
+	heap     ssHeap
+	seq      uint64
+}
+
+func (a *approxTopKAgg) Add(e Event) {
+	v, ok := e.Data[a.field]
+	if !ok {
+		return
+	}
+	if c, ok := a.counters[v]; ok {
+		c.count++
+		heap.Fix(&a.heap, c.index)
+		return
+	}
+	a.seq++
+	if len(a.heap) < a.capacity {
+		c := &ssCounter{value: v, count: 1, seq: a.seq}
+		a.counters[v] = c
+		heap.Push(&a.heap, c)
+		return
+	}
+	c := a.heap[0]
+	delete(a.counters, c.value)
+	c.value, c.err, c.seq = v, c.count, a.seq
+	c.count++
+	a.counters[v] = c
+	heap.Fix(&a.heap, 0)
+}
+
+// floor is the count a value missing from the summary may have had: the
+// lowest count once all counters are taken, zero before.
+func (a *approxTopKAgg) floor() int {
+	if len(a.heap) < a.capacity {
+		return 0
+	}
+	return a.heap[0].count
+}
+
+func (a *approxTopKAgg) Result() any {
+	top := make([]ValueCount, 0, len(a.heap))
+	for _, c := range a.heap {
+		top = append(top, ValueCount{Value: c.value, Count: c.count})
+	}
+	return topValues(top, a.k)
+}
+
+// Merge combines two summaries (Agarwal et al., 2012): a value missing from
+// one of them is counted with that summary's floor, then only the capacity
+// highest counters are kept.
+func (a *approxTopKAgg) Merge(other Aggregator) error {
+	o, ok := other.(*approxTopKAgg)
+	if !ok {
+		return mergeError(a, other)
+	}
+	if a.capacity != o.capacity {
+		return fmt.Errorf("%w: cannot merge ApproxTopK capacity %d into %d", ErrIncompatibleAggregator, o.capacity, a.capacity)
+	}
+	aFloor, oFloor := a.floor(), o.floor()
+	merged := make([]*ssCounter, 0, len(a.heap)+len(o.heap))
+	for _, c := range a.heap {
+		if oc, ok := o.counters[c.value]; ok {
+			c.count += oc.count
+			c.err += oc.err
+		} else {
+			c.count += oFloor
+			c.err += oFloor
+		}
+		merged = append(merged, c)
+	}
+	for _, oc := range o.heap {
+		if _, ok := a.counters[oc.value]; !ok {
+			merged = append(merged, &ssCounter{value: oc.value, count: oc.count + aFloor, err: oc.err + aFloor})
+		}
+	}
+
+	slices.SortFunc(merged, func(x, y *ssCounter) int {
+		if x.count != y.count {
+			return cmp.Compare(y.count, x.count)
+		}
+		return compareValues(x.value, y.value)
+	})
+	a.reset(merged[:min(len(merged), a.capacity)])
+	return nil
+}
+
+// reset replaces the counters with sorted ones, by decreasing count and
+// then value; on ties the last one is replaced first.
+func (a *approxTopKAgg) reset(sorted []*ssCounter) {
+	a.counters = make(map[any]*ssCounter, len(sorted))
+	a.heap = make(ssHeap, 0, len(sorted))
+	a.seq = 0
+	for i := len(sorted) - 1; i >= 0; i-- {
+		c := sorted[i]
+		a.seq++
+		c.seq = a.seq
+		a.counters[c.value] = c
+		heap.Push(&a.heap, c)
+	}
+}
+
+func (a *approxTopKAgg) MarshalState() ([]byte, error) {
+	// Counters are written oldest first, so that ties are broken the same
+	// way after UnmarshalState
+	ordered := slices.SortedFunc(slices.Values(a.heap), func(x, y *ssCounter) int {
+		return cmp.Compare(x.seq, y.seq)
+	})
+	b := binary.AppendUvarint(nil, uint64(len(ordered)))
+	var err error
+	for _, c := range ordered {
+		if b, err = appendValue(b, c.value); err != nil {
+			return nil, err
+		}
+		b = binary.AppendVarint(binary.AppendVarint(b, int64(c.count)), int64(c.err))
+	}
+	return b, nil
+}
+
+func (a *approxTopKAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		n := d.length()
+		if n > a.capacity {
+			d.fail(fmt.Errorf("%w: ApproxTopK state has %d counters, capacity is %d", ErrIncompatibleAggregator, n, a.capacity))
+			return
+		}
+		a.counters = make(map[any]*ssCounter, n)
+		a.heap = make(ssHeap, 0, n)
+		a.seq = 0
+		for range n {
+			v := d.value()
+			count := int(d.varint())
+			if _, ok := a.counters[v]; ok {
+				d.fail(fmt.Errorf("gofeat: duplicate ApproxTopK value %v", v))
+				return
+			}
+			a.seq++
+			c := &ssCounter{value: v, count: count, err: int(d.varint()), seq: a.seq}
+			a.counters[v] = c
+			heap.Push(&a.heap, c)
+		}
+	})
+}
+
+// topValues sorts values by decreasing count, then by value, and keeps the
+// first k.
+func topValues(top []ValueCount, k int) []ValueCount {
+	slices.SortFunc(top, func(a, b ValueCount) int {
+		if a.Count != b.Count {
+			return cmp.Compare(b.Count, a.Count)
+		}
+		return compareValues(a.Value, b.Value)
+	})
+	return top[:min(max(k, 0), len(top))]
+}
+
+// compareValues orders numbers numerically and strings lexically, and
+// other values by type and fmt representation.
+func compareValues(a, b any) int {
+	fa, okA := toFloat64(a)
+	fb, okB := toFloat64(b)
+	if okA && okB && fa != fb {
+		return cmp.Compare(fa, fb)
+	}
+	sa, okA := a.(string)
+	sb, okB := b.(string)
+	if okA && okB {
+		return strings.Compare(sa, sb)
+	}
+	return strings.Compare(fmt.Sprintf("%T:%v", a, a), fmt.Sprintf("%T:%v", b, b))
+}
+
+// Histogram counts the values of a numeric field in the buckets delimited
+// by boundaries, as []int of len(boundaries)+1 counts: bucket i holds the
+// values v with boundaries[i-1] < v <= boundaries[i], the first bucket
+// every value up to boundaries[0] and the last one every value above the
+// last boundary. Boundaries are sorted and deduplicated.
+func Histogram(field string, boundaries ...float64) AggregatorFactory {
+	boundaries = slices.Compact(slices.Sorted(slices.Values(boundaries)))
+	return func() Aggregator {
+		return &histogramAgg{field: field, boundaries: boundaries, counts: make([]int, len(boundaries)+1)}
+	}
+}
+
+type histogramAgg struct {
+	field      string
+	boundaries []float64
+	counts     []int
+}
+
+func (a *histogramAgg) Add(e Event) {
+	v, ok := e.Data[a.field]
+	if !ok {
+		return
+	}
+	f, ok := toFloat64(v)
+	if !ok || math.IsNaN(f) {
+		return
+	}
+	a.counts[sort.SearchFloat64s(a.boundaries, f)]++
+}
+
+func (a *histogramAgg) Result() any { return slices.Clone(a.counts) }
+
+func (a *histogramAgg) Merge(other Aggregator) error {
+	o, ok := other.(*histogramAgg)
+	if !ok {
+		return mergeError(a, other)
+	}
+	if !slices.Equal(a.boundaries, o.boundaries) {
+		return fmt.Errorf("%w: cannot merge histograms with different boundaries", ErrIncompatibleAggregator)
+	}
+	for i, n := range o.counts {
+		a.counts[i] += n
+	}
+	return nil
+}
+
+func (a *histogramAgg) MarshalState() ([]byte, error) {
+	b := binary.AppendUvarint(nil, uint64(len(a.counts)))
+	for _, n := range a.counts {
+		b = binary.AppendVarint(b, int64(n))
+	}
+	return b, nil
+}
+
+func (a *histogramAgg) UnmarshalState(data []byte) error {
+	return decodeState(data, func(d *decoder) {
+		if n := d.length(); d.err == nil && n != len(a.boundaries)+1 {
+			d.fail(fmt.Errorf("%w: histogram state has %d buckets, want %d", ErrIncompatibleAggregator, n, len(a.boundaries)+1))
+			return
+		}
+		for i := range a.counts {
+			a.counts[i] = int(d.varint())
+		}
+	})
+}
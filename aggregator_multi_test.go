@@ -0,0 +1,213 @@
+package gofeat_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+func valueEvents(field string, values ...any) []gofeat.Event {
+	events := make([]gofeat.Event, 0, len(values))
+	for _, v := range values {
+		events = append(events, gofeat.Event{Data: map[string]any{field: v}})
+	}
+	return events
+}
+
+func TestTopK(t *testing.T) {
+	events := valueEvents("merchant", "m1", "m2", "m1", "m3", "m2", "m1", "m4", 7, 7)
+
+	tests := []struct {
+		name    string
+		factory gofeat.AggregatorFactory
+		want    []gofeat.ValueCount
+	}{
+		{
+			name:    "top 3",
+			factory: gofeat.TopK("merchant", 3),
+			want:    []gofeat.ValueCount{{Value: "m1", Count: 3}, {Value: 7, Count: 2}, {Value: "m2", Count: 2}},
+		},
+		{
+			name:    "k above distinct values",
+			factory: gofeat.TopK("merchant", 10),
+			want: []gofeat.ValueCount{
+				{Value: "m1", Count: 3}, {Value: 7, Count: 2}, {Value: "m2", Count: 2}, {Value: "m3", Count: 1}, {Value: "m4", Count: 1},
+			},
+		},
+		{
+			name:    "zero k",
+			factory: gofeat.TopK("merchant", 0),
+			want:    []gofeat.ValueCount{},
+		},
+		{
+			name:    "approx within capacity",
+			factory: gofeat.ApproxTopK("merchant", 3, 5),
+			want:    []gofeat.ValueCount{{Value: "m1", Count: 3}, {Value: 7, Count: 2}, {Value: "m2", Count: 2}},
+		},
+		{
+			name:    "approx over capacity",
+			factory: gofeat.ApproxTopK("merchant", 1, 2),
+			// 7 replaces m2 and inherits its count of 3: overestimated
+			want: []gofeat.ValueCount{{Value: 7, Count: 5}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg := tt.factory()
+			for _, e := range events {
+				agg.Add(e)
+			}
+			agg.Add(gofeat.Event{Data: map[string]any{"other": "m1"}})
+
+			if got := agg.Result(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApproxTopK_Skewed(t *testing.T) {
+	// 5 heavy hitters among 10000 rare values, split across parts merged as
+	// incremental aggregation does
+	agg := gofeat.ApproxTopK("ip", 5, 50)()
+	for part := range 10 {
+		p := gofeat.ApproxTopK("ip", 5, 50)()
+		for i := range 2000 {
+			ip := "rare-" + strconv.Itoa(part*1000+i/2)
+			if i%4 == 0 {
+				ip = "heavy-" + strconv.Itoa(i%20/4)
+			}
+			p.Add(gofeat.Event{Data: map[string]any{"ip": ip}})
+		}
+		if err := agg.(gofeat.Merger).Merge(p); err != nil {
+			t.Fatalf("Merge failed: %v", err)
+		}
+	}
+
+	top, _ := agg.Result().([]gofeat.ValueCount)
+	if len(top) != 5 {
+		t.Fatalf("got %v, want 5 values", top)
+	}
+	for _, vc := range top {
+		// each heavy hitter appears 1000 times; overestimation is bounded by
+		// 20000 events / 50 counters
+		if !strings.HasPrefix(vc.Value.(string), "heavy-") || vc.Count < 1000 || vc.Count > 1000+20000/50 {
+			t.Errorf("unexpected top value %v", vc)
+		}
+	}
+}
+
+func TestApproxTopK_MergeCapacity(t *testing.T) {
+	err := gofeat.ApproxTopK("ip", 2, 4)().(gofeat.Merger).Merge(gofeat.ApproxTopK("ip", 2, 8)())
+	if !errors.Is(err, gofeat.ErrIncompatibleAggregator) {
+		t.Errorf("expected ErrIncompatibleAggregator, got %v", err)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	tests := []struct {
+		name       string
+		boundaries []float64
+		values     []any
+		want       []int
+	}{
+		{
+			name:       "buckets",
+			boundaries: []float64{10, 100, 1000},
+			values:     []any{5, 10.0, 10.5, 99, 100, int64(500), 1000.01, 1e9},
+			want:       []int{2, 3, 1, 2},
+		},
+		{
+			name:       "unsorted boundaries",
+			boundaries: []float64{100, 10, 100},
+			values:     []any{50.0, 150.0},
+			want:       []int{0, 1, 1},
+		},
+		{
+			name:       "non-numeric values",
+			boundaries: []float64{0},
+			values:     []any{"1", math.NaN(), math.Inf(-1), nil},
+			want:       []int{1, 0},
+		},
+		{
+			name: "no boundaries",
+			want: []int{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg := gofeat.Histogram("amount", tt.boundaries...)()
+			for _, e := range valueEvents("amount", tt.values...) {
+				agg.Add(e)
+			}
+			if got := agg.Result(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistogram_MergeBoundaries(t *testing.T) {
+	err := gofeat.Histogram("amount", 1, 2)().(gofeat.Merger).Merge(gofeat.Histogram("amount", 1, 3)())
+	if !errors.Is(err, gofeat.ErrIncompatibleAggregator) {
+		t.Errorf("expected ErrIncompatibleAggregator, got %v", err)
+	}
+}
+
+func TestMultiValued_Store(t *testing.T) {
+	cfg, err := gofeat.LoadConfig(strings.NewReader(`{"bucket_size": "1m", "features": [
+		{"name": "top_merchants", "aggregate": {"kind": "top_k", "field": "merchant", "k": 2}, "window": {"kind": "sliding", "size": "1h"}},
+		{"name": "top_merchants_approx", "aggregate": {"kind": "approx_top_k", "field": "merchant", "k": 2, "capacity": 10}},
+		{"name": "amounts", "aggregate": {"kind": "histogram", "field": "amount", "boundaries": [10, 100]}}
+	]}`))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	store, err := gofeat.New(cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	merchants := []string{"shop", "cafe", "shop", "fuel", "cafe", "shop"}
+	for i, m := range merchants {
+		e := gofeat.Event{
+			Timestamp: base.Add(time.Duration(i) * 5 * time.Minute),
+			Data:      map[string]any{"merchant": m, "amount": float64(i * 30)},
+		}
+		if err := store.Push(ctx, "u1", e); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+	}
+
+	result, err := store.GetAt(ctx, "u1", base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GetAt failed: %v", err)
+	}
+	want := []gofeat.ValueCount{{Value: "shop", Count: 3}, {Value: "cafe", Count: 2}}
+	for _, name := range []string{"top_merchants", "top_merchants_approx"} {
+		if got, err := result.TopK(name); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if got, err := result.Histogram("amounts"); err != nil || !reflect.DeepEqual(got, []int{1, 3, 2}) {
+		t.Errorf("amounts: got %v, %v, want [1 3 2]", got, err)
+	}
+
+	if _, err := result.Histogram("top_merchants"); err == nil {
+		t.Error("expected error for a top-K feature")
+	}
+	if got := result.TopKOr("missing", nil); got != nil {
+		t.Errorf("TopKOr: got %v", got)
+	}
+}
@@ -0,0 +1,57 @@
+package gofeat
+
+import "time"
+
+// SessionCount returns the number of events in the current session, where
+// a session ends after more than gap of inactivity. Result reports the last
+// session; ResultAt, which Store uses, reports 0 once it has ended. It
+// matches Count over a Session(gap) window and can be used with any window.
+// Events must be added in timestamp order, as Store does.
+func SessionCount(gap time.Duration) AggregatorFactory {
+	return func() Aggregator {
+		return &sessionAgg{gap: gap}
+	}
+}
+
+// SessionDuration returns the time between the first and the last event of
+// the current session as time.Duration, 0 once it has ended. See
+// SessionCount.
+func SessionDuration(gap time.Duration) AggregatorFactory {
+	return func() Aggregator {
+		return &sessionAgg{gap: gap, duration: true}
+	}
+}
+
+type sessionAgg struct {
+	gap      time.Duration
+	duration bool
+	count    int
+	start    time.Time
+	last     time.Time
+}
+
+func (a *sessionAgg) Add(e Event) {
+	if a.count == 0 || e.Timestamp.Sub(a.last) > a.gap {
+		a.count = 0
+		a.start = e.Timestamp
+	}
+	a.count++
+	a.last = e.Timestamp
+}
+
+func (a *sessionAgg) Result() any {
+	if a.duration {
+		return a.last.Sub(a.start)
+	}
+	return a.count
+}
+
+func (a *sessionAgg) ResultAt(t time.Time) any {
+	if a.count > 0 && t.Sub(a.last) > a.gap {
+		if a.duration {
+			return time.Duration(0)
+		}
+		return 0
+	}
+	return a.Result()
+}
@@ -0,0 +1,113 @@
+package gofeat_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+func TestSessionAggregators(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		offsets      []time.Duration
+		wantCount    int
+		wantDuration time.Duration
+	}{
+		{name: "no events", wantCount: 0, wantDuration: 0},
+		{name: "single event", offsets: []time.Duration{0}, wantCount: 1, wantDuration: 0},
+		{
+			name:         "one session",
+			offsets:      []time.Duration{0, 5 * time.Minute, 12 * time.Minute},
+			wantCount:    3,
+			wantDuration: 12 * time.Minute,
+		},
+		{
+			name:         "new session after gap",
+			offsets:      []time.Duration{0, 5 * time.Minute, 30 * time.Minute, 31 * time.Minute},
+			wantCount:    2,
+			wantDuration: time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count := gofeat.SessionCount(10 * time.Minute)()
+			duration := gofeat.SessionDuration(10 * time.Minute)()
+			for _, off := range tt.offsets {
+				e := gofeat.Event{Timestamp: base.Add(off)}
+				count.Add(e)
+				duration.Add(e)
+			}
+			if got := count.Result(); got != tt.wantCount {
+				t.Errorf("SessionCount: got %v, want %d", got, tt.wantCount)
+			}
+			if got := duration.Result(); got != tt.wantDuration {
+				t.Errorf("SessionDuration: got %v, want %v", got, tt.wantDuration)
+			}
+
+			// Once more than gap has passed, the session has ended
+			end := base.Add(time.Hour)
+			if got := count.(gofeat.TimeAware).ResultAt(end); got != 0 {
+				t.Errorf("SessionCount after the session ended: got %v, want 0", got)
+			}
+			if got := duration.(gofeat.TimeAware).ResultAt(end); got != time.Duration(0) {
+				t.Errorf("SessionDuration after the session ended: got %v, want 0", got)
+			}
+		})
+	}
+}
+
+func TestStore_SessionFeatures(t *testing.T) {
+	store, err := gofeat.New(gofeat.Config{
+		Features: []gofeat.Feature{
+			{Name: "session_actions", Aggregate: gofeat.Count, Window: gofeat.Session(15 * time.Minute)},
+			{Name: "session_count", Aggregate: gofeat.SessionCount(15 * time.Minute)},
+			{Name: "session_length", Aggregate: gofeat.SessionDuration(15 * time.Minute)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, m := range []int{0, 10, 60, 70, 75} {
+		store.Push(ctx, "user1", gofeat.Event{Timestamp: base.Add(time.Duration(m) * time.Minute)})
+	}
+
+	result, _ := store.GetAt(ctx, "user1", base.Add(80*time.Minute))
+	if got := result.IntOr("session_actions", -1); got != 3 {
+		t.Errorf("session_actions: got %d, want 3", got)
+	}
+	if got := result.IntOr("session_count", -1); got != 3 {
+		t.Errorf("session_count: got %d, want 3", got)
+	}
+	if got := result.DurationOr("session_length", -1); got != 15*time.Minute {
+		t.Errorf("session_length: got %v, want 15m", got)
+	}
+
+	// Point in time: only the first session exists at 12:20
+	result, _ = store.GetAt(ctx, "user1", base.Add(20*time.Minute))
+	if got := result.IntOr("session_count", -1); got != 2 {
+		t.Errorf("session_count at 12:20: got %d, want 2", got)
+	}
+	if _, err := result.Duration("session_count"); err == nil {
+		t.Error("expected type error for Duration on int feature")
+	}
+
+	// The first session ended at 12:25
+	result, _ = store.GetAt(ctx, "user1", base.Add(30*time.Minute))
+	if got := result.IntOr("session_actions", -1); got != 0 {
+		t.Errorf("session_actions at 12:30: got %d, want 0", got)
+	}
+	if got := result.IntOr("session_count", -1); got != 0 {
+		t.Errorf("session_count at 12:30: got %d, want 0", got)
+	}
+	if got := result.DurationOr("session_length", -1); got != 0 {
+		t.Errorf("session_length at 12:30: got %v, want 0", got)
+	}
+}
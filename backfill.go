@@ -0,0 +1,140 @@
+package gofeat
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+)
+
+// DefaultBackfillBatchSize is the number of events Backfill buffers before
+// pushing them when BackfillOptions.BatchSize is not set.
+const DefaultBackfillBatchSize = 10000
+
+// BackfillOptions configure Store.Backfill.
+type BackfillOptions struct {
+	// BatchSize is the number of events buffered before they are pushed,
+	// DefaultBackfillBatchSize if zero. Larger batches mean fewer, larger
+	// sorted inserts per entity.
+	BatchSize int
+
+	// Progress, if set, is called after every pushed batch.
+	Progress func(BackfillStats)
+
+	// Reject, if set, is called for every rejected row.
+	Reject func(*RowError)
+}
+
+// BackfillStats counts the rows processed by Store.Backfill.
+type BackfillStats struct {
+	Events   int // events pushed
+	Rejected int // rows skipped as malformed
+}
+
+// errNoEntity rejects rows that cannot be stored anywhere.
+var errNoEntity = errors.New("missing entity ID")
+
+// Backfill reads all events from src and pushes them in batches. Events
+// with an entity ID are pushed for that entity like Push does; if entity
+// types are configured, every event is also ingested like Ingest does.
+// Rows without an entity ID are rejected unless entity types are configured.
+//
+// Malformed rows are counted, reported through opts.Reject and skipped.
+// Any other error stops the backfill; events of earlier batches stay stored.
+func (s *Store) Backfill(ctx context.Context, src EventSource, opts BackfillOptions) (BackfillStats, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBackfillBatchSize
+	}
+
+	var stats BackfillStats
+	b := backfillBatch{byEntity: make(map[string][]Event)}
+	for {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		se, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err == nil && se.EntityID == "" && len(s.entities) == 0 {
+			err = &RowError{Line: se.Line, Err: errNoEntity}
+		}
+		if err != nil {
+			var rowErr *RowError
+			if !errors.As(err, &rowErr) {
+				return stats, err
+			}
+			stats.Rejected++
+			if opts.Reject != nil {
+				opts.Reject(rowErr)
+			}
+			continue
+		}
+
+		b.add(se, len(s.entities) > 0)
+		if b.size < opts.BatchSize {
+			continue
+		}
+		if err := s.pushBackfill(ctx, &b); err != nil {
+			return stats, err
+		}
+		stats.Events += b.size
+		b.reset()
+		if opts.Progress != nil {
+			opts.Progress(stats)
+		}
+	}
+
+	if b.size > 0 {
+		if err := s.pushBackfill(ctx, &b); err != nil {
+			return stats, err
+		}
+		stats.Events += b.size
+		if opts.Progress != nil {
+			opts.Progress(stats)
+		}
+	}
+	return stats, nil
+}
+
+// backfillBatch buffers events grouped by entity, so that each entity gets
+// a single sorted insert per batch.
+type backfillBatch struct {
+	byEntity map[string][]Event
+	typed    []Event // events to ingest for entity types
+	size     int
+}
+
+func (b *backfillBatch) add(se SourceEvent, ingest bool) {
+	if se.EntityID != "" {
+		b.byEntity[se.EntityID] = append(b.byEntity[se.EntityID], se.Event)
+	}
+	if ingest {
+		b.typed = append(b.typed, se.Event)
+	}
+	b.size++
+}
+
+func (b *backfillBatch) reset() {
+	clear(b.byEntity)
+	b.typed = b.typed[:0]
+	b.size = 0
+}
+
+func (s *Store) pushBackfill(ctx context.Context, b *backfillBatch) error {
+	ids := make([]string, 0, len(b.byEntity))
+	for id := range b.byEntity {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if err := s.Push(ctx, id, b.byEntity[id]...); err != nil {
+			return err
+		}
+	}
+	if len(b.typed) > 0 {
+		return s.Ingest(ctx, b.typed...)
+	}
+	return nil
+}
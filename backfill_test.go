@@ -0,0 +1,150 @@
+package gofeat_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+func TestBackfill(t *testing.T) {
+	ctx := context.Background()
+	store, err := gofeat.New(gofeat.Config{
+		Entities: []gofeat.EntityType{{Name: "card", Field: "card"}},
+		Features: []gofeat.Feature{
+			{Name: "count_1h", Aggregate: gofeat.Count, Window: gofeat.Sliding(time.Hour)},
+			{Name: "sum", Aggregate: gofeat.Sum("amount")},
+			{Name: "card_count", Aggregate: gofeat.Count, Entity: "card"},
+		},
+		BucketSize: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	// Rows are out of order across batches; every fourth row is malformed
+	var b strings.Builder
+	b.WriteString("entity_id,timestamp,amount,card\n")
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := range 40 {
+		if i%4 == 3 {
+			b.WriteString("u1,not a time,1,c1\n")
+			continue
+		}
+		ts := base.Add(-time.Duration((i*7)%40) * time.Minute)
+		fmt.Fprintf(&b, "u%d,%s,%d,c%d\n", i%2, ts.Format(time.RFC3339), i, i%3)
+	}
+
+	var progress []gofeat.BackfillStats
+	var rejected []int
+	stats, err := store.Backfill(ctx, gofeat.NewCSVEventReader(strings.NewReader(b.String()), gofeat.EventReaderOptions{}),
+		gofeat.BackfillOptions{
+			BatchSize: 8,
+			Progress:  func(s gofeat.BackfillStats) { progress = append(progress, s) },
+			Reject:    func(err *gofeat.RowError) { rejected = append(rejected, err.Line) },
+		})
+	if err != nil {
+		t.Fatalf("Backfill failed: %v", err)
+	}
+
+	if stats != (gofeat.BackfillStats{Events: 30, Rejected: 10}) {
+		t.Errorf("stats got %+v", stats)
+	}
+	if len(rejected) != 10 || rejected[0] != 5 {
+		t.Errorf("rejected lines got %v", rejected)
+	}
+	if len(progress) != 4 || progress[0].Events != 8 || progress[3] != stats {
+		t.Errorf("progress got %+v", progress)
+	}
+
+	// All events are within the hour before base
+	sums, counts := map[string]float64{}, map[string]int{}
+	for i := range 40 {
+		if i%4 != 3 {
+			sums[fmt.Sprintf("u%d", i%2)] += float64(i)
+			counts[fmt.Sprintf("u%d", i%2)]++
+		}
+	}
+	for id, sum := range sums {
+		result, err := store.GetAt(ctx, id, base)
+		if err != nil {
+			t.Fatalf("GetAt failed: %v", err)
+		}
+		if got := result.FloatOr("sum", -1); got != sum {
+			t.Errorf("%s: sum got %v, want %v", id, got, sum)
+		}
+		if got := result.IntOr("count_1h", -1); got != counts[id] {
+			t.Errorf("%s: count_1h got %d, want %d", id, got, counts[id])
+		}
+	}
+
+	result, err := store.GetEntityAt(ctx, "card", "c0", base)
+	if err != nil {
+		t.Fatalf("GetEntityAt failed: %v", err)
+	}
+	if got := result.IntOr("card_count", -1); got != 10 {
+		t.Errorf("card_count got %d, want 10", got)
+	}
+}
+
+func TestBackfill_RejectsRowsWithoutEntity(t *testing.T) {
+	store, err := gofeat.New(gofeat.Config{Features: []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count}}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	input := `{"entity_id": "u1", "timestamp": "2024-01-01T12:00:00Z"}
+{"timestamp": "2024-01-01T12:00:00Z"}
+`
+	var reasons []error
+	stats, err := store.Backfill(context.Background(), gofeat.NewJSONLEventReader(strings.NewReader(input), gofeat.EventReaderOptions{}),
+		gofeat.BackfillOptions{Reject: func(err *gofeat.RowError) { reasons = append(reasons, err) }})
+	if err != nil {
+		t.Fatalf("Backfill failed: %v", err)
+	}
+	if stats != (gofeat.BackfillStats{Events: 1, Rejected: 1}) {
+		t.Errorf("stats got %+v", stats)
+	}
+	if len(reasons) != 1 || !strings.Contains(reasons[0].Error(), "line 2: missing entity ID") {
+		t.Errorf("reasons got %v", reasons)
+	}
+}
+
+type failingSource struct{ n int }
+
+var errSourceFailed = errors.New("read failed")
+
+func (f *failingSource) Next() (gofeat.SourceEvent, error) {
+	f.n++
+	if f.n > 3 {
+		return gofeat.SourceEvent{}, errSourceFailed
+	}
+	return gofeat.SourceEvent{
+		EntityID: "u1",
+		Event:    gofeat.Event{Timestamp: time.Date(2024, 1, 1, 12, f.n, 0, 0, time.UTC)},
+	}, nil
+}
+
+func TestBackfill_SourceError(t *testing.T) {
+	ctx := context.Background()
+	store, err := gofeat.New(gofeat.Config{Features: []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count}}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	stats, err := store.Backfill(ctx, &failingSource{}, gofeat.BackfillOptions{BatchSize: 2})
+	if !errors.Is(err, errSourceFailed) {
+		t.Fatalf("got %v, want source error", err)
+	}
+	// The first batch was pushed before the error
+	if stats.Events != 2 {
+		t.Errorf("events got %d, want 2", stats.Events)
+	}
+	result, _ := store.GetAt(ctx, "u1", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))
+	if got := result.IntOr("count", -1); got != 2 {
+		t.Errorf("count got %d, want 2", got)
+	}
+}
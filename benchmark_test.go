@@ -110,6 +110,35 @@ func BenchmarkStore_GetAt(b *testing.B) {
 	}
 }
 
+func BenchmarkStore_GetAt_Incremental(b *testing.B) {
+	store, _ := gofeat.New(gofeat.Config{
+		BucketSize: time.Minute,
+		Features: []gofeat.Feature{
+			{Name: "count", Aggregate: gofeat.Count, Window: gofeat.Sliding(24 * time.Hour)},
+			{Name: "sum", Aggregate: gofeat.Sum("amount"), Window: gofeat.Sliding(24 * time.Hour)},
+		},
+	})
+	defer store.Close()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	// Prepopulate a day of events, one every 2 seconds
+	events := make([]gofeat.Event, 43200)
+	for i := range events {
+		events[i] = gofeat.Event{
+			Timestamp: now.Add(-time.Duration(i) * 2 * time.Second),
+			Data:      map[string]any{"amount": 100.0},
+		}
+	}
+	store.Push(ctx, "user1", events...)
+
+	b.ResetTimer()
+	for range b.N {
+		store.GetAt(ctx, "user1", now)
+	}
+}
+
 func BenchmarkStore_BatchGet(b *testing.B) {
 	store, _ := gofeat.New(gofeat.Config{
 		Features: []gofeat.Feature{
@@ -333,3 +362,17 @@ func BenchmarkStore_MixedReadWrite(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkApproxTopK_Add(b *testing.B) {
+	// Mostly unseen values, so that nearly every Add replaces a counter
+	agg := gofeat.ApproxTopK("ip", 10, 1000)()
+	events := make([]gofeat.Event, 10000)
+	for i := range events {
+		events[i] = gofeat.Event{Data: map[string]any{"ip": i}}
+	}
+
+	b.ResetTimer()
+	for i := range b.N {
+		agg.Add(events[i%len(events)])
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+type options struct {
+	spec        string
+	events      string
+	format      string
+	snapshot    string
+	entityField string
+	timeField   string
+	at          string
+	entityType  string
+	save        string
+
+	stderr io.Writer
+}
+
+func (o *options) flags(cmd string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("gofeat "+cmd, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&o.spec, "spec", "", "feature spec (JSON, see gofeat.LoadConfig)")
+	fs.StringVar(&o.events, "events", "", "events file (.jsonl or .csv)")
+	fs.StringVar(&o.format, "format", "", "events format: jsonl or csv (default: from the file extension)")
+	fs.StringVar(&o.snapshot, "snapshot", "", "snapshot to restore before replaying events")
+	fs.StringVar(&o.entityField, "entity-field", "entity_id", "field holding the entity ID")
+	fs.StringVar(&o.timeField, "time-field", "timestamp", "field holding the event time")
+
+	switch cmd {
+	case "replay":
+		fs.StringVar(&o.save, "save", "", "write a snapshot of the loaded events to this file")
+	case "get":
+		fs.StringVar(&o.at, "at", "", "point in time (RFC 3339, default: now)")
+		fs.StringVar(&o.entityType, "type", "", "entity type declared in the spec")
+	case "timeline":
+		fs.StringVar(&o.entityType, "type", "", "entity type declared in the spec")
+	}
+	return fs
+}
+
+// loaded is a store built from the spec with the events replayed into it.
+type loaded struct {
+	store    *gofeat.Store
+	cfg      gofeat.Config
+	events   int
+	rejected int
+	entities map[string]struct{}
+	records  []gofeat.SourceEvent // events of the entity passed to load
+}
+
+// load builds a store from opts. If entity is set, the records of that
+// entity (of opts.entityType, if set) are kept in loaded.records. The
+// caller closes the store with loaded.close.
+func load(ctx context.Context, opts *options, entity string) (_ *loaded, err error) {
+	if opts.spec == "" {
+		return nil, errors.New("-spec required")
+	}
+	if opts.events == "" && opts.snapshot == "" {
+		return nil, errors.New("-events or -snapshot required")
+	}
+
+	f, err := os.Open(opts.spec)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cfg, err := gofeat.LoadConfig(f)
+	if err != nil {
+		return nil, err
+	}
+	store, err := gofeat.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, store.Close())
+		}
+	}()
+	l := &loaded{store: store, cfg: cfg, entities: make(map[string]struct{})}
+
+	var keep func(gofeat.SourceEvent) bool
+	switch {
+	case entity == "":
+	case opts.entityType == "":
+		keep = func(se gofeat.SourceEvent) bool { return se.EntityID == entity }
+	default:
+		idx := slices.IndexFunc(cfg.Entities, func(t gofeat.EntityType) bool { return t.Name == opts.entityType })
+		if idx < 0 {
+			return nil, fmt.Errorf("%w %q", gofeat.ErrUnknownEntityType, opts.entityType)
+		}
+		t := cfg.Entities[idx]
+		keep = func(se gofeat.SourceEvent) bool {
+			key, ok := t.KeyFor(se.Event)
+			return ok && key == entity
+		}
+	}
+
+	if opts.snapshot != "" {
+		if err := restore(ctx, store, opts.snapshot); err != nil {
+			return nil, err
+		}
+	}
+	if opts.events != "" {
+		if err := l.replay(ctx, opts, keep); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// close closes the store, joining its error into *err.
+func (l *loaded) close(err *error) {
+	*err = errors.Join(*err, l.store.Close())
+}
+
+func restore(ctx context.Context, store *gofeat.Store, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return store.Restore(ctx, f)
+}
+
+func (l *loaded) replay(ctx context.Context, opts *options, keep func(gofeat.SourceEvent) bool) error {
+	format := opts.format
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(opts.events)), ".")
+	}
+	f, err := os.Open(opts.events)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ro := gofeat.EventReaderOptions{EntityField: opts.entityField, TimeField: opts.timeField}
+	var src gofeat.EventSource
+	switch format {
+	case "jsonl":
+		src = gofeat.NewJSONLEventReader(f, ro)
+	case "csv":
+		src = gofeat.NewCSVEventReader(f, ro)
+	default:
+		return fmt.Errorf("unknown events format %q", format)
+	}
+
+	stats, err := l.store.Backfill(ctx, tap{src: src, fn: func(se gofeat.SourceEvent) {
+		if se.EntityID != "" {
+			l.entities[se.EntityID] = struct{}{}
+		}
+		if keep != nil && keep(se) {
+			l.records = append(l.records, se)
+		}
+	}}, gofeat.BackfillOptions{
+		Reject: func(err *gofeat.RowError) {
+			fmt.Fprintf(opts.stderr, "%s:%d: %v\n", opts.events, err.Line, err.Err)
+		},
+	})
+	l.events, l.rejected = stats.Events, stats.Rejected
+	return err
+}
+
+// tap passes the events read from src to fn.
+type tap struct {
+	src gofeat.EventSource
+	fn  func(gofeat.SourceEvent)
+}
+
+func (t tap) Next() (gofeat.SourceEvent, error) {
+	se, err := t.src.Next()
+	if err == nil {
+		t.fn(se)
+	}
+	return se, err
+}
+
+func runReplay(opts *options, args []string, stdout io.Writer) (err error) {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments %v", args)
+	}
+	ctx := context.Background()
+	l, err := load(ctx, opts, "")
+	if err != nil {
+		return err
+	}
+	defer l.close(&err)
+	fmt.Fprintf(stdout, "replayed %d events for %d entities, %d rejected\n", l.events, len(l.entities), l.rejected)
+
+	if opts.save == "" {
+		return nil
+	}
+	f, err := os.Create(opts.save)
+	if err != nil {
+		return err
+	}
+	if err := l.store.Snapshot(ctx, f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "snapshot written to %s\n", opts.save)
+	return nil
+}
+
+func runGet(opts *options, args []string, stdout io.Writer) (err error) {
+	if len(args) != 1 {
+		return errors.New("usage: gofeat get [flags] <entity>")
+	}
+	at := time.Now().UTC()
+	if opts.at != "" {
+		t, err := time.Parse(time.RFC3339Nano, opts.at)
+		if err != nil {
+			return fmt.Errorf("invalid -at: %w", err)
+		}
+		at = t.UTC()
+	}
+
+	ctx := context.Background()
+	l, err := load(ctx, opts, "")
+	if err != nil {
+		return err
+	}
+	defer l.close(&err)
+	result, err := getAt(ctx, l.store, opts.entityType, args[0], at)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]any, len(result.All()))
+	for name, v := range result.All() {
+		values[name] = displayValue(v)
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(values)
+}
+
+func runTimeline(opts *options, args []string, stdout io.Writer) (err error) {
+	if len(args) != 1 {
+		return errors.New("usage: gofeat timeline [flags] <entity>")
+	}
+	if opts.events == "" {
+		return errors.New("timeline requires -events")
+	}
+	entity := args[0]
+
+	ctx := context.Background()
+	l, err := load(ctx, opts, entity)
+	if err != nil {
+		return err
+	}
+	defer l.close(&err)
+	records := l.records
+	if len(records) == 0 {
+		return fmt.Errorf("no events for entity %q", entity)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Event.Timestamp.Before(records[j].Event.Timestamp)
+	})
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	var names []string
+	for i, se := range records {
+		ts := se.Event.Timestamp
+		// Events sharing a timestamp are visible together, so they get one row
+		if i+1 < len(records) && records[i+1].Event.Timestamp.Equal(ts) {
+			continue
+		}
+		result, err := getAt(ctx, l.store, opts.entityType, entity, ts)
+		if err != nil {
+			return err
+		}
+		if names == nil {
+			for name := range result.All() {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			fmt.Fprintf(tw, "timestamp\t%s\n", strings.Join(names, "\t"))
+		}
+
+		row := []string{ts.Format(time.RFC3339Nano)}
+		for _, name := range names {
+			v, _ := result.Any(name)
+			row = append(row, fmt.Sprint(displayValue(v)))
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func runStats(opts *options, args []string, stdout io.Writer) (err error) {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments %v", args)
+	}
+	ctx := context.Background()
+	l, err := load(ctx, opts, "")
+	if err != nil {
+		return err
+	}
+	defer l.close(&err)
+	stats, err := l.store.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "entities: %d\nevents:   %d\n", stats.Entities, stats.TotalEvents)
+	return nil
+}
+
+func getAt(ctx context.Context, store *gofeat.Store, entityType, entity string, at time.Time) (gofeat.Result, error) {
+	if entityType == "" {
+		return store.GetAt(ctx, entity, at)
+	}
+	return store.GetEntityAt(ctx, entityType, entity, at)
+}
+
+// displayValue makes feature values readable and JSON-encodable.
+func displayValue(v any) any {
+	switch n := v.(type) {
+	case time.Duration:
+		return n.String()
+	case float64:
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return fmt.Sprint(n)
+		}
+	}
+	return v
+}
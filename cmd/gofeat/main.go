@@ -0,0 +1,95 @@
+// Command gofeat replays event files through a feature spec to reproduce
+// feature values offline.
+//
+// Usage:
+//
+//	gofeat replay   -spec features.json -events events.jsonl [-save state.snap]
+//	gofeat get      -spec features.json -events events.jsonl [-at time] [-type entity_type] <entity>
+//	gofeat timeline -spec features.json -events events.jsonl [-type entity_type] <entity>
+//	gofeat stats    -spec features.json -events events.jsonl
+//
+// The spec is read with gofeat.LoadConfig. Events are flat JSONL objects or
+// CSV rows: the -entity-field column holds the entity ID, the -time-field
+// column an RFC 3339 timestamp or Unix seconds, and all other columns become
+// event data. Events are pushed under their entity ID and, if the spec
+// declares entity types, ingested for those as well. A snapshot saved by
+// replay can be loaded with -snapshot instead of replaying the events again.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "gofeat:", err)
+		}
+		os.Exit(2)
+	}
+}
+
+const usage = `usage: gofeat <command> [flags] [entity]
+
+commands:
+  replay     load events into a store and report what was loaded
+  get        print the features of an entity
+  timeline   print the features of an entity after each of its events
+  stats      print storage statistics
+
+run "gofeat <command> -h" for the flags of a command
+`
+
+func run(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return errors.New("command required")
+	}
+
+	var cmd func(*options, []string, io.Writer) error
+	switch args[0] {
+	case "replay":
+		cmd = runReplay
+	case "get":
+		cmd = runGet
+	case "timeline":
+		cmd = runTimeline
+	case "stats":
+		cmd = runStats
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	default:
+		fmt.Fprint(stderr, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	opts := &options{stderr: stderr}
+	fs := opts.flags(args[0], stderr)
+	positional, err := parseInterspersed(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	return cmd(opts, positional, stdout)
+}
+
+// parseInterspersed parses flags that may follow positional arguments,
+// e.g. "get user1 -at 2024-01-01T00:00:00Z".
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
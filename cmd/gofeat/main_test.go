@@ -0,0 +1,221 @@
+package main //nolint:testpackage // package main cannot be imported
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSpec = `{
+	"entities": [{"name": "card", "field": "card"}],
+	"features": [
+		{"name": "count", "aggregate": "count"},
+		{"name": "sum_amount", "aggregate": {"kind": "sum", "field": "amount"}},
+		{"name": "card_count", "aggregate": "count", "entity": "card"}
+	],
+	"derived": [{"name": "avg", "expr": "sum_amount / count"}]
+}`
+
+const testJSONL = `{"entity_id": "u1", "timestamp": "2024-01-01T10:00:00Z", "amount": 10, "card": "4111"}
+{"entity_id": "u1", "timestamp": "2024-01-01T11:00:00Z", "amount": 30, "card": "4111"}
+{"entity_id": "u2", "timestamp": 1704106800, "amount": 5, "card": "5500"}
+{"entity_id": "u1", "timestamp": "2024-01-01T12:00:00Z", "amount": 20, "card": "5500"}
+not json
+{"entity_id": "u2", "amount": 1}
+`
+
+const testCSV = `entity_id,timestamp,amount,card
+u1,2024-01-01T10:00:00Z,10,4111
+u1,2024-01-01T11:00:00Z,30,4111
+u2,1704106800,5,5500
+u1,2024-01-01T12:00:00Z,20,5500
+u2,2024-01-01T13:00:00Z
+`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runCmd(t *testing.T, args ...string) (stdout, stderr string, err error) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	err = run(args, &out, &errOut)
+	return out.String(), errOut.String(), err
+}
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	spec := writeFile(t, dir, "spec.json", testSpec)
+
+	tests := []struct {
+		name     string
+		content  string
+		rejected int
+	}{
+		{name: "events.jsonl", content: testJSONL, rejected: 2},
+		{name: "events.csv", content: testCSV, rejected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := writeFile(t, dir, tt.name, tt.content)
+
+			stdout, stderr, err := runCmd(t, "replay", "-spec", spec, "-events", events)
+			if err != nil {
+				t.Fatalf("replay failed: %v", err)
+			}
+			if want := fmt.Sprintf("replayed 4 events for 2 entities, %d rejected\n", tt.rejected); stdout != want {
+				t.Errorf("stdout got %q, want %q", stdout, want)
+			}
+			if lines := strings.Count(stderr, "\n"); lines != tt.rejected || !strings.Contains(stderr, tt.name+":") {
+				t.Errorf("expected %d rejects with file and line, got %q", tt.rejected, stderr)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	dir := t.TempDir()
+	spec := writeFile(t, dir, "spec.json", testSpec)
+	events := writeFile(t, dir, "events.jsonl", testJSONL)
+
+	tests := []struct {
+		name string
+		args []string
+		want map[string]any
+	}{
+		{
+			name: "at end",
+			args: []string{"u1", "-at", "2024-01-02T00:00:00Z"},
+			want: map[string]any{"count": 3.0, "sum_amount": 60.0, "avg": 20.0},
+		},
+		{
+			name: "as of earlier time",
+			args: []string{"-at", "2024-01-01T11:00:00Z", "u1"},
+			want: map[string]any{"count": 2.0, "sum_amount": 40.0, "avg": 20.0},
+		},
+		{
+			name: "entity type",
+			args: []string{"5500", "-type", "card", "-at", "2024-01-02T00:00:00Z"},
+			want: map[string]any{"card_count": 2.0},
+		},
+		{
+			name: "no events",
+			args: []string{"nobody", "-at", "2024-01-02T00:00:00Z"},
+			want: map[string]any{"count": 0.0, "avg": "NaN"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"get", "-spec", spec, "-events", events}, tt.args...)
+			stdout, _, err := runCmd(t, args...)
+			if err != nil {
+				t.Fatalf("get failed: %v", err)
+			}
+			var got map[string]any
+			if err := json.Unmarshal([]byte(stdout), &got); err != nil {
+				t.Fatalf("invalid JSON %q: %v", stdout, err)
+			}
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("%s: got %v, want %v", name, got[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	dir := t.TempDir()
+	spec := writeFile(t, dir, "spec.json", testSpec)
+	events := writeFile(t, dir, "events.csv", testCSV)
+
+	stdout, _, err := runCmd(t, "timeline", "-spec", spec, "-events", events, "u1")
+	if err != nil {
+		t.Fatalf("timeline failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected header and 3 rows, got %q", stdout)
+	}
+	if got := strings.Fields(lines[0]); strings.Join(got, " ") != "timestamp avg count sum_amount" {
+		t.Errorf("header got %q", lines[0])
+	}
+	for i, want := range []string{
+		"2024-01-01T10:00:00Z 10 1 10",
+		"2024-01-01T11:00:00Z 20 2 40",
+		"2024-01-01T12:00:00Z 20 3 60",
+	} {
+		if got := strings.Join(strings.Fields(lines[i+1]), " "); got != want {
+			t.Errorf("row %d got %q, want %q", i+1, got, want)
+		}
+	}
+
+	stdout, _, err = runCmd(t, "timeline", "-spec", spec, "-events", events, "-type", "card", "5500")
+	if err != nil {
+		t.Fatalf("typed timeline failed: %v", err)
+	}
+	if rows := strings.Count(stdout, "\n"); rows != 3 {
+		t.Errorf("expected header and 2 rows for card 5500, got %q", stdout)
+	}
+}
+
+func TestSnapshotAndStats(t *testing.T) {
+	dir := t.TempDir()
+	spec := writeFile(t, dir, "spec.json", testSpec)
+	events := writeFile(t, dir, "events.jsonl", testJSONL)
+	snap := filepath.Join(dir, "state.snap")
+
+	if _, _, err := runCmd(t, "replay", "-spec", spec, "-events", events, "-save", snap); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	stdout, _, err := runCmd(t, "stats", "-spec", spec, "-snapshot", snap)
+	if err != nil {
+		t.Fatalf("stats failed: %v", err)
+	}
+	// Two users and two cards
+	if want := "entities: 4\nevents:   8\n"; stdout != want {
+		t.Errorf("stats got %q, want %q", stdout, want)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	dir := t.TempDir()
+	spec := writeFile(t, dir, "spec.json", testSpec)
+	events := writeFile(t, dir, "events.jsonl", testJSONL)
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "no command", args: nil, want: "command required"},
+		{name: "unknown command", args: []string{"score"}, want: "unknown command"},
+		{name: "missing spec", args: []string{"stats", "-events", events}, want: "-spec required"},
+		{name: "missing events", args: []string{"stats", "-spec", spec}, want: "-events or -snapshot required"},
+		{name: "get without entity", args: []string{"get", "-spec", spec, "-events", events}, want: "usage"},
+		{name: "invalid at", args: []string{"get", "-spec", spec, "-events", events, "-at", "now", "u1"}, want: "invalid -at"},
+		{name: "unknown type", args: []string{"get", "-spec", spec, "-events", events, "-type", "device", "d1"}, want: "unknown entity type"},
+		{name: "unknown format", args: []string{"stats", "-spec", spec, "-events", events, "-format", "xml"}, want: "unknown events format"},
+		{name: "timeline without events", args: []string{"timeline", "-spec", spec, "-events", events, "nobody"}, want: "no events"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := runCmd(t, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package gofeat
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Value type tags used by the binary event encoding.
+const (
+	tagNil byte = iota
+	tagFloat64
+	tagFloat32
+	tagInt
+	tagInt64
+	tagInt32
+	tagString
+	tagBool
+	tagTime
+	tagDuration
+)
+
+var errShortBuffer = errors.New("gofeat: unexpected end of data")
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendFloat64(b []byte, f float64) []byte {
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(f))
+}
+
+func appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
+// appendTimestamp encodes t as seconds and nanoseconds since the Unix epoch.
+// The location is not preserved; decoded timestamps are always UTC.
+func appendTimestamp(b []byte, t time.Time) []byte {
+	b = binary.AppendVarint(b, t.Unix())
+	return binary.AppendUvarint(b, uint64(t.Nanosecond()))
+}
+
+// appendValue encodes a single Event.Data value prefixed with its type tag.
+func appendValue(b []byte, v any) ([]byte, error) {
+	switch n := v.(type) {
+	case nil:
+		return append(b, tagNil), nil
+	case float64:
+		return appendFloat64(append(b, tagFloat64), n), nil
+	case float32:
+		b = append(b, tagFloat32)
+		return binary.LittleEndian.AppendUint32(b, math.Float32bits(n)), nil
+	case int:
+		return binary.AppendVarint(append(b, tagInt), int64(n)), nil
+	case int64:
+		return binary.AppendVarint(append(b, tagInt64), n), nil
+	case int32:
+		return binary.AppendVarint(append(b, tagInt32), int64(n)), nil
+	case string:
+		return appendString(append(b, tagString), n), nil
+	case bool:
+		return appendBool(append(b, tagBool), n), nil
+	case time.Time:
+		data, err := n.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		b = binary.AppendUvarint(append(b, tagTime), uint64(len(data)))
+		return append(b, data...), nil
+	case time.Duration:
+		return binary.AppendVarint(append(b, tagDuration), int64(n)), nil
+	}
+	return nil, fmt.Errorf("gofeat: unsupported value type %T", v)
+}
+
+// appendEvent encodes an event. Data keys are written in sorted order so the
+// same event always produces the same bytes.
+func appendEvent(b []byte, e Event) ([]byte, error) {
+	b = appendTimestamp(b, e.Timestamp)
+	b = binary.AppendUvarint(b, uint64(len(e.Data)))
+
+	keys := make([]string, 0, len(e.Data))
+	for k := range e.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var err error
+	for _, k := range keys {
+		b = appendString(b, k)
+		b, err = appendValue(b, e.Data[k])
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", k, err)
+		}
+	}
+	return b, nil
+}
+
+// decoder reads values written by the append* helpers. The first error is
+// sticky: once set, every subsequent read returns a zero value.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.fail(errShortBuffer)
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.fail(errShortBuffer)
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) bytes(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if uint64(len(d.b)) < n {
+		d.fail(errShortBuffer)
+		return nil
+	}
+	out := d.b[:n]
+	d.b = d.b[n:]
+	return out
+}
+
+func (d *decoder) byte() byte {
+	p := d.bytes(1)
+	if p == nil {
+		return 0
+	}
+	return p[0]
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes(d.uvarint()))
+}
+
+func (d *decoder) float64() float64 {
+	p := d.bytes(8)
+	if p == nil {
+		return 0
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(p))
+}
+
+func (d *decoder) bool() bool {
+	return d.byte() != 0
+}
+
+// length reads a collection length and rejects values that cannot fit in
+// the remaining input (every element takes at least one byte).
+func (d *decoder) length() int {
+	n := d.uvarint()
+	if n > uint64(len(d.b)) {
+		d.fail(errShortBuffer)
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) timestamp() time.Time {
+	sec := d.varint()
+	nsec := d.uvarint()
+	if d.err != nil {
+		return time.Time{}
+	}
+	return time.Unix(sec, int64(nsec)).UTC()
+}
+
+func (d *decoder) value() any {
+	switch tag := d.byte(); tag {
+	case tagNil:
+		return nil
+	case tagFloat64:
+		return d.float64()
+	case tagFloat32:
+		p := d.bytes(4)
+		if p == nil {
+			return nil
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(p))
+	case tagInt:
+		return int(d.varint())
+	case tagInt64:
+		return d.varint()
+	case tagInt32:
+		return int32(d.varint()) //nolint:gosec // written from an int32
+	case tagString:
+		return d.string()
+	case tagBool:
+		return d.bool()
+	case tagTime:
+		var t time.Time
+		if p := d.bytes(d.uvarint()); p != nil {
+			if err := t.UnmarshalBinary(p); err != nil {
+				d.fail(err)
+			}
+		}
+		return t
+	case tagDuration:
+		return time.Duration(d.varint())
+	default:
+		d.fail(fmt.Errorf("gofeat: unknown value tag %d", tag))
+		return nil
+	}
+}
+
+func (d *decoder) event() Event {
+	e := Event{Timestamp: d.timestamp()}
+	n := d.length()
+	if d.err != nil {
+		return e
+	}
+	e.Data = make(map[string]any, n)
+	for range n {
+		k := d.string()
+		v := d.value()
+		if d.err != nil {
+			return e
+		}
+		e.Data[k] = v
+	}
+	return e
+}
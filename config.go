@@ -4,13 +4,56 @@ import "time"
 
 type Config struct {
 	Features []Feature
-	Storage  Storage       // optional, defaults to in-memory with no TTL
-	TTL      time.Duration // Used only if Storage is not provided
+	Derived  []DerivedFeature // evaluated after Features, in dependency order
+	Entities []EntityType     // entity types events are fanned out to by Store.Ingest
+	Storage  Storage          // optional, defaults to in-memory with no TTL
+	TTL      time.Duration    // Used only if Storage is not provided
+	Metrics  *Metrics         // optional, collects latencies and counters, see NewMetrics
+	Observer Observer         // optional, receives callbacks for tracing or logging
+
+	// BucketSize enables incremental aggregation when positive. Features
+	// with a Sliding, Between or Lifetime window and an aggregator
+	// implementing Merger keep partial states in buckets of this width,
+	// updated on Push. GetAt then merges whole buckets and replays only the
+	// events of the two partial edge buckets, so results are exact at any
+	// point in time. Buckets are seeded from the events Storage returns at
+	// the current time when an entity is first accessed; ranges where they
+	// disagree with Storage, e.g. events older than the TTL at that time or
+	// written to Storage directly, fall back to replaying the raw events.
+	// GetAt still reads the events of the entity from Storage for the edges
+	// and that check; buckets save the aggregation, not the read. Evict
+	// drops buckets older than the TTL of a TTLStorage.
+	BucketSize time.Duration
 }
 
 // Feature defines a single feature computation.
 type Feature struct {
 	Name      string
 	Aggregate AggregatorFactory
-	Window    Window // nil for Lifetime
+	Window    Window    // nil for Lifetime
+	Filter    Predicate // optional, events not matching are ignored before the window is applied
+	Entity    string    // entity type from Config.Entities, empty for features used by Push and Get
+}
+
+// newAggregator creates an aggregator for the feature. Filter is applied
+// through Where, which is equivalent to filtering before time-based windows.
+func (f Feature) newAggregator() Aggregator {
+	if f.Filter != nil {
+		return &filterAgg{pred: f.Filter, inner: f.Aggregate()}
+	}
+	return f.Aggregate()
+}
+
+// DerivedFeature computes a value from other features, e.g.
+// "declined_1h / max(tx_count_1h, 1)". Expr may reference Features and
+// other derived features by name and supports arithmetic (+ - * / %),
+// comparisons (== != < <= > >=), logical operators (&& || !), the
+// conditional "cond ? a : b" and the functions min, max, abs, log, sqrt
+// and if(cond, a, b). Values are float64: bool features become 1 or 0,
+// time.Duration features become seconds, nil becomes 0 and comparisons
+// yield 1 or 0. The result is a float64.
+type DerivedFeature struct {
+	Name   string
+	Expr   string
+	Entity string // entity type, may only reference features of the same type
 }
@@ -0,0 +1,109 @@
+package gofeat
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type derivedFeature struct {
+	name string
+	expr expr
+}
+
+// compileDerived parses the derived features and orders them so that every
+// feature is evaluated after the derived features it references.
+func compileDerived(features []Feature, derived []DerivedFeature) ([]derivedFeature, error) {
+	if len(derived) == 0 {
+		return nil, nil
+	}
+
+	base := make(map[string]struct{}, len(features))
+	for _, f := range features {
+		base[f.Name] = struct{}{}
+	}
+
+	type node struct {
+		expr expr
+		refs []string
+	}
+	nodes := make(map[string]node, len(derived))
+	for _, d := range derived {
+		if d.Name == "" {
+			return nil, errors.New("gofeat: derived feature name required")
+		}
+		if _, ok := base[d.Name]; ok {
+			return nil, fmt.Errorf("gofeat: derived feature %q: name already used by a feature", d.Name)
+		}
+		if _, ok := nodes[d.Name]; ok {
+			return nil, fmt.Errorf("gofeat: duplicate derived feature %q", d.Name)
+		}
+		e, refs, err := parseExpr(d.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("gofeat: derived feature %q: %w", d.Name, err)
+		}
+		sort.Strings(refs)
+		nodes[d.Name] = node{expr: e, refs: refs}
+	}
+
+	ordered := make([]derivedFeature, 0, len(derived))
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(derived))
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			i := 0
+			for path[i] != name {
+				i++
+			}
+			cycle := append(path[i:len(path):len(path)], name)
+			return fmt.Errorf("gofeat: derived feature cycle: %s", strings.Join(cycle, " -> "))
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		n := nodes[name]
+		for _, ref := range n.refs {
+			if _, ok := base[ref]; ok {
+				continue
+			}
+			if _, ok := nodes[ref]; !ok {
+				return fmt.Errorf("gofeat: derived feature %q: unknown feature %q", name, ref)
+			}
+			if err := visit(ref); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		ordered = append(ordered, derivedFeature{name: name, expr: n.expr})
+		return nil
+	}
+
+	for _, d := range derived {
+		if err := visit(d.Name); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// evalDerived computes derived features in order and adds them to values.
+func evalDerived(derived []derivedFeature, values map[string]any) error {
+	for _, d := range derived {
+		v, err := d.expr.eval(values)
+		if err != nil {
+			return fmt.Errorf("gofeat: derived feature %q: %w", d.name, err)
+		}
+		values[d.name] = v
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package gofeat_test
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+func derivedTestStore(t *testing.T, derived ...gofeat.DerivedFeature) *gofeat.Store {
+	t.Helper()
+	store, err := gofeat.New(gofeat.Config{
+		Features: []gofeat.Feature{
+			{Name: "tx_count", Aggregate: gofeat.Count},
+			{Name: "amount_sum", Aggregate: gofeat.Sum("amount")},
+			{Name: "amount_max", Aggregate: gofeat.Max("amount")},
+			{Name: "last_country", Aggregate: gofeat.Last("country")},
+			{Name: "age", Aggregate: gofeat.TimeSinceFirst()},
+		},
+		Derived: derived,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return store
+}
+
+func TestStore_Derived(t *testing.T) {
+	tests := []struct {
+		expr string
+		want float64
+	}{
+		{expr: "amount_sum / tx_count", want: 50},
+		{expr: "amount_max - amount_sum / tx_count * 2", want: -10},
+		{expr: "(amount_max - 10) % 7", want: 3},
+		{expr: "-amount_max + 100", want: 10},
+		{expr: "tx_count >= 3", want: 1},
+		{expr: "tx_count > 3 || amount_max == 90", want: 1},
+		{expr: "tx_count > 3 && amount_max == 90", want: 0},
+		{expr: "!(tx_count != 3)", want: 1},
+		{expr: "tx_count > 5 ? 1 : amount_max > 50 ? 2 : 3", want: 2},
+		{expr: "if(tx_count < 1, 0, amount_sum)", want: 150},
+		{expr: "min(amount_max, 10, tx_count)", want: 3},
+		{expr: "max(amount_max, 1e3)", want: 1000},
+		{expr: "abs(10 - amount_max)", want: 80},
+		{expr: "log(1)", want: 0},
+		{expr: "sqrt(amount_sum - 6)", want: 12},
+		{expr: "age / 60", want: 20},
+		{expr: "true + false + 0.5", want: 1.5},
+	}
+
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []gofeat.Event{
+		{Timestamp: now.Add(-30 * time.Minute), Data: map[string]any{"amount": 10.0, "country": "US"}},
+		{Timestamp: now.Add(-20 * time.Minute), Data: map[string]any{"amount": 50.0, "country": "DE"}},
+		{Timestamp: now.Add(-10 * time.Minute), Data: map[string]any{"amount": 90.0, "country": "FR"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			store := derivedTestStore(t, gofeat.DerivedFeature{Name: "derived", Expr: tt.expr})
+			store.Push(ctx, "user1", events...)
+
+			result, err := store.GetAt(ctx, "user1", now)
+			if err != nil {
+				t.Fatalf("GetAt failed: %v", err)
+			}
+			got, err := result.Float("derived")
+			if err != nil {
+				t.Fatalf("Float failed: %v", err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_DerivedDependencies(t *testing.T) {
+	ctx := context.Background()
+	// Declared before its dependency on purpose
+	store := derivedTestStore(t,
+		gofeat.DerivedFeature{Name: "avg_x2", Expr: "avg * 2"},
+		gofeat.DerivedFeature{Name: "avg", Expr: "amount_sum / max(tx_count, 1)"},
+	)
+
+	// No events: base features are zero, missing Last is nil
+	result, err := store.Get(ctx, "unknown")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got := result.FloatOr("avg_x2", -1); got != 0 {
+		t.Errorf("avg_x2 got %v, want 0", got)
+	}
+
+	store.Push(ctx, "user1",
+		gofeat.Event{Timestamp: time.Now().UTC(), Data: map[string]any{"amount": 30.0}},
+		gofeat.Event{Timestamp: time.Now().UTC(), Data: map[string]any{"amount": 10.0}},
+	)
+	result, _ = store.Get(ctx, "user1")
+	if got := result.FloatOr("avg", -1); got != 20 {
+		t.Errorf("avg got %v, want 20", got)
+	}
+	if got := result.FloatOr("avg_x2", -1); got != 40 {
+		t.Errorf("avg_x2 got %v, want 40", got)
+	}
+}
+
+func TestStore_DerivedNonNumeric(t *testing.T) {
+	ctx := context.Background()
+	store := derivedTestStore(t, gofeat.DerivedFeature{Name: "bad", Expr: "last_country + 1"})
+	store.Push(ctx, "user1", gofeat.Event{Timestamp: time.Now().UTC(), Data: map[string]any{"country": "US"}})
+
+	if _, err := store.Get(ctx, "user1"); err == nil || !strings.Contains(err.Error(), "last_country") {
+		t.Errorf("expected error for string feature, got %v", err)
+	}
+}
+
+func TestNew_DerivedValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		derived []gofeat.DerivedFeature
+		wantErr string
+	}{
+		{name: "empty name", derived: []gofeat.DerivedFeature{{Expr: "1"}}, wantErr: "name required"},
+		{name: "clashes with feature", derived: []gofeat.DerivedFeature{{Name: "tx_count", Expr: "1"}}, wantErr: "already used"},
+		{name: "duplicate", derived: []gofeat.DerivedFeature{{Name: "a", Expr: "1"}, {Name: "a", Expr: "2"}}, wantErr: "duplicate"},
+		{name: "unknown reference", derived: []gofeat.DerivedFeature{{Name: "a", Expr: "missing * 2"}}, wantErr: `unknown feature "missing"`},
+		{name: "unknown function", derived: []gofeat.DerivedFeature{{Name: "a", Expr: "exp(tx_count)"}}, wantErr: `unknown function "exp"`},
+		{name: "wrong arity", derived: []gofeat.DerivedFeature{{Name: "a", Expr: "abs(1, 2)"}}, wantErr: "wrong number of arguments"},
+		{name: "syntax", derived: []gofeat.DerivedFeature{{Name: "a", Expr: "(tx_count + 1"}}, wantErr: `expected ")"`},
+		{name: "trailing input", derived: []gofeat.DerivedFeature{{Name: "a", Expr: "tx_count 1"}}, wantErr: "unexpected"},
+		{name: "bad character", derived: []gofeat.DerivedFeature{{Name: "a", Expr: "tx_count & 1"}}, wantErr: "unexpected character"},
+		{name: "empty", derived: []gofeat.DerivedFeature{{Name: "a", Expr: ""}}, wantErr: "unexpected end"},
+		{name: "self reference", derived: []gofeat.DerivedFeature{{Name: "a", Expr: "a + 1"}}, wantErr: "cycle: a -> a"},
+		{
+			name: "cycle",
+			derived: []gofeat.DerivedFeature{
+				{Name: "a", Expr: "b + 1"},
+				{Name: "b", Expr: "c + tx_count"},
+				{Name: "c", Expr: "a * 2"},
+			},
+			wantErr: "cycle: a -> b -> c -> a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := gofeat.New(gofeat.Config{
+				Features: []gofeat.Feature{{Name: "tx_count", Aggregate: gofeat.Count}},
+				Derived:  tt.derived,
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
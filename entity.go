@@ -0,0 +1,182 @@
+package gofeat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnknownEntityType is returned by GetEntity and GetEntityAt for entity
+// types missing from Config.Entities.
+var ErrUnknownEntityType = errors.New("gofeat: unknown entity type")
+
+// EntityType declares a kind of entity events are aggregated by, e.g. "card"
+// keyed by the card number of a transaction. Store.Ingest stores an event
+// once for every entity type it has a key for, and GetEntity computes the
+// features whose Entity is the type name.
+//
+// Events of typed entities are stored under "<type>/<key>", so IDs passed
+// to Push should not have that form.
+type EntityType struct {
+	Name  string
+	Field string // Data field holding the key, used when Key is nil
+
+	// Key extracts the entity key from an event. It returns false if the
+	// event does not belong to an entity of this type.
+	Key func(e Event) (string, bool)
+}
+
+// KeyFor returns the key of the entity of this type that e belongs to,
+// reading Field unless Key is set. Missing, nil and empty values have no key.
+// Numbers are formatted without exponent, so that the float64 4111111111111111
+// read from CSV or JSON has the same key as the string "4111111111111111".
+func (t EntityType) KeyFor(e Event) (string, bool) {
+	if t.Key != nil {
+		return t.Key(e)
+	}
+	v, ok := e.Data[t.Field]
+	if !ok || v == nil {
+		return "", false
+	}
+	var k string
+	switch n := v.(type) {
+	case string:
+		k = n
+	case float64:
+		k = strconv.FormatFloat(n, 'f', -1, 64)
+	case float32:
+		k = strconv.FormatFloat(float64(n), 'f', -1, 32)
+	case int:
+		k = strconv.Itoa(n)
+	case int64:
+		k = strconv.FormatInt(n, 10)
+	case int32:
+		k = strconv.FormatInt(int64(n), 10)
+	default:
+		k = fmt.Sprint(v)
+	}
+	return k, k != ""
+}
+
+func validateEntityTypes(types []EntityType) error {
+	seen := make(map[string]struct{}, len(types))
+	for _, t := range types {
+		if t.Name == "" {
+			return errors.New("gofeat: entity type name required")
+		}
+		if strings.Contains(t.Name, "/") {
+			return fmt.Errorf("gofeat: entity type %q: name must not contain '/'", t.Name)
+		}
+		if t.Field == "" && t.Key == nil {
+			return fmt.Errorf("gofeat: entity type %q: field or key function required", t.Name)
+		}
+		if _, ok := seen[t.Name]; ok {
+			return fmt.Errorf("gofeat: duplicate entity type %q", t.Name)
+		}
+		seen[t.Name] = struct{}{}
+	}
+	return nil
+}
+
+// entityKey returns the storage key of a typed entity.
+func entityKey(entityType, id string) string {
+	return entityType + "/" + id
+}
+
+// Ingest stores events under every declared entity type they have a key
+// for, e.g. a transaction under its user, card and device. If the storage
+// implements BatchPusher all fan-out writes are applied atomically,
+// otherwise they are pushed one entity at a time. Event Data is shared
+// between the copies, not cloned.
+func (s *Store) Ingest(ctx context.Context, events ...Event) error {
+	if s.obs == nil {
+		return s.ingest(ctx, events)
+	}
+	op := PushOp{Ingest: true, Events: len(events)}
+	ctx = s.obs.PushStart(ctx, op)
+	start := time.Now()
+	err := s.ingest(ctx, events)
+	s.obs.PushEnd(ctx, op, OpResult{Duration: time.Since(start), Err: err})
+	return err
+}
+
+func (s *Store) ingest(ctx context.Context, events []Event) error {
+	if len(s.entities) == 0 {
+		return errors.New("gofeat: no entity types configured")
+	}
+
+	s.writeMu.RLock()
+	defer s.writeMu.RUnlock()
+
+	fs := s.features.Load()
+	batch := make(map[string][]Event)
+	groups := make(map[string]*featureGroup)
+	for i, e := range events {
+		if err := s.validateEvent(e); err != nil {
+			return fmt.Errorf("%w %d: %w", ErrInvalidEvent, i, err)
+		}
+		for _, t := range s.entities {
+			id, ok := t.KeyFor(e)
+			if !ok {
+				continue
+			}
+			key := entityKey(t.Name, id)
+			batch[key] = append(batch[key], e)
+			groups[key] = fs.groups[t.Name]
+		}
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+
+	// Bucket state must be seeded before the new events become visible
+	buckets := make(map[string]*entityBuckets)
+	for key, g := range groups {
+		if g.buckets == nil {
+			continue
+		}
+		eb, err := g.buckets.load(ctx, s.backend, key)
+		if err != nil {
+			return err
+		}
+		buckets[key] = eb
+	}
+
+	// The observed backend implements BatchPusher whether or not the
+	// storage does, so check the storage itself
+	if _, ok := s.storage.(BatchPusher); ok {
+		bp, _ := s.backend.(BatchPusher)
+		if err := bp.PushBatch(ctx, batch); err != nil {
+			return err
+		}
+	} else {
+		for key, evs := range batch {
+			if err := s.backend.Push(ctx, key, evs...); err != nil {
+				return err
+			}
+		}
+	}
+
+	for key, eb := range buckets {
+		groups[key].buckets.add(eb, batch[key])
+	}
+	return nil
+}
+
+// GetEntity computes the features of entityType for the entity id.
+func (s *Store) GetEntity(ctx context.Context, entityType, id string) (Result, error) {
+	return s.GetEntityAt(ctx, entityType, id, time.Now().UTC())
+}
+
+// GetEntityAt computes the features of entityType for the entity id at a
+// point in time.
+func (s *Store) GetEntityAt(ctx context.Context, entityType, id string, at time.Time) (Result, error) {
+	g, ok := s.features.Load().groups[entityType]
+	if !ok || entityType == "" {
+		return Result{}, fmt.Errorf("%w %q", ErrUnknownEntityType, entityType)
+	}
+	return s.compute(ctx, g, entityType, id, at)
+}
@@ -0,0 +1,268 @@
+package gofeat_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+func entityTestConfig() gofeat.Config {
+	return gofeat.Config{
+		Entities: []gofeat.EntityType{
+			{Name: "user", Field: "user"},
+			{Name: "card", Field: "card"},
+			{Name: "ip", Key: func(e gofeat.Event) (string, bool) {
+				ip, ok := e.Data["ip"].(string)
+				return strings.TrimSpace(ip), ok
+			}},
+		},
+		Features: []gofeat.Feature{
+			{Name: "tx_count", Aggregate: gofeat.Count},
+			{Name: "user_tx_1h", Aggregate: gofeat.Count, Window: gofeat.Sliding(time.Hour), Entity: "user"},
+			{Name: "card_sum", Aggregate: gofeat.Sum("amount"), Entity: "card"},
+			{Name: "card_users", Aggregate: gofeat.DistinctCount("user"), Entity: "card"},
+			{Name: "ip_cards", Aggregate: gofeat.DistinctCount("card"), Entity: "ip"},
+		},
+		Derived: []gofeat.DerivedFeature{
+			{Name: "card_avg", Expr: "card_sum / max(card_users, 1)", Entity: "card"},
+		},
+	}
+}
+
+func TestStore_Ingest(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []gofeat.Event{
+		{Timestamp: now.Add(-2 * time.Hour), Data: map[string]any{"user": "u1", "card": "4111", "ip": "10.0.0.1", "amount": 10.0}},
+		{Timestamp: now.Add(-30 * time.Minute), Data: map[string]any{"user": "u2", "card": "4111", "ip": " 10.0.0.1 ", "amount": 20.0}},
+		{Timestamp: now.Add(-10 * time.Minute), Data: map[string]any{"user": "u1", "card": 5500, "amount": 30.0}},
+	}
+
+	for _, bucket := range []time.Duration{0, time.Minute} {
+		cfg := entityTestConfig()
+		cfg.BucketSize = bucket
+		store, err := gofeat.New(cfg)
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		if err := store.Ingest(ctx, events[0]); err != nil {
+			t.Fatalf("Ingest failed: %v", err)
+		}
+		if err := store.Ingest(ctx, events[1:]...); err != nil {
+			t.Fatalf("Ingest failed: %v", err)
+		}
+
+		user, err := store.GetEntityAt(ctx, "user", "u1", now)
+		if err != nil {
+			t.Fatalf("GetEntityAt failed: %v", err)
+		}
+		if got := user.IntOr("user_tx_1h", -1); got != 1 {
+			t.Errorf("bucket %v: user_tx_1h got %d, want 1", bucket, got)
+		}
+		if _, err := user.Float("card_sum"); err == nil {
+			t.Errorf("bucket %v: card features must not be computed for users", bucket)
+		}
+
+		card, _ := store.GetEntityAt(ctx, "card", "4111", now)
+		if got := card.FloatOr("card_sum", -1); got != 30 {
+			t.Errorf("bucket %v: card_sum got %v, want 30", bucket, got)
+		}
+		if got := card.IntOr("card_users", -1); got != 2 {
+			t.Errorf("bucket %v: card_users got %d, want 2", bucket, got)
+		}
+		if got := card.FloatOr("card_avg", -1); got != 15 {
+			t.Errorf("bucket %v: card_avg got %v, want 15", bucket, got)
+		}
+
+		// Non-string keys are formatted
+		card, _ = store.GetEntityAt(ctx, "card", "5500", now)
+		if got := card.FloatOr("card_sum", -1); got != 30 {
+			t.Errorf("bucket %v: card 5500 card_sum got %v, want 30", bucket, got)
+		}
+
+		ip, _ := store.GetEntityAt(ctx, "ip", "10.0.0.1", now)
+		if got := ip.IntOr("ip_cards", -1); got != 1 {
+			t.Errorf("bucket %v: ip_cards got %d, want 1", bucket, got)
+		}
+
+		// Untyped features are not affected by Ingest
+		plain, _ := store.GetAt(ctx, "u1", now)
+		if got := plain.IntOr("tx_count", -1); got != 0 {
+			t.Errorf("bucket %v: tx_count got %d, want 0", bucket, got)
+		}
+		if _, err := plain.Int("user_tx_1h"); err == nil {
+			t.Errorf("bucket %v: typed features must not be computed by GetAt", bucket)
+		}
+
+		// user: u1, u2; card: 4111, 5500; ip: 10.0.0.1
+		stats, _ := store.Stats(ctx)
+		if stats.Entities != 5 || stats.TotalEvents != 8 {
+			t.Errorf("bucket %v: stats got %+v", bucket, stats)
+		}
+	}
+}
+
+func TestStore_Ingest_CustomStorage(t *testing.T) {
+	ctx := context.Background()
+	storage := &mockStorage{events: make(map[string][]gofeat.Event)}
+	cfg := entityTestConfig()
+	cfg.Storage = storage
+	store, _ := gofeat.New(cfg)
+
+	err := store.Ingest(ctx, gofeat.Event{Timestamp: time.Now().UTC(), Data: map[string]any{"user": "u1", "card": "4111", "amount": 5.0}})
+	if err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	if len(storage.events["user/u1"]) != 1 || len(storage.events["card/4111"]) != 1 {
+		t.Errorf("unexpected storage keys: %v", storage.events)
+	}
+
+	result, _ := store.GetEntity(ctx, "card", "4111")
+	if got := result.FloatOr("card_sum", -1); got != 5 {
+		t.Errorf("card_sum got %v, want 5", got)
+	}
+}
+
+func TestEntityType_KeyFor(t *testing.T) {
+	card := gofeat.EntityType{Name: "card", Field: "card"}
+
+	tests := []struct {
+		name   string
+		value  any
+		want   string
+		wantOK bool
+	}{
+		{name: "string", value: "4111111111111111", want: "4111111111111111", wantOK: true},
+		{name: "float64", value: 4111111111111111.0, want: "4111111111111111", wantOK: true},
+		{name: "fractional float64", value: 12.5, want: "12.5", wantOK: true},
+		{name: "float32", value: float32(1e7), want: "10000000", wantOK: true},
+		{name: "int", value: 5500, want: "5500", wantOK: true},
+		{name: "int64", value: int64(-42), want: "-42", wantOK: true},
+		{name: "bool", value: true, want: "true", wantOK: true},
+		{name: "empty string", value: "", wantOK: false},
+		{name: "nil", value: nil, wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := card.KeyFor(gofeat.Event{Data: map[string]any{"card": tt.value}})
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("got %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestStore_Backfill_NumericEntityKeys(t *testing.T) {
+	// Readers decode numeric cells as float64; they must reach the same
+	// entity as the string key
+	ctx := context.Background()
+	sources := []gofeat.EventSource{
+		gofeat.NewCSVEventReader(strings.NewReader("timestamp,card,amount\n2024-01-01T10:00:00Z,4111111111111111,10\n"),
+			gofeat.EventReaderOptions{}),
+		gofeat.NewJSONLEventReader(strings.NewReader(`{"timestamp": "2024-01-01T11:00:00Z", "card": 4111111111111111, "amount": 20}`),
+			gofeat.EventReaderOptions{}),
+	}
+
+	store, err := gofeat.New(entityTestConfig())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	for _, src := range sources {
+		if _, err := store.Backfill(ctx, src, gofeat.BackfillOptions{}); err != nil {
+			t.Fatalf("Backfill failed: %v", err)
+		}
+	}
+	event := gofeat.Event{
+		Timestamp: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Data:      map[string]any{"card": "4111111111111111", "amount": 30.0},
+	}
+	if err := store.Ingest(ctx, event); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+
+	result, err := store.GetEntityAt(ctx, "card", "4111111111111111", event.Timestamp)
+	if err != nil {
+		t.Fatalf("GetEntityAt failed: %v", err)
+	}
+	if got := result.FloatOr("card_sum", 0); got != 60 {
+		t.Errorf("card_sum got %v, want 60", got)
+	}
+}
+
+func TestStore_Ingest_Errors(t *testing.T) {
+	ctx := context.Background()
+	store, _ := gofeat.New(entityTestConfig())
+
+	if err := store.Ingest(ctx, gofeat.Event{Timestamp: time.Now(), Data: map[string]any{"user": "u1"}}); err == nil {
+		t.Error("expected error for non-UTC timestamp")
+	}
+	if _, err := store.GetEntity(ctx, "device", "d1"); err == nil {
+		t.Error("expected error for unknown entity type")
+	}
+	if _, err := store.GetEntity(ctx, "", "u1"); err == nil {
+		t.Error("expected error for empty entity type")
+	}
+
+	plain, _ := gofeat.New(gofeat.Config{Features: []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count}}})
+	if err := plain.Ingest(ctx, gofeat.Event{Timestamp: time.Now().UTC()}); err == nil {
+		t.Error("expected error without entity types")
+	}
+}
+
+func TestNew_EntityValidation(t *testing.T) {
+	count := gofeat.Feature{Name: "count", Aggregate: gofeat.Count}
+	tests := []struct {
+		name    string
+		cfg     gofeat.Config
+		wantErr string
+	}{
+		{
+			name:    "empty name",
+			cfg:     gofeat.Config{Features: []gofeat.Feature{count}, Entities: []gofeat.EntityType{{Field: "card"}}},
+			wantErr: "name required",
+		},
+		{
+			name:    "slash in name",
+			cfg:     gofeat.Config{Features: []gofeat.Feature{count}, Entities: []gofeat.EntityType{{Name: "a/b", Field: "card"}}},
+			wantErr: "must not contain",
+		},
+		{
+			name:    "no key",
+			cfg:     gofeat.Config{Features: []gofeat.Feature{count}, Entities: []gofeat.EntityType{{Name: "card"}}},
+			wantErr: "field or key function required",
+		},
+		{
+			name: "duplicate",
+			cfg: gofeat.Config{Features: []gofeat.Feature{count}, Entities: []gofeat.EntityType{
+				{Name: "card", Field: "card"}, {Name: "card", Field: "pan"},
+			}},
+			wantErr: "duplicate entity type",
+		},
+		{
+			name:    "unknown feature entity",
+			cfg:     gofeat.Config{Features: []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count, Entity: "card"}}},
+			wantErr: `unknown entity type "card"`,
+		},
+		{
+			name: "derived across entity types",
+			cfg: gofeat.Config{
+				Features: []gofeat.Feature{count},
+				Entities: []gofeat.EntityType{{Name: "card", Field: "card"}},
+				Derived:  []gofeat.DerivedFeature{{Name: "double", Expr: "count * 2", Entity: "card"}},
+			},
+			wantErr: `unknown feature "count"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := gofeat.New(tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
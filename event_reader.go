@@ -0,0 +1,399 @@
+package gofeat
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SourceEvent is an event read from an EventSource together with the
+// entity it belongs to.
+type SourceEvent struct {
+	EntityID string // empty if the row has no entity ID
+	Event    Event
+	Line     int // 1-based line of the row in the input
+}
+
+// EventSource yields events, e.g. for Store.Backfill.
+type EventSource interface {
+	// Next returns the next event. It returns io.EOF after the last one and
+	// a *RowError for a malformed row; reading may continue after a
+	// *RowError but not after other errors.
+	Next() (SourceEvent, error)
+}
+
+// RowError reports a malformed row of an event file.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ColumnType converts the values of a column read by an event reader.
+type ColumnType int
+
+const (
+	// ColumnAuto keeps JSON values as decoded and converts CSV cells
+	// spelled like JSON numbers or bools. Integers become int64, or stay
+	// strings if out of range, and other numbers become float64. Cells
+	// such as "01234", "+1" or "NaN" stay strings.
+	ColumnAuto ColumnType = iota
+	ColumnString
+	ColumnFloat
+	ColumnInt  // int64
+	ColumnBool // true or false
+	ColumnTime // EventReaderOptions.TimeLayout or Unix seconds, in UTC
+)
+
+// Default column names of EventReaderOptions.
+const (
+	DefaultEntityField = "entity_id"
+	DefaultTimeField   = "timestamp"
+)
+
+// EventReaderOptions configure how rows are mapped to events.
+type EventReaderOptions struct {
+	// EntityField is the column holding the entity ID, DefaultEntityField
+	// if empty. It is not copied to Data.
+	EntityField string
+
+	// TimeField is the column holding the event time, DefaultTimeField if
+	// empty. It is not copied to Data.
+	TimeField string
+
+	// TimeLayout parses string timestamps, time.RFC3339Nano if empty.
+	// Numeric timestamps are always Unix seconds.
+	TimeLayout string
+
+	// Columns maps column names to Data keys. If set, only the listed
+	// columns are copied to Data; otherwise all columns are, under their
+	// own name.
+	Columns map[string]string
+
+	// Types sets the type of columns by column name, ColumnAuto by default.
+	Types map[string]ColumnType
+}
+
+// NewCSVEventReader returns an EventSource reading CSV with a header row.
+// Empty cells are left out of Data.
+func NewCSVEventReader(r io.Reader, opts EventReaderOptions) EventSource {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	return &csvEventReader{r: cr, m: newRowMapper(opts, true)}
+}
+
+// NewJSONLEventReader returns an EventSource reading one flat JSON object
+// per line. Blank lines are skipped.
+func NewJSONLEventReader(r io.Reader, opts EventReaderOptions) EventSource {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	return &jsonlEventReader{sc: sc, m: newRowMapper(opts, false)}
+}
+
+type csvEventReader struct {
+	r      *csv.Reader
+	m      rowMapper
+	header []string
+}
+
+func (c *csvEventReader) Next() (SourceEvent, error) {
+	if c.header == nil {
+		header, err := c.r.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return SourceEvent{}, errors.New("gofeat: missing csv header")
+			}
+			return SourceEvent{}, fmt.Errorf("gofeat: read csv header: %w", err)
+		}
+		c.header = append([]string(nil), header...)
+	}
+
+	row, err := c.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return SourceEvent{}, &RowError{Line: parseErr.Line, Err: parseErr.Err}
+		}
+		return SourceEvent{}, err
+	}
+	line, _ := c.r.FieldPos(0)
+	if len(row) != len(c.header) {
+		return SourceEvent{}, &RowError{Line: line, Err: fmt.Errorf("expected %d columns, got %d", len(c.header), len(row))}
+	}
+
+	fields := make(map[string]any, len(row))
+	for i, v := range row {
+		if v != "" {
+			fields[c.header[i]] = v
+		}
+	}
+	return c.m.event(line, fields)
+}
+
+type jsonlEventReader struct {
+	sc   *bufio.Scanner
+	m    rowMapper
+	line int
+}
+
+func (j *jsonlEventReader) Next() (SourceEvent, error) {
+	for j.sc.Scan() {
+		j.line++
+		text := strings.TrimSpace(j.sc.Text())
+		if text == "" {
+			continue
+		}
+		// Numbers are decoded as json.Number so that they can be converted to
+		// the column type without losing digits
+		dec := json.NewDecoder(strings.NewReader(text))
+		dec.UseNumber()
+		var fields map[string]any
+		if err := dec.Decode(&fields); err != nil {
+			return SourceEvent{}, &RowError{Line: j.line, Err: err}
+		}
+		return j.m.event(j.line, fields)
+	}
+	if err := j.sc.Err(); err != nil {
+		return SourceEvent{}, err
+	}
+	return SourceEvent{}, io.EOF
+}
+
+// rowMapper turns the fields of a row into a SourceEvent. Field values are
+// CSV strings or values decoded by encoding/json with UseNumber.
+type rowMapper struct {
+	EventReaderOptions
+	csv bool // values are untyped CSV cells
+}
+
+func newRowMapper(opts EventReaderOptions, csv bool) rowMapper {
+	if opts.EntityField == "" {
+		opts.EntityField = DefaultEntityField
+	}
+	if opts.TimeField == "" {
+		opts.TimeField = DefaultTimeField
+	}
+	if opts.TimeLayout == "" {
+		opts.TimeLayout = time.RFC3339Nano
+	}
+	return rowMapper{EventReaderOptions: opts, csv: csv}
+}
+
+func (m rowMapper) event(line int, fields map[string]any) (SourceEvent, error) {
+	se := SourceEvent{Line: line}
+	if v, ok := fields[m.EntityField]; ok && v != nil {
+		id, err := m.convert(v, ColumnString)
+		if err != nil {
+			return SourceEvent{}, &RowError{Line: line, Err: fmt.Errorf("%s: %w", m.EntityField, err)}
+		}
+		se.EntityID, _ = id.(string)
+	}
+
+	v, ok := fields[m.TimeField]
+	if !ok || v == nil {
+		return SourceEvent{}, &RowError{Line: line, Err: fmt.Errorf("missing %q", m.TimeField)}
+	}
+	ts, err := m.convert(v, ColumnTime)
+	if err != nil {
+		return SourceEvent{}, &RowError{Line: line, Err: fmt.Errorf("%s: %w", m.TimeField, err)}
+	}
+	se.Event.Timestamp, _ = ts.(time.Time)
+
+	se.Event.Data = make(map[string]any, len(fields))
+	for col, v := range fields {
+		if col == m.EntityField || col == m.TimeField {
+			continue
+		}
+		key := col
+		if m.Columns != nil {
+			if key, ok = m.Columns[col]; !ok {
+				continue
+			}
+		}
+		if se.Event.Data[key], err = m.convert(v, m.Types[col]); err != nil {
+			return SourceEvent{}, &RowError{Line: line, Err: fmt.Errorf("%s: %w", col, err)}
+		}
+	}
+	return se, nil
+}
+
+// convert converts a CSV cell or JSON value to t.
+func (m rowMapper) convert(v any, t ColumnType) (any, error) {
+	// text is the literal of strings and JSON numbers
+	var text string
+	isText := true
+	switch x := v.(type) {
+	case nil:
+		return v, nil
+	case string:
+		text = x
+	case json.Number:
+		text = x.String()
+	default:
+		isText = false
+	}
+
+	switch t {
+	case ColumnString:
+		if isText {
+			return text, nil
+		}
+		return fmt.Sprint(v), nil
+	case ColumnFloat:
+		if isText {
+			return parseFloat(text)
+		}
+	case ColumnInt:
+		if isText {
+			i, err := strconv.ParseInt(text, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid integer %q", text)
+			}
+			return i, nil
+		}
+	case ColumnBool:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+		if text == "true" || text == "false" {
+			return text == "true", nil
+		}
+		return nil, fmt.Errorf("invalid bool %v", v)
+	case ColumnTime:
+		return m.parseTime(v)
+	default: // ColumnAuto
+		if _, ok := v.(json.Number); ok {
+			return autoNumber(text), nil
+		}
+		if m.csv && isText {
+			return autoValue(text), nil
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("cannot convert %T to %s", v, t)
+}
+
+func (m rowMapper) parseTime(v any) (any, error) {
+	var secs string
+	switch t := v.(type) {
+	case string:
+		if ts, err := time.Parse(m.TimeLayout, t); err == nil {
+			return ts.UTC(), nil
+		}
+		secs = t
+	case json.Number:
+		secs = t.String()
+	default:
+		return nil, fmt.Errorf("invalid timestamp %v", v)
+	}
+
+	f, err := strconv.ParseFloat(secs, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("invalid timestamp %q", secs)
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*float64(time.Second))).UTC(), nil
+}
+
+func parseFloat(s string) (any, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", s)
+	}
+	return f, nil
+}
+
+// autoValue converts a CSV cell to a number or bool where it is spelled
+// like one in JSON, matching the types JSON values get.
+func autoValue(s string) any {
+	if isJSONNumber(s) {
+		return autoNumber(s)
+	}
+	if s == "true" || s == "false" {
+		return s == "true"
+	}
+	return s
+}
+
+// autoNumber converts a JSON number literal to int64 if it is an integer,
+// keeping it as a string if it does not fit, and to float64 otherwise.
+func autoNumber(s string) any {
+	if !strings.ContainsAny(s, ".eE") {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+		return s
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+// isJSONNumber reports whether s is a number literal in JSON syntax: no
+// leading zeros, sign or spelled out values.
+func isJSONNumber(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	digits := func() int {
+		n := 0
+		for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+			n++
+		}
+		return n
+	}
+
+	n := digits()
+	if n == 0 || (n > 1 && s[0] == '0') {
+		return false
+	}
+	s = s[n:]
+	if strings.HasPrefix(s, ".") {
+		s = s[1:]
+		if n = digits(); n == 0 {
+			return false
+		}
+		s = s[n:]
+	}
+	if len(s) > 0 && (s[0] == 'e' || s[0] == 'E') {
+		s = strings.TrimLeft(s[1:], "+-")
+		if n = digits(); n == 0 {
+			return false
+		}
+		s = s[n:]
+	}
+	return s == ""
+}
+
+func (t ColumnType) String() string {
+	switch t {
+	case ColumnAuto:
+		return "auto"
+	case ColumnString:
+		return "string"
+	case ColumnFloat:
+		return "float"
+	case ColumnInt:
+		return "int"
+	case ColumnBool:
+		return "bool"
+	case ColumnTime:
+		return "time"
+	default:
+		return "ColumnType(" + strconv.Itoa(int(t)) + ")"
+	}
+}
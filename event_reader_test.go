@@ -0,0 +1,178 @@
+package gofeat_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+// readAll reads src to the end, collecting events and rejected lines.
+func readAll(t *testing.T, src gofeat.EventSource) ([]gofeat.SourceEvent, []int) {
+	t.Helper()
+	var events []gofeat.SourceEvent
+	var rejected []int
+	for {
+		se, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			return events, rejected
+		}
+		var rowErr *gofeat.RowError
+		if errors.As(err, &rowErr) {
+			rejected = append(rejected, rowErr.Line)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		events = append(events, se)
+	}
+}
+
+func TestEventReaders(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		csv      bool
+		input    string
+		opts     gofeat.EventReaderOptions
+		want     []gofeat.SourceEvent
+		rejected []int
+	}{
+		{
+			name: "jsonl",
+			input: `{"entity_id": 12345678901234567890, "timestamp": "2024-01-01T14:00:00+02:00", "amount": 10, "ok": true}
+
+{"timestamp": 1704110400.5, "country": "US"}
+{"entity_id": "u2"}
+{"entity_id": "u2", "timestamp": "yesterday"}
+not json`,
+			want: []gofeat.SourceEvent{
+				{
+					EntityID: "12345678901234567890", Line: 1,
+					Event: gofeat.Event{Timestamp: ts, Data: map[string]any{"amount": int64(10), "ok": true}},
+				},
+				{
+					Line:  3,
+					Event: gofeat.Event{Timestamp: ts.Add(500 * time.Millisecond), Data: map[string]any{"country": "US"}},
+				},
+			},
+			rejected: []int{4, 5, 6},
+		},
+		{
+			name: "csv",
+			csv:  true,
+			input: `entity_id,timestamp,amount,ok,note
+u1,2024-01-01T12:00:00Z,10,true,
+u1,1704110400,x,false,hi
+u1,2024-01-01T12:00:00Z
+u1,,1,true,x
+`,
+			want: []gofeat.SourceEvent{
+				{
+					EntityID: "u1", Line: 2,
+					Event: gofeat.Event{Timestamp: ts, Data: map[string]any{"amount": int64(10), "ok": true}},
+				},
+				{
+					EntityID: "u1", Line: 3,
+					Event: gofeat.Event{Timestamp: ts, Data: map[string]any{"amount": "x", "ok": false, "note": "hi"}},
+				},
+			},
+			rejected: []int{4, 5},
+		},
+		{
+			name: "csv mapping and types",
+			csv:  true,
+			input: `user,time,amt,card,skipped
+u1,01/01/2024 12:00,10.5,0042,x
+u1,01/01/2024 12:00,abc,0042,x
+`,
+			opts: gofeat.EventReaderOptions{
+				EntityField: "user",
+				TimeField:   "time",
+				TimeLayout:  "01/02/2006 15:04",
+				Columns:     map[string]string{"amt": "amount", "card": "card"},
+				Types:       map[string]gofeat.ColumnType{"amt": gofeat.ColumnFloat, "card": gofeat.ColumnString},
+			},
+			want: []gofeat.SourceEvent{
+				{
+					EntityID: "u1", Line: 2,
+					Event: gofeat.Event{Timestamp: ts, Data: map[string]any{"amount": 10.5, "card": "0042"}},
+				},
+			},
+			rejected: []int{3},
+		},
+		{
+			name: "csv auto numbers",
+			csv:  true,
+			input: `entity_id,timestamp,zip,id,big,neg,rate,exp,nan,inf,plus
+u1,2024-01-01T12:00:00Z,01234,9007199254740993,12345678901234567890,-3,0.25,1e3,NaN,Inf,+1
+`,
+			want: []gofeat.SourceEvent{
+				{
+					EntityID: "u1", Line: 2,
+					Event: gofeat.Event{Timestamp: ts, Data: map[string]any{
+						"zip": "01234", "id": int64(9007199254740993), "big": "12345678901234567890",
+						"neg": int64(-3), "rate": 0.25, "exp": 1000.0, "nan": "NaN", "inf": "Inf", "plus": "+1",
+					}},
+				},
+			},
+		},
+		{
+			name:  "jsonl auto numbers",
+			input: `{"entity_id": "u1", "timestamp": "2024-01-01T12:00:00Z", "id": 9007199254740993, "big": 12345678901234567890, "rate": 0.25}`,
+			want: []gofeat.SourceEvent{
+				{
+					EntityID: "u1", Line: 1,
+					Event: gofeat.Event{Timestamp: ts, Data: map[string]any{
+						"id": int64(9007199254740993), "big": "12345678901234567890", "rate": 0.25,
+					}},
+				},
+			},
+		},
+		{
+			name:  "jsonl types",
+			input: `{"entity_id": "u1", "timestamp": "2024-01-01T12:00:00Z", "n": 3, "id": 7, "flag": "true", "at": 1704110400}`,
+			opts: gofeat.EventReaderOptions{Types: map[string]gofeat.ColumnType{
+				"n": gofeat.ColumnInt, "id": gofeat.ColumnString, "flag": gofeat.ColumnBool, "at": gofeat.ColumnTime,
+			}},
+			want: []gofeat.SourceEvent{
+				{
+					EntityID: "u1", Line: 1,
+					Event: gofeat.Event{Timestamp: ts, Data: map[string]any{"n": int64(3), "id": "7", "flag": true, "at": ts}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var src gofeat.EventSource
+			if tt.csv {
+				src = gofeat.NewCSVEventReader(strings.NewReader(tt.input), tt.opts)
+			} else {
+				src = gofeat.NewJSONLEventReader(strings.NewReader(tt.input), tt.opts)
+			}
+			events, rejected := readAll(t, src)
+			if !reflect.DeepEqual(events, tt.want) {
+				t.Errorf("events got %+v, want %+v", events, tt.want)
+			}
+			if !reflect.DeepEqual(rejected, tt.rejected) {
+				t.Errorf("rejected lines got %v, want %v", rejected, tt.rejected)
+			}
+		})
+	}
+}
+
+func TestCSVEventReader_MissingHeader(t *testing.T) {
+	_, err := gofeat.NewCSVEventReader(strings.NewReader(""), gofeat.EventReaderOptions{}).Next()
+	var rowErr *gofeat.RowError
+	if err == nil || errors.Is(err, io.EOF) || errors.As(err, &rowErr) {
+		t.Errorf("expected a fatal error, got %v", err)
+	}
+}
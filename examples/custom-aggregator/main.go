@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/w0rng/gofeat"
@@ -89,7 +90,25 @@ func (a *percentileAgg) Result() any {
 	return sorted[idx]
 }
 
+// featureSpec defines features in JSON, e.g. loaded from a file maintained by analysts.
+const featureSpec = `{
+  "ttl": "24h",
+  "features": [
+    {"name": "count", "aggregate": "count", "window": {"kind": "sliding", "size": "1h"}},
+    {"name": "median", "aggregate": {"kind": "median", "field": "amount"}, "window": {"kind": "sliding", "size": "1h"}}
+  ]
+}`
+
 func main() {
+	// Make Median addressable as "median" in JSON configs
+	gofeat.RegisterAggregator("median", func(p *gofeat.Params) (gofeat.AggregatorFactory, error) {
+		field, err := p.String("field")
+		if err != nil {
+			return nil, err
+		}
+		return Median(field), nil
+	})
+
 	store, err := gofeat.New(gofeat.Config{
 		TTL: 24 * time.Hour,
 		Features: []gofeat.Feature{
@@ -145,4 +164,34 @@ func main() {
 	fmt.Printf("  Median: $%.2f\n", result.FloatOr("median", -1))
 	fmt.Printf("  P90:    $%.2f\n", result.FloatOr("p90", -1))
 	fmt.Printf("  P95:    $%.2f\n", result.FloatOr("p95", -1))
+
+	// Same aggregator, defined in JSON
+	cfg, err := gofeat.LoadConfig(strings.NewReader(featureSpec))
+	if err != nil {
+		log.Fatal(err)
+	}
+	specStore, err := gofeat.New(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer specStore.Close()
+
+	for i, amount := range amounts {
+		err := specStore.Push(ctx, "user_1", gofeat.Event{
+			Timestamp: now.Add(-time.Duration(len(amounts)-i) * time.Minute),
+			Data:      map[string]any{"amount": amount},
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	result, err = specStore.Get(ctx, "user_1")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println()
+	fmt.Println("From JSON config:")
+	fmt.Printf("  Count:  %d\n", result.IntOr("count", -1))
+	fmt.Printf("  Median: $%.2f\n", result.FloatOr("median", -1))
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"github.com/w0rng/gofeat"
@@ -90,4 +91,26 @@ func main() {
 		result.IntOr("tx_count_1h", -1),
 		result.FloatOr("tx_sum_1h", -1),
 	)
+
+	// BuildTrainingSet does the same join and writes a CSV ready for training
+	fmt.Println()
+	fmt.Println("=== BuildTrainingSet (CSV) ===")
+	fmt.Println()
+
+	labels := func(yield func(gofeat.LabelRow) bool) {
+		for _, tx := range transactions {
+			row := gofeat.LabelRow{
+				EntityID:  userID,
+				Timestamp: tx.event.Timestamp,
+				Label:     tx.isFraud,
+				Extra:     map[string]any{"amount": tx.event.Data["amount"]},
+			}
+			if !yield(row) {
+				return
+			}
+		}
+	}
+	if err := store.BuildTrainingSet(ctx, labels, gofeat.NewCSVRowWriter(os.Stdout), gofeat.TrainingSetOptions{}); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,440 @@
+package gofeat
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expression language for derived features.
+//
+//	expr    = or [ "?" expr ":" expr ]
+//	or      = and { "||" and }
+//	and     = cmp { "&&" cmp }
+//	cmp     = sum [ ("==" | "!=" | "<" | "<=" | ">" | ">=") sum ]
+//	sum     = product { ("+" | "-") product }
+//	product = unary { ("*" | "/" | "%") unary }
+//	unary   = ("-" | "!") unary | primary
+//	primary = number | "true" | "false" | name | name "(" args ")" | "(" expr ")"
+//
+// All values are float64; comparisons and logical operators yield 1 or 0
+// and any non-zero value is true. Feature values are converted as follows:
+// numbers as is, bool as 1 or 0, time.Duration as seconds and nil as 0.
+
+type expr interface {
+	eval(values map[string]any) (float64, error)
+}
+
+type (
+	numberExpr float64
+	refExpr    string
+	unaryExpr  struct {
+		op string
+		x  expr
+	}
+	binaryExpr struct {
+		op   string
+		x, y expr
+	}
+	condExpr struct {
+		cond, then, els expr
+	}
+	callExpr struct {
+		fn   exprFunc
+		args []expr
+	}
+)
+
+type exprFunc struct {
+	minArgs int
+	maxArgs int // -1 for variadic
+	call    func(args []float64) float64
+}
+
+//nolint:gochecknoglobals // read-only function table
+var exprFuncs = map[string]exprFunc{
+	"min":  {minArgs: 1, maxArgs: -1, call: func(a []float64) float64 { return fold(a, math.Min) }},
+	"max":  {minArgs: 1, maxArgs: -1, call: func(a []float64) float64 { return fold(a, math.Max) }},
+	"abs":  {minArgs: 1, maxArgs: 1, call: func(a []float64) float64 { return math.Abs(a[0]) }},
+	"log":  {minArgs: 1, maxArgs: 1, call: func(a []float64) float64 { return math.Log(a[0]) }},
+	"sqrt": {minArgs: 1, maxArgs: 1, call: func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"if": {minArgs: 3, maxArgs: 3, call: func(a []float64) float64 {
+		if a[0] != 0 {
+			return a[1]
+		}
+		return a[2]
+	}},
+}
+
+func fold(values []float64, fn func(a, b float64) float64) float64 {
+	acc := values[0]
+	for _, v := range values[1:] {
+		acc = fn(acc, v)
+	}
+	return acc
+}
+
+func (e numberExpr) eval(map[string]any) (float64, error) { return float64(e), nil }
+
+func (e refExpr) eval(values map[string]any) (float64, error) {
+	v, ok := values[string(e)]
+	if !ok {
+		return 0, fmt.Errorf("feature %q not found", string(e))
+	}
+	switch n := v.(type) {
+	case nil:
+		return 0, nil
+	case bool:
+		return boolFloat(n), nil
+	case time.Duration:
+		return n.Seconds(), nil
+	}
+	if f, ok := toFloat64(v); ok {
+		return f, nil
+	}
+	return 0, fmt.Errorf("feature %q: cannot use %T in expression", string(e), v)
+}
+
+func (e *unaryExpr) eval(values map[string]any) (float64, error) {
+	x, err := e.x.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	if e.op == "!" {
+		return boolFloat(x == 0), nil
+	}
+	return -x, nil
+}
+
+func (e *binaryExpr) eval(values map[string]any) (float64, error) {
+	x, err := e.x.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	// Short-circuit logical operators
+	switch {
+	case e.op == "&&" && x == 0:
+		return 0, nil
+	case e.op == "||" && x != 0:
+		return 1, nil
+	}
+	y, err := e.y.eval(values)
+	if err != nil {
+		return 0, err
+	}
+
+	switch e.op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/":
+		return x / y, nil
+	case "%":
+		return math.Mod(x, y), nil
+	case "==":
+		return boolFloat(x == y), nil
+	case "!=":
+		return boolFloat(x != y), nil
+	case "<":
+		return boolFloat(x < y), nil
+	case "<=":
+		return boolFloat(x <= y), nil
+	case ">":
+		return boolFloat(x > y), nil
+	case ">=":
+		return boolFloat(x >= y), nil
+	default: // && and ||
+		return boolFloat(y != 0), nil
+	}
+}
+
+func (e *condExpr) eval(values map[string]any) (float64, error) {
+	c, err := e.cond.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	if c != 0 {
+		return e.then.eval(values)
+	}
+	return e.els.eval(values)
+}
+
+func (e *callExpr) eval(values map[string]any) (float64, error) {
+	args := make([]float64, len(e.args))
+	for i, a := range e.args {
+		v, err := a.eval(values)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = v
+	}
+	return e.fn.call(args), nil
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// parseExpr parses src and returns the expression with the names of all
+// features it references.
+func parseExpr(src string) (expr, []string, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &exprParser{tokens: tokens, refs: make(map[string]struct{})}
+	e, err := p.expr()
+	if err != nil {
+		return nil, nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+	}
+
+	refs := make([]string, 0, len(p.refs))
+	for name := range p.refs {
+		refs = append(refs, name)
+	}
+	return e, refs, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokName
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isDigit(c) || c == '.':
+			j := i
+			for j < len(src) && (isDigit(src[j]) || src[j] == '.' || src[j] == 'e' || src[j] == 'E' ||
+				((src[j] == '+' || src[j] == '-') && (src[j-1] == 'e' || src[j-1] == 'E'))) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[i:j], pos: i})
+			i = j
+		case isNameStart(c):
+			j := i
+			for j < len(src) && (isNameStart(src[j]) || isDigit(src[j]) || src[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokName, text: src[i:j], pos: i})
+			i = j
+		default:
+			op := src[i : i+1]
+			if i+1 < len(src) {
+				switch two := src[i : i+2]; two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					op = two
+				}
+			}
+			if len(op) == 1 && !strings.Contains("+-*/%<>!?:(),", op) {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", op, i)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+type exprParser struct {
+	tokens []token
+	pos    int
+	refs   map[string]struct{}
+}
+
+func (p *exprParser) peek() token { return p.tokens[p.pos] }
+
+func (p *exprParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is one of the given operators.
+func (p *exprParser) accept(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		tok := p.peek()
+		if tok.kind == tokEOF {
+			return fmt.Errorf("expected %q at end of expression", op)
+		}
+		return fmt.Errorf("expected %q at offset %d, got %q", op, tok.pos, tok.text)
+	}
+	return nil
+}
+
+func (p *exprParser) expr() (expr, error) {
+	cond, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("?"); !ok {
+		return cond, nil
+	}
+	then, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	els, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &condExpr{cond: cond, then: then, els: els}, nil
+}
+
+// binaryLevels lists binary operators from lowest to highest precedence.
+//
+//nolint:gochecknoglobals // read-only operator table
+var binaryLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) binary(level int) (expr, error) {
+	if level == len(binaryLevels) {
+		return p.unary()
+	}
+	x, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(binaryLevels[level]...)
+		if !ok {
+			return x, nil
+		}
+		y, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryExpr{op: op, x: x, y: y}
+	}
+}
+
+func (p *exprParser) unary() (expr, error) {
+	if op, ok := p.accept("-", "!"); ok {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: op, x: x}, nil
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (expr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", tok.text, tok.pos)
+		}
+		return numberExpr(f), nil
+	case tokName:
+		switch tok.text {
+		case "true":
+			return numberExpr(1), nil
+		case "false":
+			return numberExpr(0), nil
+		}
+		if _, ok := p.accept("("); ok {
+			return p.call(tok)
+		}
+		p.refs[tok.text] = struct{}{}
+		return refExpr(tok.text), nil
+	case tokOp:
+		if tok.text == "(" {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expect(")")
+		}
+		return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+	default:
+		return nil, errors.New("unexpected end of expression")
+	}
+}
+
+func (p *exprParser) call(name token) (expr, error) {
+	fn, ok := exprFuncs[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at offset %d", name.text, name.pos)
+	}
+
+	var args []expr
+	if _, ok := p.accept(")"); !ok {
+		for {
+			arg, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.accept(","); !ok {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("%s: wrong number of arguments: %d", name.text, len(args))
+	}
+	return &callExpr{fn: fn, args: args}, nil
+}
@@ -0,0 +1,452 @@
+package gofeat
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyncPolicy controls when FileStorage flushes the write-ahead log to disk.
+type SyncPolicy int
+
+const (
+	// SyncAlways fsyncs the log before every Push returns.
+	SyncAlways SyncPolicy = iota
+	// SyncInterval fsyncs the log periodically in the background.
+	// A crash may lose writes from the last interval.
+	SyncInterval
+	// SyncNever leaves flushing to the operating system.
+	SyncNever
+)
+
+const (
+	defaultSegmentSize  = 64 << 20
+	defaultSyncInterval = time.Second
+	segmentExt          = ".seg"
+	recordHeaderSize    = 8
+)
+
+// ErrStorageClosed is returned when writing to a closed storage.
+var ErrStorageClosed = errors.New("gofeat: storage closed")
+
+// FileStorageOptions configures NewFileStorage.
+type FileStorageOptions struct {
+	TTL          time.Duration // 0 keeps events forever
+	SegmentSize  int64         // bytes per segment before rotation, defaults to 64 MiB
+	Sync         SyncPolicy
+	SyncInterval time.Duration // used with SyncInterval, defaults to 1s
+}
+
+// fileStorage is a durable Storage backed by a segmented append-only log.
+// All events are also indexed in memory; the log is only read on open.
+type fileStorage struct {
+	mem  *memoryStorage
+	dir  string
+	opts FileStorageOptions
+
+	mu       sync.Mutex
+	segments []*segment // ordered by id, last one is active
+	active   *os.File
+	size     int64 // size of the active segment
+	dirty    bool
+	closed   bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type segment struct {
+	id     uint64
+	path   string
+	latest time.Time // newest event timestamp written to the segment
+}
+
+// NewFileStorage opens (or creates) a file-backed storage in dir.
+//
+// Every Push is appended to a write-ahead log split into segments of
+// opts.SegmentSize bytes. On open all segments are replayed to rebuild the
+// per-entity event lists; a torn record at the tail of the last segment
+// (from a crash mid-write) is truncated. Evict removes whole segments
+// whose newest event is older than the TTL.
+func NewFileStorage(dir string, opts FileStorageOptions) (Storage, error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = defaultSegmentSize
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = defaultSyncInterval
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("gofeat: create storage dir: %w", err)
+	}
+
+	s := &fileStorage{
+		mem:  newMemoryStorage(opts.TTL),
+		dir:  dir,
+		opts: opts,
+	}
+	if err := s.recover(); err != nil {
+		return nil, err
+	}
+	if err := s.openActive(); err != nil {
+		return nil, err
+	}
+
+	if opts.Sync == SyncInterval {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.syncLoop()
+	}
+
+	return s, nil
+}
+
+func (s *fileStorage) Push(ctx context.Context, entityID string, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if err := s.append(map[string][]Event{entityID: events}); err != nil {
+		return err
+	}
+	return s.mem.Push(ctx, entityID, events...)
+}
+
+// PushBatch writes the whole batch as a single log record, so after a crash
+// either all of its events are replayed or none are.
+func (s *fileStorage) PushBatch(ctx context.Context, batch map[string][]Event) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := s.append(batch); err != nil {
+		return err
+	}
+	return s.mem.PushBatch(ctx, batch)
+}
+
+func (s *fileStorage) Get(ctx context.Context, entityID string, at time.Time) ([]Event, error) {
+	return s.mem.Get(ctx, entityID, at)
+}
+
+func (s *fileStorage) TTL() time.Duration {
+	return s.opts.TTL
+}
+
+func (s *fileStorage) Evict(ctx context.Context) error {
+	_, err := s.EvictCount(ctx)
+	return err
+}
+
+func (s *fileStorage) EvictCount(ctx context.Context) (int64, error) {
+	if s.opts.TTL == 0 {
+		return 0, nil
+	}
+	evicted, err := s.mem.EvictCount(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	before := time.Now().UTC().Add(-s.opts.TTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.segments[:0]
+	for i, seg := range s.segments {
+		if i == len(s.segments)-1 || !seg.latest.Before(before) {
+			kept = append(kept, seg)
+			continue
+		}
+		if err := os.Remove(seg.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.segments = append(kept, s.segments[i:]...)
+			return evicted, fmt.Errorf("gofeat: remove segment: %w", err)
+		}
+	}
+	s.segments = kept
+
+	return evicted, nil
+}
+
+func (s *fileStorage) Stats(ctx context.Context) (StorageStats, error) {
+	return s.mem.Stats(ctx)
+}
+
+func (s *fileStorage) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.active.Sync(); err != nil {
+		_ = s.active.Close()
+		return fmt.Errorf("gofeat: sync segment: %w", err)
+	}
+	return s.active.Close()
+}
+
+// append writes one log record holding events for one or more entities.
+// A record is either replayed completely or not at all.
+func (s *fileStorage) append(batch map[string][]Event) error {
+	payload, latest, err := encodeRecord(batch)
+	if err != nil {
+		return err
+	}
+
+	rec := make([]byte, recordHeaderSize, recordHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(rec[0:4], uint32(len(payload))) //nolint:gosec // records are far below 4 GiB
+	binary.LittleEndian.PutUint32(rec[4:8], crc32.ChecksumIEEE(payload))
+	rec = append(rec, payload...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStorageClosed
+	}
+	if _, err := s.active.Write(rec); err != nil {
+		return fmt.Errorf("gofeat: write segment: %w", err)
+	}
+	s.size += int64(len(rec))
+
+	seg := s.segments[len(s.segments)-1]
+	if latest.After(seg.latest) {
+		seg.latest = latest
+	}
+
+	if s.opts.Sync == SyncAlways {
+		if err := s.active.Sync(); err != nil {
+			return fmt.Errorf("gofeat: sync segment: %w", err)
+		}
+	} else {
+		s.dirty = true
+	}
+
+	if s.size >= s.opts.SegmentSize {
+		return s.rotate()
+	}
+	return nil
+}
+
+// rotate seals the active segment and starts a new one. Caller holds s.mu.
+func (s *fileStorage) rotate() error {
+	if err := s.active.Sync(); err != nil {
+		return fmt.Errorf("gofeat: sync segment: %w", err)
+	}
+	if err := s.active.Close(); err != nil {
+		return fmt.Errorf("gofeat: close segment: %w", err)
+	}
+	s.dirty = false
+
+	next := s.segments[len(s.segments)-1].id + 1
+	seg := &segment{id: next, path: s.segmentPath(next)}
+	f, err := os.OpenFile(seg.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("gofeat: create segment: %w", err)
+	}
+	s.segments = append(s.segments, seg)
+	s.active = f
+	s.size = 0
+	return nil
+}
+
+func (s *fileStorage) syncLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.opts.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.dirty && !s.closed {
+				if err := s.active.Sync(); err == nil {
+					s.dirty = false
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *fileStorage) segmentPath(id uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%016x%s", id, segmentExt))
+}
+
+// recover loads all segments from disk into the in-memory index.
+func (s *fileStorage) recover() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("gofeat: read storage dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 16, 64)
+		if err != nil {
+			continue
+		}
+		s.segments = append(s.segments, &segment{id: id, path: filepath.Join(s.dir, name)})
+	}
+	sort.Slice(s.segments, func(i, j int) bool {
+		return s.segments[i].id < s.segments[j].id
+	})
+
+	pending := make(map[string][]Event)
+	for i, seg := range s.segments {
+		last := i == len(s.segments)-1
+		if err := s.replay(seg, last, pending); err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+	for entityID, events := range pending {
+		if err := s.mem.Push(ctx, entityID, events...); err != nil {
+			return err
+		}
+	}
+	return s.mem.Evict(ctx)
+}
+
+// replay reads every record of seg into pending. For the last segment a
+// truncated or corrupt tail is cut off; elsewhere it is reported as an error.
+func (s *fileStorage) replay(seg *segment, last bool, pending map[string][]Event) error {
+	data, err := os.ReadFile(seg.path)
+	if err != nil {
+		return fmt.Errorf("gofeat: read segment: %w", err)
+	}
+
+	var offset int
+	for offset < len(data) {
+		n, latest, err := decodeRecord(data[offset:], pending)
+		if err != nil {
+			if !last {
+				return fmt.Errorf("gofeat: segment %s at offset %d: %w", seg.path, offset, err)
+			}
+			if err := os.Truncate(seg.path, int64(offset)); err != nil {
+				return fmt.Errorf("gofeat: truncate segment: %w", err)
+			}
+			break
+		}
+		if latest.After(seg.latest) {
+			seg.latest = latest
+		}
+		offset += n
+	}
+	return nil
+}
+
+// openActive opens the last segment for appending, creating the first
+// segment of an empty directory.
+func (s *fileStorage) openActive() error {
+	if len(s.segments) == 0 {
+		s.segments = append(s.segments, &segment{id: 1, path: s.segmentPath(1)})
+	}
+	seg := s.segments[len(s.segments)-1]
+
+	f, err := os.OpenFile(seg.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("gofeat: open segment: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("gofeat: stat segment: %w", err)
+	}
+	s.active = f
+	s.size = info.Size()
+	return nil
+}
+
+// encodeRecord serializes a batch as: entity count, then for every entity
+// its ID, event count and events. It also returns the newest timestamp.
+func encodeRecord(batch map[string][]Event) ([]byte, time.Time, error) {
+	var latest time.Time
+	b := binary.AppendUvarint(nil, uint64(len(batch)))
+	for entityID, events := range batch {
+		b = appendString(b, entityID)
+		b = binary.AppendUvarint(b, uint64(len(events)))
+		for _, e := range events {
+			var err error
+			b, err = appendEvent(b, e)
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			if e.Timestamp.After(latest) {
+				latest = e.Timestamp
+			}
+		}
+	}
+	return b, latest, nil
+}
+
+// decodeRecord parses one framed record from data into pending and returns
+// the number of bytes consumed.
+func decodeRecord(data []byte, pending map[string][]Event) (int, time.Time, error) {
+	if len(data) < recordHeaderSize {
+		return 0, time.Time{}, io.ErrUnexpectedEOF
+	}
+	size := int(binary.LittleEndian.Uint32(data[0:4]))
+	sum := binary.LittleEndian.Uint32(data[4:8])
+	if len(data)-recordHeaderSize < size {
+		return 0, time.Time{}, io.ErrUnexpectedEOF
+	}
+	payload := data[recordHeaderSize : recordHeaderSize+size]
+	if crc32.ChecksumIEEE(payload) != sum {
+		return 0, time.Time{}, errors.New("gofeat: checksum mismatch")
+	}
+
+	var latest time.Time
+	decoded := make(map[string][]Event)
+	d := &decoder{b: payload}
+	entities := d.uvarint()
+	for range entities {
+		entityID := d.string()
+		count := d.uvarint()
+		for range count {
+			e := d.event()
+			if d.err != nil {
+				break
+			}
+			if e.Timestamp.After(latest) {
+				latest = e.Timestamp
+			}
+			decoded[entityID] = append(decoded[entityID], e)
+		}
+		if d.err != nil {
+			return 0, time.Time{}, d.err
+		}
+	}
+	if d.err != nil {
+		return 0, time.Time{}, d.err
+	}
+
+	for entityID, events := range decoded {
+		pending[entityID] = append(pending[entityID], events...)
+	}
+	return recordHeaderSize + size, latest, nil
+}
@@ -0,0 +1,237 @@
+package gofeat_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+func TestFileStorage_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	s, err := gofeat.NewFileStorage(dir, gofeat.FileStorageOptions{})
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+
+	err = s.Push(ctx, "user1",
+		gofeat.Event{Timestamp: base.Add(time.Minute), Data: map[string]any{"amount": 50.0, "n": 2}},
+		gofeat.Event{Timestamp: base, Data: map[string]any{"amount": 100.0, "card": "1234", "ok": true}},
+	)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if err := s.Push(ctx, "user2", gofeat.Event{Timestamp: base, Data: map[string]any{"at": base}}); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	s, err = gofeat.NewFileStorage(dir, gofeat.FileStorageOptions{})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer s.Close()
+
+	events, err := s.Get(ctx, "user1", base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if !events[0].Timestamp.Equal(base) {
+		t.Errorf("events not sorted: first timestamp %v", events[0].Timestamp)
+	}
+	if events[0].Data["amount"] != 100.0 || events[0].Data["card"] != "1234" || events[0].Data["ok"] != true {
+		t.Errorf("unexpected data: %v", events[0].Data)
+	}
+	if events[1].Data["n"] != 2 {
+		t.Errorf("int value not preserved: %#v", events[1].Data["n"])
+	}
+
+	events, _ = s.Get(ctx, "user2", base)
+	if at, ok := events[0].Data["at"].(time.Time); !ok || !at.Equal(base) {
+		t.Errorf("time value not preserved: %#v", events[0].Data["at"])
+	}
+
+	stats, _ := s.Stats(ctx)
+	if stats.Entities != 2 || stats.TotalEvents != 3 {
+		t.Errorf("stats: got %+v", stats)
+	}
+}
+
+func TestFileStorage_TornWrite(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	s, err := gofeat.NewFileStorage(dir, gofeat.FileStorageOptions{Sync: gofeat.SyncNever})
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+	for i := range 3 {
+		if err := s.Push(ctx, "user1", gofeat.Event{Timestamp: base.Add(time.Duration(i) * time.Minute)}); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+	}
+	s.Close()
+
+	// Simulate a crash in the middle of writing a record
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	if len(segments) != 1 {
+		t.Fatalf("expected 1 segment, got %d", len(segments))
+	}
+	f, err := os.OpenFile(segments[0], os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{42, 0, 0, 0, 1, 2})
+	f.Close()
+
+	s, err = gofeat.NewFileStorage(dir, gofeat.FileStorageOptions{})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if err := s.Push(ctx, "user1", gofeat.Event{Timestamp: base.Add(time.Hour)}); err != nil {
+		t.Fatalf("Push after recovery failed: %v", err)
+	}
+	s.Close()
+
+	s, err = gofeat.NewFileStorage(dir, gofeat.FileStorageOptions{})
+	if err != nil {
+		t.Fatalf("second reopen failed: %v", err)
+	}
+	defer s.Close()
+
+	events, _ := s.Get(ctx, "user1", base.Add(2*time.Hour))
+	if len(events) != 4 {
+		t.Errorf("expected 4 events after recovery, got %d", len(events))
+	}
+}
+
+func TestFileStorage_TTL(t *testing.T) {
+	s, err := gofeat.NewFileStorage(t.TempDir(), gofeat.FileStorageOptions{TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.Push(ctx, "user1",
+		gofeat.Event{Timestamp: now.Add(-2 * time.Hour)},
+		gofeat.Event{Timestamp: now.Add(-30 * time.Minute)},
+	)
+
+	events, _ := s.Get(ctx, "user1", now)
+	if len(events) != 1 {
+		t.Errorf("expected 1 event within TTL, got %d", len(events))
+	}
+}
+
+func TestFileStorage_EvictDropsSegments(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	s, err := gofeat.NewFileStorage(dir, gofeat.FileStorageOptions{TTL: time.Hour, SegmentSize: 1})
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+	defer s.Close()
+
+	s.Push(ctx, "user1", gofeat.Event{Timestamp: now.Add(-3 * time.Hour)})
+	s.Push(ctx, "user1", gofeat.Event{Timestamp: now.Add(-2 * time.Hour)})
+	s.Push(ctx, "user1", gofeat.Event{Timestamp: now.Add(-time.Minute)})
+
+	before, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	if err := s.Evict(ctx); err != nil {
+		t.Fatalf("Evict failed: %v", err)
+	}
+	after, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+
+	// Every record rotates, so each push has its own segment plus the empty active one
+	if len(before) != 4 {
+		t.Fatalf("expected 4 segments before evict, got %d", len(before))
+	}
+	if len(after) != 2 {
+		t.Errorf("expected 2 segments after evict, got %d", len(after))
+	}
+
+	stats, _ := s.Stats(ctx)
+	if stats.TotalEvents != 1 {
+		t.Errorf("expected 1 event after evict, got %d", stats.TotalEvents)
+	}
+}
+
+func TestFileStorage_Closed(t *testing.T) {
+	s, err := gofeat.NewFileStorage(t.TempDir(), gofeat.FileStorageOptions{Sync: gofeat.SyncInterval})
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second Close failed: %v", err)
+	}
+
+	err = s.Push(context.Background(), "user1", gofeat.Event{Timestamp: time.Now().UTC()})
+	if !errors.Is(err, gofeat.ErrStorageClosed) {
+		t.Errorf("expected ErrStorageClosed, got %v", err)
+	}
+}
+
+func TestFileStorage_UnsupportedValue(t *testing.T) {
+	s, err := gofeat.NewFileStorage(t.TempDir(), gofeat.FileStorageOptions{})
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+	defer s.Close()
+
+	err = s.Push(context.Background(), "user1", gofeat.Event{
+		Timestamp: time.Now().UTC(),
+		Data:      map[string]any{"bad": []int{1}},
+	})
+	if err == nil {
+		t.Error("expected error for unsupported value type")
+	}
+}
+
+func TestFileStorage_PushBatch(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	s, err := gofeat.NewFileStorage(dir, gofeat.FileStorageOptions{})
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+	e := gofeat.Event{Timestamp: now, Data: map[string]any{"amount": 10.0}}
+	if err := s.(gofeat.BatchPusher).PushBatch(ctx, map[string][]gofeat.Event{"user/u1": {e}, "card/4111": {e}}); err != nil {
+		t.Fatalf("PushBatch failed: %v", err)
+	}
+	s.Close()
+
+	s, err = gofeat.NewFileStorage(dir, gofeat.FileStorageOptions{})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer s.Close()
+
+	for _, key := range []string{"user/u1", "card/4111"} {
+		events, _ := s.Get(ctx, key, now)
+		if len(events) != 1 || events[0].Data["amount"] != 10.0 {
+			t.Errorf("%s: unexpected events %v", key, events)
+		}
+	}
+}
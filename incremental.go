@@ -0,0 +1,260 @@
+package gofeat
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// bucketIndex maintains pre-aggregated partial states in fixed-width time
+// buckets for features that can be computed incrementally.
+//
+// A feature is incremental when its window is Sliding, Between or Lifetime
+// and its aggregator can be merged. GetAt merges the buckets that lie
+// completely inside the window and replays raw events only for the partial
+// buckets at both edges, so results match the non-incremental path (up to
+// floating-point rounding). When the buckets do not hold exactly the events
+// storage returns for that range, e.g. events hidden by the TTL when the
+// buckets were seeded, GetAt replays all events in the window instead.
+type bucketIndex struct {
+	size     int64 // bucket width in nanoseconds
+	slots    []int // feature index -> slot in entityBuckets, -1 if not incremental
+	features []Feature
+	entities sync.Map // string -> *entityBuckets
+}
+
+type entityBuckets struct {
+	mu      sync.RWMutex
+	seeded  bool                   // false until seeded and again once dropped by prune
+	buckets []map[int64]Aggregator // slot -> bucket start -> partial state
+	counts  map[int64]int          // bucket start -> number of events added
+}
+
+func newBucketIndex(size time.Duration, features []Feature) *bucketIndex {
+	b := &bucketIndex{
+		size:  int64(size),
+		slots: make([]int, len(features)),
+	}
+	for i, f := range features {
+		b.slots[i] = -1
+		switch f.Window.(type) {
+		case *slidingWindow, *betweenWindow, *lifetimeWindow:
+		default:
+			continue
+		}
+		if !isMerger(f.newAggregator()) {
+			continue
+		}
+		b.slots[i] = len(b.features)
+		b.features = append(b.features, f)
+	}
+	if len(b.features) == 0 {
+		return nil
+	}
+	return b
+}
+
+// load returns the bucket state of an entity. State for an entity this
+// index has not seen yet (e.g. events restored from disk) is seeded from
+// the events visible in storage at the current time; aggregate detects the
+// events this misses by comparing event counts.
+func (b *bucketIndex) load(ctx context.Context, storage Storage, entityID string) (*entityBuckets, error) {
+	v, _ := b.entities.LoadOrStore(entityID, &entityBuckets{})
+	eb, _ := v.(*entityBuckets)
+
+	eb.mu.RLock()
+	seeded := eb.seeded
+	eb.mu.RUnlock()
+	if seeded {
+		return eb, nil
+	}
+
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	if eb.seeded {
+		return eb, nil
+	}
+	events, err := storage.Get(ctx, entityID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	eb.counts = make(map[int64]int)
+	eb.buckets = make([]map[int64]Aggregator, len(b.features))
+	for i := range eb.buckets {
+		eb.buckets[i] = make(map[int64]Aggregator)
+	}
+	b.addLocked(eb, events)
+	eb.seeded = true
+	return eb, nil
+}
+
+// add adds events to the buckets of eb. If prune dropped eb in the
+// meantime, the events are only in storage; the entity is seeded again on
+// next access.
+func (b *bucketIndex) add(eb *entityBuckets, events []Event) {
+	eb.mu.Lock()
+	if eb.seeded {
+		b.addLocked(eb, events)
+	}
+	eb.mu.Unlock()
+}
+
+func (b *bucketIndex) addLocked(eb *entityBuckets, events []Event) {
+	for _, e := range events {
+		start := floorDiv(e.Timestamp.UnixNano(), b.size) * b.size
+		eb.counts[start]++
+		for slot, f := range b.features {
+			agg, ok := eb.buckets[slot][start]
+			if !ok {
+				agg = f.newAggregator()
+				eb.buckets[slot][start] = agg
+			}
+			agg.Add(e)
+		}
+	}
+}
+
+// aggregate computes feature i at time at. events are the sorted events
+// returned by storage for the same point in time.
+func (b *bucketIndex) aggregate(eb *entityBuckets, i int, events []Event, at time.Time) (Aggregator, error) {
+	f := b.features[b.slots[i]]
+	agg := f.newAggregator()
+	if len(events) == 0 {
+		return agg, nil
+	}
+
+	// Buckets may hold events storage no longer returns (TTL), so never
+	// reach further back than the oldest visible event.
+	lo := events[0].Timestamp.UnixNano()
+	hi := at.UnixNano()
+	switch w := f.Window.(type) {
+	case *slidingWindow:
+		lo = max(lo, at.Add(-w.duration).UnixNano())
+	case *betweenWindow:
+		lo = max(lo, at.Add(-w.from).UnixNano()+1)
+		hi = at.Add(-w.to).UnixNano()
+	}
+	if lo > hi {
+		return agg, nil
+	}
+
+	first := ceilDiv(lo, b.size) * b.size  // start of the first full bucket
+	end := floorDiv(hi+1, b.size) * b.size // end of the last full bucket
+	if first >= end {
+		addRange(agg, events, lo, hi)
+		return agg, nil
+	}
+
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+	if !eb.seeded {
+		addRange(agg, events, lo, hi)
+		return agg, nil
+	}
+
+	// Add edges and merge buckets in time order so floating-point results
+	// do not depend on map iteration order.
+	buckets := eb.buckets[b.slots[i]]
+	starts := make([]int64, 0, len(buckets))
+	n := 0
+	for start := range buckets {
+		if start >= first && start < end {
+			starts = append(starts, start)
+			n += eb.counts[start]
+		}
+	}
+	if n != searchEvents(events, end)-searchEvents(events, first) {
+		addRange(agg, events, lo, hi)
+		return agg, nil
+	}
+	slices.Sort(starts)
+
+	addRange(agg, events, lo, first-1)
+	m, _ := agg.(Merger)
+	for _, start := range starts {
+		if err := m.Merge(buckets[start]); err != nil {
+			return nil, fmt.Errorf("gofeat: feature %q: %w", f.Name, err)
+		}
+	}
+
+	addRange(agg, events, end, hi)
+	return agg, nil
+}
+
+// prune drops buckets that end at or before cutoff and forgets entities
+// left without buckets.
+func (b *bucketIndex) prune(cutoff time.Time) {
+	limit := cutoff.UnixNano()
+	b.entities.Range(func(key, value any) bool {
+		eb, ok := value.(*entityBuckets)
+		if !ok {
+			return true
+		}
+		eb.mu.Lock()
+		defer eb.mu.Unlock()
+		if !eb.seeded {
+			return true
+		}
+		for start := range eb.counts {
+			if start+b.size <= limit {
+				delete(eb.counts, start)
+			}
+		}
+		for _, buckets := range eb.buckets {
+			for start := range buckets {
+				if start+b.size <= limit {
+					delete(buckets, start)
+				}
+			}
+		}
+		if len(eb.counts) == 0 {
+			eb.seeded, eb.buckets, eb.counts = false, nil, nil
+			b.entities.CompareAndDelete(key, eb)
+		}
+		return true
+	})
+}
+
+// reset forgets all bucket state; entities are re-seeded on next access.
+func (b *bucketIndex) reset() {
+	b.entities.Range(func(key, _ any) bool {
+		b.entities.Delete(key)
+		return true
+	})
+}
+
+// addRange adds events with timestamps in [from, to] (Unix nanoseconds).
+func addRange(agg Aggregator, events []Event, from, to int64) {
+	if from > to {
+		return
+	}
+	for _, e := range events[searchEvents(events, from):] {
+		if e.Timestamp.UnixNano() > to {
+			break
+		}
+		agg.Add(e)
+	}
+}
+
+// searchEvents returns the index of the first event at or after t (Unix
+// nanoseconds).
+func searchEvents(events []Event, t int64) int {
+	return sort.Search(len(events), func(i int) bool {
+		return events[i].Timestamp.UnixNano() >= t
+	})
+}
+
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+func ceilDiv(a, b int64) int64 {
+	return -floorDiv(-a, b)
+}
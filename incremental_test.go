@@ -0,0 +1,249 @@
+package gofeat_test
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+func incrementalFeatures() []gofeat.Feature {
+	return []gofeat.Feature{
+		{Name: "count_1h", Aggregate: gofeat.Count, Window: gofeat.Sliding(time.Hour)},
+		{Name: "sum_90m", Aggregate: gofeat.Sum("amount"), Window: gofeat.Sliding(90 * time.Minute)},
+		{Name: "min_1h", Aggregate: gofeat.Min("amount"), Window: gofeat.Sliding(time.Hour)},
+		{Name: "max_1h", Aggregate: gofeat.Max("amount"), Window: gofeat.Sliding(time.Hour)},
+		{Name: "mean_life", Aggregate: gofeat.Mean("amount")},
+		{Name: "std_life", Aggregate: gofeat.StandardDeviation("amount")},
+		{Name: "distinct_2h", Aggregate: gofeat.DistinctCount("card"), Window: gofeat.Sliding(2 * time.Hour)},
+		{Name: "velocity", Aggregate: gofeat.Velocity(time.Hour), Window: gofeat.Sliding(time.Hour)},
+		{Name: "sum_between", Aggregate: gofeat.Sum("amount"), Window: gofeat.Between(2*time.Hour, 25*time.Minute)},
+		{Name: "last", Aggregate: gofeat.Last("card"), Window: gofeat.Sliding(time.Hour)},
+		{Name: "session", Aggregate: gofeat.SessionCount(time.Minute), Window: gofeat.Sliding(time.Hour)},
+	}
+}
+
+func TestStore_Incremental_MatchesFullScan(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	full, _ := gofeat.New(gofeat.Config{Features: incrementalFeatures()})
+	incr, err := gofeat.New(gofeat.Config{Features: incrementalFeatures(), BucketSize: 10 * time.Minute})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for range 500 {
+		e := gofeat.Event{
+			Timestamp: base.Add(time.Duration(rng.Int63n(int64(6 * time.Hour)))),
+			Data: map[string]any{
+				"amount": float64(rng.Intn(1000)) / 10,
+				"card":   rng.Intn(20),
+			},
+		}
+		full.Push(ctx, "user1", e)
+		incr.Push(ctx, "user1", e)
+	}
+
+	for i := range 100 {
+		at := base.Add(time.Duration(i) * 4 * time.Minute).Add(time.Duration(rng.Int63n(int64(time.Minute))))
+		want, _ := full.GetAt(ctx, "user1", at)
+		got, _ := incr.GetAt(ctx, "user1", at)
+		for name, w := range want.All() {
+			g, _ := got.Any(name)
+			if wf, ok := w.(float64); ok {
+				if math.Abs(wf-g.(float64)) > 1e-9 {
+					t.Errorf("at %v: %s = %v, want %v", at, name, g, w)
+				}
+				continue
+			}
+			if g != w {
+				t.Errorf("at %v: %s = %v, want %v", at, name, g, w)
+			}
+		}
+	}
+}
+
+func TestStore_Incremental_BucketAligned(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store, _ := gofeat.New(gofeat.Config{
+		BucketSize: time.Minute,
+		Features: []gofeat.Feature{
+			{Name: "count", Aggregate: gofeat.Count, Window: gofeat.Sliding(10 * time.Minute)},
+		},
+	})
+	for i := range 30 {
+		store.Push(ctx, "user1", gofeat.Event{Timestamp: base.Add(time.Duration(i) * time.Minute)})
+	}
+
+	// [20m, 30m] includes events at 20..29 minutes
+	result, _ := store.GetAt(ctx, "user1", base.Add(30*time.Minute))
+	if got := result.IntOr("count", -1); got != 10 {
+		t.Errorf("count: got %d, want 10", got)
+	}
+	// Events after the query time are excluded even inside a bucket
+	result, _ = store.GetAt(ctx, "user1", base.Add(5*time.Minute+30*time.Second))
+	if got := result.IntOr("count", -1); got != 6 {
+		t.Errorf("count: got %d, want 6", got)
+	}
+}
+
+func TestStore_Incremental_TTL(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	store, _ := gofeat.New(gofeat.Config{
+		TTL:        time.Hour,
+		BucketSize: time.Minute,
+		Features:   []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count}},
+	})
+	store.Push(ctx, "user1",
+		gofeat.Event{Timestamp: now.Add(-3 * time.Hour)},
+		gofeat.Event{Timestamp: now.Add(-2 * time.Hour)},
+		gofeat.Event{Timestamp: now.Add(-time.Minute)},
+	)
+
+	result, _ := store.Get(ctx, "user1")
+	if got := result.IntOr("count", -1); got != 1 {
+		t.Errorf("count before evict: got %d, want 1", got)
+	}
+	store.Evict(ctx)
+	result, _ = store.Get(ctx, "user1")
+	if got := result.IntOr("count", -1); got != 1 {
+		t.Errorf("count after evict: got %d, want 1", got)
+	}
+}
+
+func TestStore_Incremental_SeedsFromStorage(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	src, _ := gofeat.New(gofeat.Config{Features: []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count}}})
+	for i := range 5 {
+		src.Push(ctx, "user1", gofeat.Event{Timestamp: now.Add(-time.Duration(i) * time.Hour)})
+	}
+	var buf bytes.Buffer
+	src.Snapshot(ctx, &buf)
+
+	store, _ := gofeat.New(gofeat.Config{
+		BucketSize: time.Minute,
+		Features:   []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count}},
+	})
+	if err := store.Restore(ctx, &buf); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	store.Push(ctx, "user1", gofeat.Event{Timestamp: now})
+
+	result, _ := store.Get(ctx, "user1")
+	if got := result.IntOr("count", -1); got != 6 {
+		t.Errorf("count: got %d, want 6", got)
+	}
+}
+
+func TestStore_Incremental_RestoreWithTTL(t *testing.T) {
+	// Buckets are seeded with the events visible now, so a query in the past
+	// must not miss the events the TTL hid at that time
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Minute)
+	features := []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count, Window: gofeat.Sliding(time.Hour)}}
+
+	src, _ := gofeat.New(gofeat.Config{Features: features})
+	for i := range 180 {
+		src.Push(ctx, "user1", gofeat.Event{Timestamp: now.Add(-time.Duration(i) * time.Minute)})
+	}
+	var buf bytes.Buffer
+	if err := src.Snapshot(ctx, &buf); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	store, _ := gofeat.New(gofeat.Config{TTL: time.Hour, BucketSize: time.Minute, Features: features})
+	if err := store.Restore(ctx, &buf); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	tests := []struct {
+		at   time.Time
+		want int
+	}{
+		{now, 60},
+		{now.Add(-90 * time.Minute), 60},
+		{now.Add(-150*time.Minute + 30*time.Second), 30},
+		{now, 60},
+	}
+	for _, tt := range tests {
+		result, err := store.GetAt(ctx, "user1", tt.at)
+		if err != nil {
+			t.Fatalf("GetAt failed: %v", err)
+		}
+		if got := result.IntOr("count", -1); got != tt.want {
+			t.Errorf("at %v: count %d, want %d", now.Sub(tt.at), got, tt.want)
+		}
+	}
+}
+
+// seedObserver counts the storage reads of an entity made while pushing,
+// i.e. the reads that seed its buckets.
+type seedObserver struct {
+	gofeat.NopObserver
+	pushing bool
+	seeds   int
+}
+
+func (o *seedObserver) PushStart(ctx context.Context, _ gofeat.PushOp) context.Context {
+	o.pushing = true
+	return ctx
+}
+
+func (o *seedObserver) PushEnd(context.Context, gofeat.PushOp, gofeat.OpResult) {
+	o.pushing = false
+}
+
+func (o *seedObserver) StorageCall(_ context.Context, call gofeat.StorageCall) {
+	if o.pushing && call.Op == gofeat.StorageOpGet {
+		o.seeds++
+	}
+}
+
+func TestStore_Incremental_EvictPrunesWithStorageTTL(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	storage, err := gofeat.NewFileStorage(t.TempDir(), gofeat.FileStorageOptions{TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+	obs := &seedObserver{}
+	store, _ := gofeat.New(gofeat.Config{
+		Storage:    storage,
+		BucketSize: time.Minute,
+		Observer:   obs,
+		Features:   []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count, Window: gofeat.Sliding(24 * time.Hour)}},
+	})
+	defer store.Close()
+
+	store.Push(ctx, "user1", gofeat.Event{Timestamp: now.Add(-2 * time.Hour)})
+	store.Push(ctx, "user1", gofeat.Event{Timestamp: now.Add(-90 * time.Minute)})
+	if obs.seeds != 1 {
+		t.Fatalf("seeds before evict: got %d, want 1", obs.seeds)
+	}
+	if err := store.Evict(ctx); err != nil {
+		t.Fatalf("Evict failed: %v", err)
+	}
+
+	// All buckets of user1 expired, so its state was dropped and is seeded again
+	store.Push(ctx, "user1", gofeat.Event{Timestamp: now})
+	if obs.seeds != 2 {
+		t.Errorf("seeds after evict: got %d, want 2", obs.seeds)
+	}
+	result, _ := store.GetAt(ctx, "user1", now)
+	if got := result.IntOr("count", -1); got != 1 {
+		t.Errorf("count: got %d, want 1", got)
+	}
+}
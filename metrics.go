@@ -0,0 +1,241 @@
+package gofeat
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Metrics collects latencies and counters of a Store and serves them in the
+// Prometheus text exposition format. It implements http.Handler:
+//
+//	m := gofeat.NewMetrics()
+//	store, err := gofeat.New(gofeat.Config{Features: features, Metrics: m})
+//	http.Handle("/metrics", m)
+//
+// Exported metrics:
+//
+//	gofeat_push_duration_seconds             histogram of Push and Ingest calls
+//	gofeat_get_duration_seconds              histogram of feature computations for one entity
+//	gofeat_feature_compute_duration_seconds  histogram per feature (label feature)
+//	gofeat_events_rejected_total             events of Push and Ingest calls failing validation, e.g. non-UTC timestamps
+//	gofeat_events_evicted_total              events removed by Evict
+//	gofeat_storage_errors_total              failed storage calls (label op: push, get, evict, stats)
+//	gofeat_entities, gofeat_events           storage statistics at scrape time
+//
+// Metrics is an Observer; it can be used by a single Store only, combined
+// with Config.Observer if that is set.
+type Metrics struct {
+	store    atomic.Pointer[Store]
+	buckets  []float64
+	push     *histogram
+	get      *histogram
+	rejected atomic.Uint64
+	evicted  atomic.Uint64
+	errors   map[string]*atomic.Uint64 // by storage operation, fixed at construction
+
+	mu       sync.RWMutex
+	features map[string]*histogram
+}
+
+// NewMetrics returns an empty Metrics. buckets are the upper bounds of the
+// latency histograms in seconds; by default they range from 10µs to 1s.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = []float64{
+			0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005,
+			0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1,
+		}
+	} else {
+		buckets = append([]float64(nil), buckets...)
+		sort.Float64s(buckets)
+	}
+
+	m := &Metrics{
+		buckets:  buckets,
+		push:     newHistogram(buckets),
+		get:      newHistogram(buckets),
+		errors:   make(map[string]*atomic.Uint64),
+		features: make(map[string]*histogram),
+	}
+	for _, op := range []string{StorageOpPush, StorageOpGet, StorageOpEvict, StorageOpStats} {
+		m.errors[op] = new(atomic.Uint64)
+	}
+	return m
+}
+
+// attach binds m to s, the store whose statistics are reported.
+func (m *Metrics) attach(s *Store) error {
+	if !m.store.CompareAndSwap(nil, s) {
+		return errors.New("gofeat: Metrics already used by another Store")
+	}
+	return nil
+}
+
+// Metrics implements Observer to collect its values.
+
+func (m *Metrics) PushStart(ctx context.Context, _ PushOp) context.Context { return ctx }
+
+func (m *Metrics) PushEnd(_ context.Context, op PushOp, res OpResult) {
+	m.push.observe(res.Duration.Seconds())
+	if errors.Is(res.Err, ErrInvalidEvent) {
+		// Calls are all or nothing: every event of the call is rejected
+		m.rejected.Add(uint64(op.Events)) //nolint:gosec // event counts are not negative
+	}
+}
+
+func (m *Metrics) GetStart(ctx context.Context, _ GetOp) context.Context { return ctx }
+
+func (m *Metrics) GetEnd(_ context.Context, _ GetOp, res OpResult) {
+	m.get.observe(res.Duration.Seconds())
+}
+
+func (m *Metrics) StorageCall(_ context.Context, call StorageCall) {
+	if call.Err != nil {
+		if n, ok := m.errors[call.Op]; ok {
+			n.Add(1)
+		}
+	}
+}
+
+func (m *Metrics) FeatureComputed(_ context.Context, f FeatureComputation) {
+	m.mu.RLock()
+	h, ok := m.features[f.Feature]
+	m.mu.RUnlock()
+	if !ok {
+		m.mu.Lock()
+		if h, ok = m.features[f.Feature]; !ok {
+			h = newHistogram(m.buckets)
+			m.features[f.Feature] = h
+		}
+		m.mu.Unlock()
+	}
+	h.observe(f.Duration.Seconds())
+}
+
+func (m *Metrics) Evicted(_ context.Context, ev Eviction) {
+	m.evicted.Add(uint64(ev.Events))
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+
+	m.push.write(bw, "gofeat_push_duration_seconds", "Latency of Push and Ingest calls.", "")
+	m.get.write(bw, "gofeat_get_duration_seconds", "Latency of feature computations for one entity.", "")
+
+	m.mu.RLock()
+	names := make([]string, 0, len(m.features))
+	for name := range m.features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	const featureMetric = "gofeat_feature_compute_duration_seconds"
+	writeHeader(bw, featureMetric, "Time spent aggregating a feature.", "histogram")
+	for _, name := range names {
+		m.features[name].write(bw, featureMetric, "", "feature="+quoteLabel(name))
+	}
+	m.mu.RUnlock()
+
+	writeHeader(bw, "gofeat_events_rejected_total", "Events rejected by validation.", "counter")
+	fmt.Fprintf(bw, "gofeat_events_rejected_total %d\n", m.rejected.Load())
+	writeHeader(bw, "gofeat_events_evicted_total", "Events removed by Evict.", "counter")
+	fmt.Fprintf(bw, "gofeat_events_evicted_total %d\n", m.evicted.Load())
+	writeHeader(bw, "gofeat_storage_errors_total", "Failed storage calls.", "counter")
+	for _, op := range []string{StorageOpEvict, StorageOpGet, StorageOpPush, StorageOpStats} {
+		fmt.Fprintf(bw, "gofeat_storage_errors_total{op=%s} %d\n", quoteLabel(op), m.errors[op].Load())
+	}
+
+	if s := m.store.Load(); s != nil {
+		if stats, err := s.Stats(r.Context()); err == nil {
+			writeHeader(bw, "gofeat_entities", "Entities in storage.", "gauge")
+			fmt.Fprintf(bw, "gofeat_entities %d\n", stats.Entities)
+			writeHeader(bw, "gofeat_events", "Events in storage.", "gauge")
+			fmt.Fprintf(bw, "gofeat_events %d\n", stats.TotalEvents)
+		}
+	}
+	_ = bw.Flush()
+}
+
+func writeHeader(w *bufio.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// quoteLabel quotes a label value for the text format, which only escapes
+// backslashes, double quotes and line feeds, unlike Go's %q.
+func quoteLabel(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// histogram is a lock-free Prometheus histogram.
+type histogram struct {
+	bounds []float64
+	counts []atomic.Uint64 // per bucket, the last one is +Inf
+	sum    atomic.Uint64   // float64 bits
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]atomic.Uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(v float64) {
+	h.counts[sort.SearchFloat64s(h.bounds, v)].Add(1)
+	for {
+		old := h.sum.Load()
+		if h.sum.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// write writes the samples of h, preceded by HELP and TYPE lines if help is
+// set. labels are added to every sample.
+func (h *histogram) write(w *bufio.Writer, name, help, labels string) {
+	if help != "" {
+		writeHeader(w, name, help, "histogram")
+	}
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+
+	var cumulative uint64
+	for i := range h.counts {
+		cumulative += h.counts[i].Load()
+		le := "+Inf"
+		if i < len(h.bounds) {
+			le = strconv.FormatFloat(h.bounds[i], 'g', -1, 64)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s%sle=%s} %d\n", name, labels, sep, quoteLabel(le), cumulative)
+	}
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s_sum%s %s\n", name, labels, strconv.FormatFloat(math.Float64frombits(h.sum.Load()), 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count%s %d\n", name, labels, cumulative)
+}
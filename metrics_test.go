@@ -0,0 +1,172 @@
+package gofeat_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+// scrape returns the metrics served by m as lines.
+func scrape(t *testing.T, m *gofeat.Metrics) []string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("content type %q", ct)
+	}
+	return strings.Split(rec.Body.String(), "\n")
+}
+
+func hasLine(lines []string, want string) bool {
+	for _, line := range lines {
+		if line == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	m := gofeat.NewMetrics(0.001, 1)
+	store, err := gofeat.New(gofeat.Config{
+		TTL: time.Hour,
+		Features: []gofeat.Feature{
+			{Name: "count", Aggregate: gofeat.Count},
+			{Name: `sum"amount`, Aggregate: gofeat.Sum("amount")},
+			{Name: "tab\tcafé\\", Aggregate: gofeat.Count},
+		},
+		Metrics: m,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	now := time.Now().UTC()
+	old := now.Add(-2 * time.Hour)
+	if err := store.Push(ctx, "u1", gofeat.Event{Timestamp: now}, gofeat.Event{Timestamp: old}); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if err := store.Push(ctx, "u2", gofeat.Event{Timestamp: old}); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if err := store.Push(ctx, "u1", gofeat.Event{Timestamp: now.In(time.FixedZone("X", 3600))}); err == nil {
+		t.Fatal("expected non-UTC event to be rejected")
+	}
+	for range 3 {
+		if _, err := store.Get(ctx, "u1"); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+	}
+	if err := store.Evict(ctx); err != nil {
+		t.Fatalf("Evict failed: %v", err)
+	}
+
+	lines := scrape(t, m)
+	for _, want := range []string{
+		"# TYPE gofeat_push_duration_seconds histogram",
+		`gofeat_push_duration_seconds_bucket{le="+Inf"} 3`,
+		"gofeat_push_duration_seconds_count 3",
+		`gofeat_get_duration_seconds_bucket{le="+Inf"} 3`,
+		"# TYPE gofeat_feature_compute_duration_seconds histogram",
+		`gofeat_feature_compute_duration_seconds_count{feature="count"} 3`,
+		`gofeat_feature_compute_duration_seconds_bucket{feature="sum\"amount",le="+Inf"} 3`,
+		"gofeat_feature_compute_duration_seconds_count{feature=\"tab\tcafé\\\\\"} 3",
+		"gofeat_events_rejected_total 1",
+		"gofeat_events_evicted_total 2",
+		`gofeat_storage_errors_total{op="get"} 0`,
+		"gofeat_entities 2", // u2 has no events left but is still known
+		"gofeat_events 1",
+	} {
+		if !hasLine(lines, want) {
+			t.Errorf("missing %q in\n%s", want, strings.Join(lines, "\n"))
+		}
+	}
+}
+
+func TestMetrics_RejectedEvents(t *testing.T) {
+	m := gofeat.NewMetrics()
+	store, err := gofeat.New(gofeat.Config{
+		Entities: []gofeat.EntityType{{Name: "card", Field: "card"}},
+		Features: []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count}},
+		Metrics:  m,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	// A call with an invalid event rejects all its events
+	nonUTC := gofeat.Event{Timestamp: time.Now().In(time.FixedZone("X", 3600)), Data: map[string]any{"card": "c1"}}
+	valid := gofeat.Event{Timestamp: time.Now().UTC(), Data: map[string]any{"card": "c1"}}
+	_ = store.Push(context.Background(), "u1", nonUTC)
+	_ = store.Ingest(context.Background(), valid, nonUTC)
+
+	if lines := scrape(t, m); !hasLine(lines, "gofeat_events_rejected_total 3") {
+		t.Errorf("expected 3 rejected events in\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+var errStorageDown = errors.New("storage down")
+
+// failingStorage fails every call.
+type failingStorage struct{}
+
+func (failingStorage) Push(context.Context, string, ...gofeat.Event) error { return errStorageDown }
+func (failingStorage) Get(context.Context, string, time.Time) ([]gofeat.Event, error) {
+	return nil, errStorageDown
+}
+func (failingStorage) Evict(context.Context) error { return errStorageDown }
+func (failingStorage) Stats(context.Context) (gofeat.StorageStats, error) {
+	return gofeat.StorageStats{}, errStorageDown
+}
+func (failingStorage) Close() error { return nil }
+
+func TestMetrics_StorageErrors(t *testing.T) {
+	ctx := context.Background()
+	m := gofeat.NewMetrics()
+	store, err := gofeat.New(gofeat.Config{
+		Features: []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count}},
+		Storage:  failingStorage{},
+		Metrics:  m,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	_ = store.Push(ctx, "u1", gofeat.Event{Timestamp: time.Now().UTC()})
+	_, _ = store.Get(ctx, "u1")
+	_, _ = store.Get(ctx, "u1")
+	_ = store.Evict(ctx)
+
+	lines := scrape(t, m)
+	for _, want := range []string{
+		`gofeat_storage_errors_total{op="push"} 1`,
+		`gofeat_storage_errors_total{op="get"} 2`,
+		`gofeat_storage_errors_total{op="evict"} 1`,
+	} {
+		if !hasLine(lines, want) {
+			t.Errorf("missing %q in\n%s", want, strings.Join(lines, "\n"))
+		}
+	}
+	// Stats fail as well: the gauges are left out
+	if hasLine(lines, "# TYPE gofeat_entities gauge") {
+		t.Error("unexpected gauges without storage stats")
+	}
+}
+
+func TestMetrics_SingleStore(t *testing.T) {
+	m := gofeat.NewMetrics()
+	cfg := gofeat.Config{Features: []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count}}, Metrics: m}
+	if _, err := gofeat.New(cfg); err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := gofeat.New(cfg); err == nil {
+		t.Error("expected error when sharing Metrics between stores")
+	}
+}
@@ -0,0 +1,212 @@
+package gofeat
+
+import (
+	"context"
+	"time"
+)
+
+// Observer receives callbacks about the operations of a Store, e.g. to trace
+// or log them. Start callbacks return the context passed to the callbacks
+// nested in the operation and to its end callback, so that a tracer can
+// attach spans to it:
+//
+//	GetStart -> StorageCall (get) -> FeatureComputed (per feature) -> GetEnd
+//
+// Callbacks run synchronously on the calling goroutine and must be safe for
+// concurrent use. Embed NopObserver to implement only some of them.
+type Observer interface {
+	PushStart(ctx context.Context, op PushOp) context.Context
+	PushEnd(ctx context.Context, op PushOp, res OpResult)
+	GetStart(ctx context.Context, op GetOp) context.Context
+	GetEnd(ctx context.Context, op GetOp, res OpResult)
+	StorageCall(ctx context.Context, call StorageCall)
+	FeatureComputed(ctx context.Context, f FeatureComputation)
+	Evicted(ctx context.Context, ev Eviction)
+}
+
+// PushOp describes a Push or Ingest call.
+type PushOp struct {
+	EntityID string // empty for Ingest
+	Ingest   bool
+	Events   int
+}
+
+// GetOp describes the computation of the features of one entity, by GetAt,
+// GetEntityAt or BuildTrainingSet.
+type GetOp struct {
+	EntityType string // empty for features without an entity type
+	EntityID   string
+	At         time.Time
+}
+
+// OpResult is the outcome of a Push, Ingest or Get operation. Err wraps
+// ErrInvalidEvent for events rejected by validation.
+type OpResult struct {
+	Duration time.Duration
+	Err      error
+}
+
+// Storage operations reported in StorageCall.Op.
+const (
+	StorageOpPush  = "push"
+	StorageOpGet   = "get"
+	StorageOpEvict = "evict"
+	StorageOpStats = "stats"
+)
+
+// StorageCall describes a call to the Storage.
+type StorageCall struct {
+	Op       string
+	Key      string // storage key of the entity, empty for evict, stats and batched pushes
+	Events   int    // events pushed, returned or evicted
+	Duration time.Duration
+	Err      error
+}
+
+// FeatureComputation describes the aggregation of one feature for one
+// entity. Derived features are not reported.
+type FeatureComputation struct {
+	Feature    string
+	EntityType string
+	EntityID   string
+	Duration   time.Duration
+}
+
+// Eviction describes an Evict call.
+type Eviction struct {
+	Events   int64 // events removed; estimated from Stats for storages that are not an EvictCounter
+	Duration time.Duration
+	Err      error
+}
+
+// NopObserver implements Observer with callbacks that do nothing.
+type NopObserver struct{}
+
+func (NopObserver) PushStart(ctx context.Context, _ PushOp) context.Context { return ctx }
+func (NopObserver) PushEnd(context.Context, PushOp, OpResult)               {}
+func (NopObserver) GetStart(ctx context.Context, _ GetOp) context.Context   { return ctx }
+func (NopObserver) GetEnd(context.Context, GetOp, OpResult)                 {}
+func (NopObserver) StorageCall(context.Context, StorageCall)                {}
+func (NopObserver) FeatureComputed(context.Context, FeatureComputation)     {}
+func (NopObserver) Evicted(context.Context, Eviction)                       {}
+
+// MultiObserver returns an Observer calling each of observers in order.
+// Contexts returned by start callbacks are passed on to the next observer.
+// Nil observers are skipped.
+func MultiObserver(observers ...Observer) Observer {
+	var m multiObserver
+	for _, o := range observers {
+		if o != nil {
+			m = append(m, o)
+		}
+	}
+	switch len(m) {
+	case 0:
+		return NopObserver{}
+	case 1:
+		return m[0]
+	default:
+		return m
+	}
+}
+
+type multiObserver []Observer
+
+func (m multiObserver) PushStart(ctx context.Context, op PushOp) context.Context {
+	for _, o := range m {
+		ctx = o.PushStart(ctx, op)
+	}
+	return ctx
+}
+
+func (m multiObserver) PushEnd(ctx context.Context, op PushOp, res OpResult) {
+	for _, o := range m {
+		o.PushEnd(ctx, op, res)
+	}
+}
+
+func (m multiObserver) GetStart(ctx context.Context, op GetOp) context.Context {
+	for _, o := range m {
+		ctx = o.GetStart(ctx, op)
+	}
+	return ctx
+}
+
+func (m multiObserver) GetEnd(ctx context.Context, op GetOp, res OpResult) {
+	for _, o := range m {
+		o.GetEnd(ctx, op, res)
+	}
+}
+
+func (m multiObserver) StorageCall(ctx context.Context, call StorageCall) {
+	for _, o := range m {
+		o.StorageCall(ctx, call)
+	}
+}
+
+func (m multiObserver) FeatureComputed(ctx context.Context, f FeatureComputation) {
+	for _, o := range m {
+		o.FeatureComputed(ctx, f)
+	}
+}
+
+func (m multiObserver) Evicted(ctx context.Context, ev Eviction) {
+	for _, o := range m {
+		o.Evicted(ctx, ev)
+	}
+}
+
+// observedStorage reports the calls made to a Storage to an Observer.
+type observedStorage struct {
+	Storage
+	obs Observer
+}
+
+func (o observedStorage) Push(ctx context.Context, entityID string, events ...Event) error {
+	start := time.Now()
+	err := o.Storage.Push(ctx, entityID, events...)
+	o.obs.StorageCall(ctx, StorageCall{Op: StorageOpPush, Key: entityID, Events: len(events), Duration: time.Since(start), Err: err})
+	return err
+}
+
+func (o observedStorage) Get(ctx context.Context, entityID string, at time.Time) ([]Event, error) {
+	start := time.Now()
+	events, err := o.Storage.Get(ctx, entityID, at)
+	o.obs.StorageCall(ctx, StorageCall{Op: StorageOpGet, Key: entityID, Events: len(events), Duration: time.Since(start), Err: err})
+	return events, err
+}
+
+func (o observedStorage) Evict(ctx context.Context) error {
+	start := time.Now()
+	err := o.Storage.Evict(ctx)
+	o.obs.StorageCall(ctx, StorageCall{Op: StorageOpEvict, Duration: time.Since(start), Err: err})
+	return err
+}
+
+func (o observedStorage) EvictCount(ctx context.Context) (int64, error) {
+	ec, _ := o.Storage.(EvictCounter)
+	start := time.Now()
+	n, err := ec.EvictCount(ctx)
+	o.obs.StorageCall(ctx, StorageCall{Op: StorageOpEvict, Events: int(n), Duration: time.Since(start), Err: err})
+	return n, err
+}
+
+func (o observedStorage) Stats(ctx context.Context) (StorageStats, error) {
+	start := time.Now()
+	stats, err := o.Storage.Stats(ctx)
+	o.obs.StorageCall(ctx, StorageCall{Op: StorageOpStats, Duration: time.Since(start), Err: err})
+	return stats, err
+}
+
+// PushBatch is only called if the wrapped storage implements BatchPusher.
+func (o observedStorage) PushBatch(ctx context.Context, batch map[string][]Event) error {
+	bp, _ := o.Storage.(BatchPusher)
+	start := time.Now()
+	err := bp.PushBatch(ctx, batch)
+	n := 0
+	for _, events := range batch {
+		n += len(events)
+	}
+	o.obs.StorageCall(ctx, StorageCall{Op: StorageOpPush, Events: n, Duration: time.Since(start), Err: err})
+	return err
+}
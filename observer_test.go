@@ -0,0 +1,315 @@
+package gofeat_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+type spanKey struct{}
+
+// recordingObserver records callbacks as strings, prefixed with the span
+// started by the enclosing operation.
+type recordingObserver struct {
+	gofeat.NopObserver
+	name string
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recordingObserver) record(ctx context.Context, format string, args ...any) {
+	span, _ := ctx.Value(spanKey{}).(string)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, span+": "+fmt.Sprintf(format, args...))
+}
+
+func (r *recordingObserver) PushStart(ctx context.Context, op gofeat.PushOp) context.Context {
+	span, _ := ctx.Value(spanKey{}).(string)
+	return context.WithValue(ctx, spanKey{}, span+r.name+".push")
+}
+
+func (r *recordingObserver) PushEnd(ctx context.Context, op gofeat.PushOp, res gofeat.OpResult) {
+	r.record(ctx, "push end %q ingest=%v events=%d invalid=%v", op.EntityID, op.Ingest, op.Events,
+		errors.Is(res.Err, gofeat.ErrInvalidEvent))
+}
+
+func (r *recordingObserver) GetStart(ctx context.Context, op gofeat.GetOp) context.Context {
+	span, _ := ctx.Value(spanKey{}).(string)
+	return context.WithValue(ctx, spanKey{}, span+r.name+".get")
+}
+
+func (r *recordingObserver) GetEnd(ctx context.Context, op gofeat.GetOp, res gofeat.OpResult) {
+	r.record(ctx, "get end %s/%s err=%v", op.EntityType, op.EntityID, res.Err)
+}
+
+func (r *recordingObserver) StorageCall(ctx context.Context, call gofeat.StorageCall) {
+	r.record(ctx, "storage %s %q events=%d", call.Op, call.Key, call.Events)
+}
+
+func (r *recordingObserver) FeatureComputed(ctx context.Context, f gofeat.FeatureComputation) {
+	r.record(ctx, "feature %s %s/%s", f.Feature, f.EntityType, f.EntityID)
+}
+
+func (r *recordingObserver) Evicted(ctx context.Context, ev gofeat.Eviction) {
+	r.record(ctx, "evicted %d", ev.Events)
+}
+
+func (r *recordingObserver) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	calls := r.calls
+	r.calls = nil
+	return calls
+}
+
+func TestObserver(t *testing.T) {
+	ctx := context.Background()
+	obs := &recordingObserver{name: "a"}
+	store, err := gofeat.New(gofeat.Config{
+		TTL:      time.Hour,
+		Entities: []gofeat.EntityType{{Name: "card", Field: "card"}},
+		Features: []gofeat.Feature{
+			{Name: "count", Aggregate: gofeat.Count},
+			{Name: "sum", Aggregate: gofeat.Sum("amount")},
+			{Name: "card_count", Aggregate: gofeat.Count, Entity: "card"},
+		},
+		Derived:  []gofeat.DerivedFeature{{Name: "avg", Expr: "sum / count"}},
+		Observer: obs,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	now := time.Now().UTC()
+	e := gofeat.Event{Timestamp: now, Data: map[string]any{"amount": 5.0, "card": "c1"}}
+	old := gofeat.Event{Timestamp: now.Add(-2 * time.Hour)}
+
+	tests := []struct {
+		name string
+		run  func() error
+		want []string
+	}{
+		{
+			name: "push",
+			run:  func() error { return store.Push(ctx, "u1", e, old) },
+			want: []string{
+				`a.push: storage push "u1" events=2`,
+				`a.push: push end "u1" ingest=false events=2 invalid=false`,
+			},
+		},
+		{
+			name: "invalid event",
+			run: func() error {
+				_ = store.Push(ctx, "u1", gofeat.Event{Timestamp: now.In(time.FixedZone("X", 3600))})
+				return nil
+			},
+			want: []string{`a.push: push end "u1" ingest=false events=1 invalid=true`},
+		},
+		{
+			name: "ingest",
+			run:  func() error { return store.Ingest(ctx, e) },
+			want: []string{
+				`a.push: storage push "" events=1`,
+				`a.push: push end "" ingest=true events=1 invalid=false`,
+			},
+		},
+		{
+			name: "get",
+			run: func() error {
+				_, err := store.Get(ctx, "u1")
+				return err
+			},
+			want: []string{
+				`a.get: storage get "u1" events=1`,
+				`a.get: feature count /u1`,
+				`a.get: feature sum /u1`,
+				`a.get: get end /u1 err=<nil>`,
+			},
+		},
+		{
+			name: "get entity",
+			run: func() error {
+				_, err := store.GetEntity(ctx, "card", "c1")
+				return err
+			},
+			want: []string{
+				`a.get: storage get "card/c1" events=1`,
+				`a.get: feature card_count card/c1`,
+				`a.get: get end card/c1 err=<nil>`,
+			},
+		},
+		{
+			name: "evict",
+			run:  func() error { return store.Evict(ctx) },
+			want: []string{
+				`: storage evict "" events=1`,
+				`: evicted 1`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+			if got := obs.take(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("calls got\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+// evictedObserver records the counts of Evicted.
+type evictedObserver struct {
+	gofeat.NopObserver
+	events []int64
+}
+
+func (o *evictedObserver) Evicted(_ context.Context, ev gofeat.Eviction) {
+	o.events = append(o.events, ev.Events)
+}
+
+func TestObserver_EvictedCount(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name    string
+		storage gofeat.Storage
+	}{
+		{name: "evict counter", storage: gofeat.NewMemoryStorage(time.Hour)},
+		// Embedding hides EvictCount, so the count comes from Stats
+		{name: "other storage", storage: struct{ gofeat.Storage }{gofeat.NewMemoryStorage(time.Hour)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obs := &evictedObserver{}
+			store, err := gofeat.New(gofeat.Config{
+				Storage:  tt.storage,
+				Features: []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count}},
+				Observer: obs,
+			})
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			for i := range 3 {
+				store.Push(ctx, strconv.Itoa(i), gofeat.Event{Timestamp: now.Add(-2 * time.Hour)}, gofeat.Event{Timestamp: now})
+			}
+			if err := store.Evict(ctx); err != nil {
+				t.Fatalf("Evict failed: %v", err)
+			}
+			if !reflect.DeepEqual(obs.events, []int64{3}) {
+				t.Errorf("evicted got %v, want [3]", obs.events)
+			}
+		})
+	}
+}
+
+func TestObserver_EvictedCount_ConcurrentPush(t *testing.T) {
+	// Pushes during Evict must not change the reported count
+	ctx := context.Background()
+	now := time.Now().UTC()
+	obs := &evictedObserver{}
+	store, _ := gofeat.New(gofeat.Config{
+		TTL:      time.Hour,
+		Features: []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count}},
+		Observer: obs,
+	})
+
+	const entities = 10000
+	for i := range entities {
+		store.Push(ctx, strconv.Itoa(i), gofeat.Event{Timestamp: now.Add(-2 * time.Hour)})
+	}
+
+	stop := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			store.Push(ctx, strconv.Itoa(i%entities), gofeat.Event{Timestamp: now})
+			if i == 0 {
+				close(started)
+			}
+		}
+	}()
+	<-started
+	err := store.Evict(ctx)
+	close(stop)
+	<-done
+	if err != nil {
+		t.Fatalf("Evict failed: %v", err)
+	}
+	if !reflect.DeepEqual(obs.events, []int64{entities}) {
+		t.Errorf("evicted got %v, want [%d]", obs.events, entities)
+	}
+}
+
+func TestMultiObserver(t *testing.T) {
+	a := &recordingObserver{name: "a"}
+	b := &recordingObserver{name: "b"}
+	store, err := gofeat.New(gofeat.Config{
+		Features: []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count}},
+		Observer: gofeat.MultiObserver(a, nil, b),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := store.Get(context.Background(), "u1"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	// b sees the context returned by a
+	want := []string{`a.getb.get: storage get "u1" events=0`, `a.getb.get: feature count /u1`, `a.getb.get: get end /u1 err=<nil>`}
+	if got := a.take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("a got %q, want %q", got, want)
+	}
+	if got := b.take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("b got %q, want %q", got, want)
+	}
+
+	if _, ok := gofeat.MultiObserver().(gofeat.NopObserver); !ok {
+		t.Error("empty MultiObserver should be a NopObserver")
+	}
+	if got := gofeat.MultiObserver(nil, a); got != a {
+		t.Error("MultiObserver of one observer should return it")
+	}
+}
+
+func TestObserver_WithMetrics(t *testing.T) {
+	obs := &recordingObserver{name: "a"}
+	m := gofeat.NewMetrics()
+	store, err := gofeat.New(gofeat.Config{
+		Features: []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count}},
+		Observer: obs,
+		Metrics:  m,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := store.Push(context.Background(), "u1", gofeat.Event{Timestamp: time.Now().UTC()}); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if got := obs.take(); len(got) != 2 {
+		t.Errorf("observer calls got %q", got)
+	}
+	if lines := scrape(t, m); !hasLine(lines, "gofeat_push_duration_seconds_count 1") {
+		t.Error("push not counted by metrics")
+	}
+}
@@ -0,0 +1,186 @@
+package gofeat
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Predicate reports whether an event should be aggregated.
+type Predicate func(e Event) bool
+
+// Where wraps an aggregator so that it only sees events matching pred.
+// Unlike Feature.Filter it is applied after the window, which only matters
+// for count-based windows such as LastN.
+func Where(pred Predicate, factory AggregatorFactory) AggregatorFactory {
+	return func() Aggregator {
+		return &filterAgg{pred: pred, inner: factory()}
+	}
+}
+
+type filterAgg struct {
+	pred  Predicate
+	inner Aggregator
+}
+
+func (a *filterAgg) Add(e Event) {
+	if a.pred(e) {
+		a.inner.Add(e)
+	}
+}
+
+func (a *filterAgg) Result() any { return a.inner.Result() }
+
+func (a *filterAgg) ResultAt(t time.Time) any { return resultAt(a.inner, t) }
+
+func (a *filterAgg) Merge(other Aggregator) error {
+	o, ok := other.(*filterAgg)
+	m, okM := a.inner.(Merger)
+	if !ok || !okM {
+		return mergeError(a, other)
+	}
+	return m.Merge(o.inner)
+}
+
+func (a *filterAgg) MarshalState() ([]byte, error) {
+	m, ok := a.inner.(StateMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrStateUnsupported, a.inner)
+	}
+	return m.MarshalState()
+}
+
+func (a *filterAgg) UnmarshalState(data []byte) error {
+	m, ok := a.inner.(StateMarshaler)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrStateUnsupported, a.inner)
+	}
+	return m.UnmarshalState(data)
+}
+
+func (a *filterAgg) mergeable() bool {
+	return isMerger(a.inner)
+}
+
+// isMerger reports whether agg can actually merge, looking through
+// wrappers that implement Merger only when the wrapped aggregator does.
+func isMerger(agg Aggregator) bool {
+	if w, ok := agg.(interface{ mergeable() bool }); ok {
+		return w.mergeable()
+	}
+	_, ok := agg.(Merger)
+	return ok
+}
+
+// filterEvents returns the events matching pred.
+func filterEvents(events []Event, pred Predicate) []Event {
+	filtered := make([]Event, 0, len(events))
+	for _, e := range events {
+		if pred(e) {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// FieldExists matches events that have the field set.
+func FieldExists(field string) Predicate {
+	return func(e Event) bool {
+		_, ok := e.Data[field]
+		return ok
+	}
+}
+
+// FieldEquals matches events whose field equals value.
+// Numbers are compared by value, so 1 matches 1.0.
+func FieldEquals(field string, value any) Predicate {
+	return func(e Event) bool {
+		v, ok := e.Data[field]
+		return ok && valuesEqual(v, value)
+	}
+}
+
+// FieldIn matches events whose field equals any of values.
+func FieldIn(field string, values ...any) Predicate {
+	return func(e Event) bool {
+		v, ok := e.Data[field]
+		if !ok {
+			return false
+		}
+		for _, want := range values {
+			if valuesEqual(v, want) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FieldGreaterThan matches events whose numeric field is greater than threshold.
+func FieldGreaterThan(field string, threshold float64) Predicate {
+	return func(e Event) bool {
+		f, ok := toFloat64(e.Data[field])
+		return ok && f > threshold
+	}
+}
+
+// FieldsEqual matches events where both fields are set and equal, e.g.
+// Not(FieldsEqual("country", "home_country")) for foreign transactions.
+func FieldsEqual(a, b string) Predicate {
+	return func(e Event) bool {
+		va, okA := e.Data[a]
+		vb, okB := e.Data[b]
+		return okA && okB && valuesEqual(va, vb)
+	}
+}
+
+// And matches events matching all predicates.
+func And(preds ...Predicate) Predicate {
+	return func(e Event) bool {
+		for _, p := range preds {
+			if !p(e) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or matches events matching any of the predicates.
+func Or(preds ...Predicate) Predicate {
+	return func(e Event) bool {
+		for _, p := range preds {
+			if p(e) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not inverts a predicate.
+func Not(pred Predicate) Predicate {
+	return func(e Event) bool {
+		return !pred(e)
+	}
+}
+
+func valuesEqual(a, b any) bool {
+	fa, okA := toFloat64(a)
+	fb, okB := toFloat64(b)
+	if okA && okB {
+		return fa == fb
+	}
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		return ok && ta.Equal(tb)
+	}
+	if a == nil || b == nil {
+		return a == b
+	}
+	ra, rb := reflect.TypeOf(a), reflect.TypeOf(b)
+	if ra != rb || !ra.Comparable() {
+		return false
+	}
+	return a == b
+}
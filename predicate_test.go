@@ -0,0 +1,133 @@
+package gofeat_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+func TestPredicates(t *testing.T) {
+	e := gofeat.Event{Data: map[string]any{
+		"status":       "declined",
+		"amount":       150.0,
+		"attempts":     3,
+		"country":      "DE",
+		"home_country": "US",
+		"tags":         []string{"a"},
+	}}
+
+	tests := []struct {
+		name string
+		pred gofeat.Predicate
+		want bool
+	}{
+		{name: "exists", pred: gofeat.FieldExists("status"), want: true},
+		{name: "not exists", pred: gofeat.FieldExists("missing"), want: false},
+		{name: "equals string", pred: gofeat.FieldEquals("status", "declined"), want: true},
+		{name: "equals other string", pred: gofeat.FieldEquals("status", "approved"), want: false},
+		{name: "equals numeric across types", pred: gofeat.FieldEquals("attempts", 3.0), want: true},
+		{name: "equals missing field", pred: gofeat.FieldEquals("missing", nil), want: false},
+		{name: "equals uncomparable", pred: gofeat.FieldEquals("tags", "a"), want: false},
+		{name: "in", pred: gofeat.FieldIn("country", "FR", "DE"), want: true},
+		{name: "not in", pred: gofeat.FieldIn("country", "FR", "US"), want: false},
+		{name: "greater than", pred: gofeat.FieldGreaterThan("amount", 100), want: true},
+		{name: "not greater than", pred: gofeat.FieldGreaterThan("amount", 150), want: false},
+		{name: "greater than non numeric", pred: gofeat.FieldGreaterThan("status", 0), want: false},
+		{name: "fields equal", pred: gofeat.FieldsEqual("country", "home_country"), want: false},
+		{name: "and", pred: gofeat.And(gofeat.FieldExists("status"), gofeat.FieldGreaterThan("amount", 100)), want: true},
+		{name: "and fails", pred: gofeat.And(gofeat.FieldExists("status"), gofeat.FieldExists("missing")), want: false},
+		{name: "or", pred: gofeat.Or(gofeat.FieldExists("missing"), gofeat.FieldEquals("country", "DE")), want: true},
+		{name: "empty and", pred: gofeat.And(), want: true},
+		{name: "empty or", pred: gofeat.Or(), want: false},
+		{name: "not", pred: gofeat.Not(gofeat.FieldsEqual("country", "home_country")), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pred(e); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWhere(t *testing.T) {
+	agg := gofeat.Where(gofeat.FieldEquals("status", "declined"), gofeat.Sum("amount"))()
+	agg.Add(gofeat.Event{Data: map[string]any{"status": "declined", "amount": 10.0}})
+	agg.Add(gofeat.Event{Data: map[string]any{"status": "approved", "amount": 100.0}})
+	agg.Add(gofeat.Event{Data: map[string]any{"status": "declined", "amount": 5.0}})
+
+	if got := agg.Result(); got != 15.0 {
+		t.Errorf("sum of declined: got %v, want 15", got)
+	}
+
+	other := gofeat.Where(gofeat.FieldEquals("status", "declined"), gofeat.Sum("amount"))()
+	other.Add(gofeat.Event{Data: map[string]any{"status": "declined", "amount": 1.0}})
+	if err := agg.(gofeat.Merger).Merge(other); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if got := agg.Result(); got != 16.0 {
+		t.Errorf("merged sum: got %v, want 16", got)
+	}
+}
+
+func TestWhere_StateUnsupported(t *testing.T) {
+	agg := gofeat.Where(gofeat.FieldExists("amount"), gofeat.SessionCount(time.Minute))()
+	sm := agg.(gofeat.StateMarshaler)
+
+	if _, err := sm.MarshalState(); !errors.Is(err, gofeat.ErrStateUnsupported) || !strings.Contains(err.Error(), "sessionAgg") {
+		t.Errorf("MarshalState: expected ErrStateUnsupported naming the aggregator, got %v", err)
+	}
+	if err := sm.UnmarshalState(nil); !errors.Is(err, gofeat.ErrStateUnsupported) {
+		t.Errorf("UnmarshalState: expected ErrStateUnsupported, got %v", err)
+	}
+}
+
+func TestStore_FilteredFeatures(t *testing.T) {
+	declined := gofeat.FieldEquals("status", "declined")
+	features := func() []gofeat.Feature {
+		return []gofeat.Feature{
+			{Name: "declined_1h", Aggregate: gofeat.Count, Window: gofeat.Sliding(time.Hour), Filter: declined},
+			{Name: "foreign_sum", Aggregate: gofeat.Sum("amount"), Filter: gofeat.Not(gofeat.FieldsEqual("country", "home_country"))},
+			// Filter applies before the window: last 2 declined transactions
+			{Name: "declined_last_2", Aggregate: gofeat.Sum("amount"), Window: gofeat.LastN(2), Filter: declined},
+			// Where applies after the window: declined among the last 2 transactions
+			{Name: "last_2_declined", Aggregate: gofeat.Where(declined, gofeat.Sum("amount")), Window: gofeat.LastN(2)},
+		}
+	}
+
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []gofeat.Event{
+		{Timestamp: now.Add(-4 * time.Hour), Data: map[string]any{"status": "declined", "amount": 1.0, "country": "US", "home_country": "US"}},
+		{Timestamp: now.Add(-50 * time.Minute), Data: map[string]any{"status": "declined", "amount": 2.0, "country": "DE", "home_country": "US"}},
+		{Timestamp: now.Add(-20 * time.Minute), Data: map[string]any{"status": "approved", "amount": 4.0, "country": "DE", "home_country": "US"}},
+		{Timestamp: now.Add(-10 * time.Minute), Data: map[string]any{"status": "declined", "amount": 8.0, "country": "US", "home_country": "US"}},
+		{Timestamp: now.Add(-5 * time.Minute), Data: map[string]any{"status": "approved", "amount": 16.0, "country": "FR", "home_country": "US"}},
+	}
+
+	for _, bucket := range []time.Duration{0, time.Minute} {
+		store, err := gofeat.New(gofeat.Config{Features: features(), BucketSize: bucket})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		store.Push(ctx, "user1", events...)
+
+		result, _ := store.GetAt(ctx, "user1", now)
+		if got := result.IntOr("declined_1h", -1); got != 2 {
+			t.Errorf("bucket %v: declined_1h got %d, want 2", bucket, got)
+		}
+		if got := result.FloatOr("foreign_sum", -1); got != 22 {
+			t.Errorf("bucket %v: foreign_sum got %v, want 22", bucket, got)
+		}
+		if got := result.FloatOr("declined_last_2", -1); got != 10 {
+			t.Errorf("bucket %v: declined_last_2 got %v, want 10", bucket, got)
+		}
+		if got := result.FloatOr("last_2_declined", -1); got != 8 {
+			t.Errorf("bucket %v: last_2_declined got %v, want 8", bucket, got)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package gofeat
+
+import (
+	"context"
+	"reflect"
+	"sort"
+)
+
+// FeatureDiff describes how UpdateFeatures changed the feature set.
+// Names are sorted; features of an entity type are qualified with it, as
+// in "card/count".
+type FeatureDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// Empty reports whether the update did not change any feature.
+func (d FeatureDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// UpdateFeatures atomically replaces the features computed by the Store.
+// Stored events are kept, so new and changed features are computed over
+// the existing history right away. The new set is validated like in New,
+// together with the configured derived features and entity types; on
+// error the current set stays in use.
+//
+// Features are matched by entity type and name. A feature counts as
+// changed when its aggregator or window differs. Aggregators and windows are compared by value, so features with
+// a Filter, or whose aggregator or window holds a function, are always
+// reported as changed.
+func (s *Store) UpdateFeatures(ctx context.Context, features []Feature) (FeatureDiff, error) {
+	next, err := s.newFeatureSet(features)
+	if err != nil {
+		return FeatureDiff{}, err
+	}
+
+	// Writers hold writeMu while seeding and updating buckets, so the new
+	// buckets are seeded either before or after any in-flight Push
+	s.writeMu.Lock()
+	prev := s.features.Swap(next)
+	s.writeMu.Unlock()
+
+	return diffFeatures(prev.features, next.features), nil
+}
+
+type featureKey struct {
+	entity, name string
+}
+
+func (k featureKey) String() string {
+	if k.entity == "" {
+		return k.name
+	}
+	return k.entity + "/" + k.name
+}
+
+func diffFeatures(prev, next []Feature) FeatureDiff {
+	old := make(map[featureKey]Feature, len(prev))
+	for _, f := range prev {
+		old[featureKey{f.Entity, f.Name}] = f
+	}
+
+	var d FeatureDiff
+	for _, f := range next {
+		key := featureKey{f.Entity, f.Name}
+		o, ok := old[key]
+		delete(old, key)
+		switch {
+		case !ok:
+			d.Added = append(d.Added, key.String())
+		case !sameFeature(o, f):
+			d.Changed = append(d.Changed, key.String())
+		}
+	}
+	for key := range old {
+		d.Removed = append(d.Removed, key.String())
+	}
+
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Changed)
+	return d
+}
+
+func sameFeature(a, b Feature) bool {
+	if a.Filter != nil || b.Filter != nil {
+		return false
+	}
+	return reflect.DeepEqual(a.Aggregate(), b.Aggregate()) && sameWindow(a.Window, b.Window)
+}
+
+func sameWindow(a, b Window) bool {
+	ca, okA := a.(*calendarWindow)
+	cb, okB := b.(*calendarWindow)
+	if okA && okB {
+		// Skip the location cache and compare zones by name
+		return ca.unit == cb.unit && ca.loc.String() == cb.loc.String() &&
+			ca.weekStart == cb.weekStart && ca.locationField == cb.locationField
+	}
+	return reflect.DeepEqual(a, b)
+}
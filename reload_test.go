@@ -0,0 +1,191 @@
+package gofeat_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+func TestStore_UpdateFeatures(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	for _, bucket := range []time.Duration{0, time.Minute} {
+		store, err := gofeat.New(gofeat.Config{
+			BucketSize: bucket,
+			Features: []gofeat.Feature{
+				{Name: "count_1h", Aggregate: gofeat.Count, Window: gofeat.Sliding(time.Hour)},
+				{Name: "sum", Aggregate: gofeat.Sum("amount")},
+				{Name: "today", Aggregate: gofeat.Count, Window: gofeat.CalendarDay(berlin)},
+				{Name: "old", Aggregate: gofeat.Count},
+			},
+		})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		for i := range 6 {
+			store.Push(ctx, "user1", gofeat.Event{
+				Timestamp: now.Add(-time.Duration(i) * 30 * time.Minute),
+				Data:      map[string]any{"amount": float64(i)},
+			})
+		}
+		store.Get(ctx, "user1") // fill caches of the current set
+
+		berlinAgain, _ := time.LoadLocation("Europe/Berlin")
+		diff, err := store.UpdateFeatures(ctx, []gofeat.Feature{
+			{Name: "count_1h", Aggregate: gofeat.Count, Window: gofeat.Sliding(3 * time.Hour)},
+			{Name: "sum", Aggregate: gofeat.Sum("amount"), Window: gofeat.Lifetime()},
+			{Name: "today", Aggregate: gofeat.Count, Window: gofeat.CalendarDay(berlinAgain)},
+			{Name: "max", Aggregate: gofeat.Max("amount")},
+		})
+		if err != nil {
+			t.Fatalf("UpdateFeatures failed: %v", err)
+		}
+		want := gofeat.FeatureDiff{Added: []string{"max"}, Removed: []string{"old"}, Changed: []string{"count_1h"}}
+		if !reflect.DeepEqual(diff, want) {
+			t.Errorf("bucket %v: diff got %+v, want %+v", bucket, diff, want)
+		}
+
+		// Changed features are computed over the stored history
+		store.Push(ctx, "user1", gofeat.Event{Timestamp: now, Data: map[string]any{"amount": 10.0}})
+		result, _ := store.GetAt(ctx, "user1", now)
+		if got := result.IntOr("count_1h", -1); got != 7 {
+			t.Errorf("bucket %v: count_1h got %d, want 7", bucket, got)
+		}
+		if got := result.FloatOr("max", -1); got != 10 {
+			t.Errorf("bucket %v: max got %v, want 10", bucket, got)
+		}
+		if _, err := result.Int("old"); err == nil {
+			t.Errorf("bucket %v: removed feature still computed", bucket)
+		}
+	}
+}
+
+func TestStore_UpdateFeatures_Invalid(t *testing.T) {
+	ctx := context.Background()
+	store, _ := gofeat.New(gofeat.Config{
+		Features: []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count}},
+		Derived:  []gofeat.DerivedFeature{{Name: "double", Expr: "count * 2"}},
+	})
+
+	tests := []struct {
+		name     string
+		features []gofeat.Feature
+		wantErr  string
+	}{
+		{name: "empty", features: nil, wantErr: "at least one feature"},
+		{name: "missing aggregate", features: []gofeat.Feature{{Name: "count"}}, wantErr: "aggregate required"},
+		{name: "unknown entity", features: []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count, Entity: "card"}}, wantErr: "unknown entity type"},
+		{name: "breaks derived", features: []gofeat.Feature{{Name: "total", Aggregate: gofeat.Count}}, wantErr: `unknown feature "count"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := store.UpdateFeatures(ctx, tt.features)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	store.Push(ctx, "user1", gofeat.Event{Timestamp: time.Now().UTC()})
+	result, _ := store.Get(ctx, "user1")
+	if got := result.FloatOr("double", -1); got != 2 {
+		t.Errorf("previous features must stay in use: double got %v, want 2", got)
+	}
+}
+
+func TestStore_UpdateFeatures_FilterAlwaysChanged(t *testing.T) {
+	declined := gofeat.FieldEquals("status", "declined")
+	features := []gofeat.Feature{{Name: "declined", Aggregate: gofeat.Count, Filter: declined}}
+	store, _ := gofeat.New(gofeat.Config{Features: features})
+
+	diff, err := store.UpdateFeatures(context.Background(), features)
+	if err != nil {
+		t.Fatalf("UpdateFeatures failed: %v", err)
+	}
+	if !reflect.DeepEqual(diff.Changed, []string{"declined"}) {
+		t.Errorf("changed got %v, want [declined]", diff.Changed)
+	}
+
+	// Without functions identical features are unchanged
+	store.UpdateFeatures(context.Background(), []gofeat.Feature{{Name: "declined", Aggregate: gofeat.Count}})
+	diff, _ = store.UpdateFeatures(context.Background(), []gofeat.Feature{{Name: "declined", Aggregate: gofeat.Count}})
+	if !diff.Empty() {
+		t.Errorf("expected empty diff, got %+v", diff)
+	}
+}
+
+func TestStore_UpdateFeatures_SharedNameAcrossEntities(t *testing.T) {
+	ctx := context.Background()
+	features := []gofeat.Feature{
+		{Name: "count", Aggregate: gofeat.Count},
+		{Name: "count", Aggregate: gofeat.Count, Entity: "user"},
+		{Name: "count", Aggregate: gofeat.Count, Entity: "card"},
+	}
+	store, err := gofeat.New(gofeat.Config{
+		Entities: []gofeat.EntityType{{Name: "user", Field: "user"}, {Name: "card", Field: "card"}},
+		Features: features,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	diff, err := store.UpdateFeatures(ctx, features)
+	if err != nil {
+		t.Fatalf("UpdateFeatures failed: %v", err)
+	}
+	if !diff.Empty() {
+		t.Errorf("unchanged features: expected empty diff, got %+v", diff)
+	}
+
+	diff, err = store.UpdateFeatures(ctx, []gofeat.Feature{
+		{Name: "count", Aggregate: gofeat.Count},
+		{Name: "count", Aggregate: gofeat.Count, Window: gofeat.Sliding(time.Hour), Entity: "card"},
+		{Name: "sum", Aggregate: gofeat.Sum("amount"), Entity: "user"},
+	})
+	if err != nil {
+		t.Fatalf("UpdateFeatures failed: %v", err)
+	}
+	want := gofeat.FeatureDiff{Added: []string{"user/sum"}, Removed: []string{"user/count"}, Changed: []string{"card/count"}}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("diff got %+v, want %+v", diff, want)
+	}
+}
+
+func TestStore_UpdateFeatures_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	store, _ := gofeat.New(gofeat.Config{
+		BucketSize: time.Second,
+		Features:   []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count, Window: gofeat.Sliding(time.Hour)}},
+	})
+
+	const pushes = 200
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range pushes {
+			store.Push(ctx, "user1", gofeat.Event{Timestamp: time.Now().UTC()})
+			store.Get(ctx, "user1")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := range 50 {
+			window := gofeat.Sliding(time.Hour + time.Duration(i)*time.Second)
+			store.UpdateFeatures(ctx, []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count, Window: window}})
+		}
+	}()
+	wg.Wait()
+
+	result, _ := store.Get(ctx, "user1")
+	if got := result.IntOr("count", -1); got != pushes {
+		t.Errorf("count got %d, want %d", got, pushes)
+	}
+}
@@ -1,6 +1,9 @@
 package gofeat
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type Result struct {
 	values map[string]any
@@ -70,6 +73,86 @@ func (r Result) StringOr(name, defaultValue string) string {
 	return v
 }
 
+func (r Result) Duration(name string) (time.Duration, error) {
+	v, ok := r.values[name]
+	if !ok {
+		return 0, fmt.Errorf("feature %q not found", name)
+	}
+	d, ok := v.(time.Duration)
+	if !ok {
+		return 0, fmt.Errorf("feature %q: expected time.Duration, got %T", name, v)
+	}
+	return d, nil
+}
+
+func (r Result) DurationOr(name string, defaultValue time.Duration) time.Duration {
+	v, err := r.Duration(name)
+	if err != nil {
+		return defaultValue
+	}
+	return v
+}
+
+func (r Result) Floats(name string) ([]float64, error) {
+	v, ok := r.values[name]
+	if !ok {
+		return nil, fmt.Errorf("feature %q not found", name)
+	}
+	f, ok := v.([]float64)
+	if !ok {
+		return nil, fmt.Errorf("feature %q: expected []float64, got %T", name, v)
+	}
+	return f, nil
+}
+
+func (r Result) FloatsOr(name string, defaultValue []float64) []float64 {
+	v, err := r.Floats(name)
+	if err != nil {
+		return defaultValue
+	}
+	return v
+}
+
+func (r Result) TopK(name string) ([]ValueCount, error) {
+	v, ok := r.values[name]
+	if !ok {
+		return nil, fmt.Errorf("feature %q not found", name)
+	}
+	top, ok := v.([]ValueCount)
+	if !ok {
+		return nil, fmt.Errorf("feature %q: expected []ValueCount, got %T", name, v)
+	}
+	return top, nil
+}
+
+func (r Result) TopKOr(name string, defaultValue []ValueCount) []ValueCount {
+	v, err := r.TopK(name)
+	if err != nil {
+		return defaultValue
+	}
+	return v
+}
+
+func (r Result) Histogram(name string) ([]int, error) {
+	v, ok := r.values[name]
+	if !ok {
+		return nil, fmt.Errorf("feature %q not found", name)
+	}
+	counts, ok := v.([]int)
+	if !ok {
+		return nil, fmt.Errorf("feature %q: expected []int, got %T", name, v)
+	}
+	return counts, nil
+}
+
+func (r Result) HistogramOr(name string, defaultValue []int) []int {
+	v, err := r.Histogram(name)
+	if err != nil {
+		return defaultValue
+	}
+	return v
+}
+
 func (r Result) Any(name string) (any, bool) {
 	v, ok := r.values[name]
 	return v, ok
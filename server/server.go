@@ -0,0 +1,351 @@
+// Package server exposes a gofeat.Store over HTTP with JSON bodies.
+//
+// Routes:
+//
+//	POST /entities/{id}/events        push events for an entity
+//	GET  /entities/{id}/features      compute features, optionally ?at=<RFC 3339>&type=<entity type>
+//	POST /events                      ingest events fanned out to entity types (Store.Ingest)
+//	POST /features:batchGet           compute features for several entities
+//	GET  /stats                       storage statistics
+//	POST /evict                       evict expired events
+//
+// Events are encoded as {"timestamp": "2024-01-01T12:00:00Z", "data": {...}};
+// a missing timestamp means the time the request is handled. Request bodies
+// of the event routes hold a single event or an array of events.
+//
+// Feature values are encoded as JSON numbers, strings or booleans, or
+// arrays of them for multi-valued features. time.Duration values are
+// encoded as seconds and NaN or infinite floats as null, also inside
+// arrays. Errors are returned as {"error": {"code": "...", "message": "..."}}.
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+// DefaultMaxBodyBytes is the request body limit used when
+// Options.MaxBodyBytes is not set.
+const DefaultMaxBodyBytes = 1 << 20
+
+// Error codes used in error bodies.
+const (
+	CodeInvalidArgument  = "invalid_argument"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeInternal         = "internal"
+)
+
+// Options configure a Server.
+type Options struct {
+	MaxBodyBytes int64 // request body limit, DefaultMaxBodyBytes if zero
+}
+
+// Server serves the features of a Store. It implements http.Handler.
+type Server struct {
+	store *gofeat.Store
+	opts  Options
+	mux   *http.ServeMux
+}
+
+// New returns a Server for store.
+func New(store *gofeat.Store, opts Options) *Server {
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	s := &Server{store: store, opts: opts, mux: http.NewServeMux()}
+
+	// Methods are checked by the handlers so that 405 responses use the
+	// same error body as everything else
+	s.mux.HandleFunc("/entities/{id}/events", s.method(http.MethodPost, s.pushEvents))
+	s.mux.HandleFunc("/entities/{id}/features", s.method(http.MethodGet, s.getFeatures))
+	s.mux.HandleFunc("/events", s.method(http.MethodPost, s.ingestEvents))
+	s.mux.HandleFunc("/features:batchGet", s.method(http.MethodPost, s.batchGet))
+	s.mux.HandleFunc("/stats", s.method(http.MethodGet, s.stats))
+	s.mux.HandleFunc("/evict", s.method(http.MethodPost, s.evict))
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, CodeNotFound, fmt.Sprintf("no route for %s", r.URL.Path))
+	})
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) method(method string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+			return
+		}
+		h(w, r)
+	}
+}
+
+type eventJSON struct {
+	Timestamp *time.Time     `json:"timestamp"`
+	Data      map[string]any `json:"data"`
+}
+
+type acceptedResponse struct {
+	Accepted int `json:"accepted"`
+}
+
+type featuresResponse struct {
+	EntityID string         `json:"entity_id"`
+	At       time.Time      `json:"at"`
+	Features map[string]any `json:"features"`
+}
+
+type batchGetRequest struct {
+	EntityIDs  []string   `json:"entity_ids"`
+	EntityType string     `json:"entity_type"`
+	At         *time.Time `json:"at"`
+}
+
+type batchGetResponse struct {
+	At      time.Time                 `json:"at"`
+	Results map[string]map[string]any `json:"results"`
+}
+
+type statsResponse struct {
+	Entities    int   `json:"entities"`
+	TotalEvents int64 `json:"total_events"`
+}
+
+func (s *Server) pushEvents(w http.ResponseWriter, r *http.Request) {
+	events, ok := s.readEvents(w, r)
+	if !ok {
+		return
+	}
+	if err := s.store.Push(r.Context(), r.PathValue("id"), events...); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, acceptedResponse{Accepted: len(events)})
+}
+
+func (s *Server) ingestEvents(w http.ResponseWriter, r *http.Request) {
+	events, ok := s.readEvents(w, r)
+	if !ok {
+		return
+	}
+	if err := s.store.Ingest(r.Context(), events...); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, acceptedResponse{Accepted: len(events)})
+}
+
+func (s *Server) getFeatures(w http.ResponseWriter, r *http.Request) {
+	at := time.Now().UTC()
+	if v := r.URL.Query().Get("at"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, CodeInvalidArgument, fmt.Sprintf("invalid at: %v", err))
+			return
+		}
+		at = t.UTC()
+	}
+
+	id := r.PathValue("id")
+	result, err := s.compute(r, r.URL.Query().Get("type"), id, at)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, featuresResponse{EntityID: id, At: at, Features: encodeResult(result)})
+}
+
+func (s *Server) batchGet(w http.ResponseWriter, r *http.Request) {
+	var req batchGetRequest
+	if !s.readJSON(w, r, &req) {
+		return
+	}
+	at := time.Now().UTC()
+	if req.At != nil {
+		at = req.At.UTC()
+	}
+
+	resp := batchGetResponse{At: at, Results: make(map[string]map[string]any, len(req.EntityIDs))}
+	for _, id := range req.EntityIDs {
+		result, err := s.compute(r, req.EntityType, id, at)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		resp.Results[id] = encodeResult(result)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) compute(r *http.Request, entityType, id string, at time.Time) (gofeat.Result, error) {
+	if entityType == "" {
+		return s.store.GetAt(r.Context(), id, at)
+	}
+	return s.store.GetEntityAt(r.Context(), entityType, id, at)
+}
+
+func (s *Server) stats(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.store.Stats(r.Context())
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, statsResponse{Entities: stats.Entities, TotalEvents: stats.TotalEvents})
+}
+
+func (s *Server) evict(w http.ResponseWriter, r *http.Request) {
+	if err := s.store.Evict(r.Context()); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readEvents decodes a single event or an array of events.
+func (s *Server) readEvents(w http.ResponseWriter, r *http.Request) ([]gofeat.Event, bool) {
+	var raw json.RawMessage
+	if !s.readJSON(w, r, &raw) {
+		return nil, false
+	}
+
+	var list []eventJSON
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(raw, &list); err != nil {
+			writeError(w, http.StatusBadRequest, CodeInvalidArgument, fmt.Sprintf("invalid events: %v", err))
+			return nil, false
+		}
+	} else {
+		var e eventJSON
+		if err := json.Unmarshal(raw, &e); err != nil {
+			writeError(w, http.StatusBadRequest, CodeInvalidArgument, fmt.Sprintf("invalid event: %v", err))
+			return nil, false
+		}
+		list = []eventJSON{e}
+	}
+
+	now := time.Now().UTC()
+	events := make([]gofeat.Event, 0, len(list))
+	for _, e := range list {
+		ts := now
+		if e.Timestamp != nil {
+			ts = e.Timestamp.UTC()
+		}
+		events = append(events, gofeat.Event{Timestamp: ts, Data: e.Data})
+	}
+	return events, true
+}
+
+func (s *Server) readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.opts.MaxBodyBytes))
+	if err := dec.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, CodeInvalidArgument,
+				fmt.Sprintf("request body larger than %d bytes", tooLarge.Limit))
+			return false
+		}
+		writeError(w, http.StatusBadRequest, CodeInvalidArgument, fmt.Sprintf("invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+// encodeResult converts feature values to JSON-friendly values.
+func encodeResult(r gofeat.Result) map[string]any {
+	all := r.All()
+	values := make(map[string]any, len(all))
+	for name, v := range all {
+		values[name] = encodeValue(v)
+	}
+	return values
+}
+
+func encodeValue(v any) any {
+	switch n := v.(type) {
+	case time.Duration:
+		return n.Seconds()
+	case float64:
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil
+		}
+	case float32:
+		if math.IsNaN(float64(n)) || math.IsInf(float64(n), 0) {
+			return nil
+		}
+	case []float64:
+		values := make([]any, len(n))
+		for i, f := range n {
+			values[i] = encodeValue(f)
+		}
+		return values
+	case []any:
+		values := make([]any, len(n))
+		for i, e := range n {
+			values[i] = encodeValue(e)
+		}
+		return values
+	case map[string]any:
+		values := make(map[string]any, len(n))
+		for k, e := range n {
+			values[k] = encodeValue(e)
+		}
+		return values
+	case []gofeat.ValueCount:
+		values := make([]gofeat.ValueCount, len(n))
+		for i, vc := range n {
+			values[i] = gofeat.ValueCount{Value: encodeValue(vc.Value), Count: vc.Count}
+		}
+		return values
+	}
+	return v
+}
+
+type errorBody struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gofeat.ErrUnknownEntityType):
+		writeError(w, http.StatusNotFound, CodeNotFound, err.Error())
+	case errors.Is(err, gofeat.ErrInvalidEvent):
+		writeError(w, http.StatusBadRequest, CodeInvalidArgument, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, errorBody{Error: errorDetail{Code: code, Message: message}})
+}
+
+// writeJSON encodes v before writing the header, so that values that
+// cannot be encoded result in a 500 rather than a truncated body.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		status = http.StatusInternalServerError
+		buf.Reset()
+		body := errorBody{Error: errorDetail{Code: CodeInternal, Message: fmt.Sprintf("encode response: %v", err)}}
+		_ = json.NewEncoder(&buf).Encode(body) // error bodies only hold strings
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
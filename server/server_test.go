@@ -0,0 +1,289 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+	"github.com/w0rng/gofeat/server"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	store, err := gofeat.New(gofeat.Config{
+		Entities: []gofeat.EntityType{{Name: "card", Field: "card"}},
+		Features: []gofeat.Feature{
+			{Name: "count", Aggregate: gofeat.Count},
+			{Name: "sum", Aggregate: gofeat.Sum("amount")},
+			{Name: "age", Aggregate: gofeat.TimeSinceFirst()},
+			{Name: "last_country", Aggregate: gofeat.Last("country")},
+			{Name: "card_count", Aggregate: gofeat.Count, Entity: "card"},
+		},
+		Derived: []gofeat.DerivedFeature{{Name: "ratio", Expr: "sum / count"}},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ts := httptest.NewServer(server.New(store, server.Options{MaxBodyBytes: 1024}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func do(t *testing.T, method, url, body string) (int, map[string]any) {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(context.Background(), method, url, strings.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	var out map[string]any
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &out); err != nil {
+			t.Fatalf("%s %s: invalid JSON %q: %v", method, url, data, err)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s %s: content type %q", method, url, ct)
+		}
+	}
+	return resp.StatusCode, out
+}
+
+func TestServer_PushAndGet(t *testing.T) {
+	ts := newTestServer(t)
+
+	status, body := do(t, http.MethodPost, ts.URL+"/entities/user1/events", `[
+		{"timestamp": "2024-01-01T12:00:00Z", "data": {"amount": 10, "country": "US"}},
+		{"timestamp": "2024-01-01T14:00:00+02:00", "data": {"amount": 20, "country": "DE"}}
+	]`)
+	if status != http.StatusOK || body["accepted"] != 2.0 {
+		t.Fatalf("push: status %d, body %v", status, body)
+	}
+	status, _ = do(t, http.MethodPost, ts.URL+"/entities/user1/events",
+		`{"timestamp": "2024-01-01T12:30:00Z", "data": {"amount": 30}}`)
+	if status != http.StatusOK {
+		t.Fatalf("push single: status %d", status)
+	}
+
+	status, body = do(t, http.MethodGet, ts.URL+"/entities/user1/features?at=2024-01-01T13:00:00Z", "")
+	if status != http.StatusOK {
+		t.Fatalf("get: status %d, body %v", status, body)
+	}
+	if body["entity_id"] != "user1" || body["at"] != "2024-01-01T13:00:00Z" {
+		t.Errorf("unexpected envelope: %v", body)
+	}
+	features, _ := body["features"].(map[string]any)
+	want := map[string]any{
+		"count":        3.0,
+		"sum":          60.0,
+		"age":          1800.0, // Duration as seconds
+		"last_country": "DE",
+		"ratio":        20.0,
+	}
+	for name, v := range want {
+		if features[name] != v {
+			t.Errorf("%s: got %#v, want %#v", name, features[name], v)
+		}
+	}
+}
+
+func TestServer_NonFiniteValues(t *testing.T) {
+	ts := newTestServer(t)
+
+	// ratio is 0/0 without events
+	status, body := do(t, http.MethodGet, ts.URL+"/entities/nobody/features", "")
+	if status != http.StatusOK {
+		t.Fatalf("status %d, body %v", status, body)
+	}
+	features, _ := body["features"].(map[string]any)
+	if v, ok := features["ratio"]; !ok || v != nil {
+		t.Errorf("ratio: got %#v, want null", v)
+	}
+}
+
+// constAgg always returns the same value.
+type constAgg struct{ v any }
+
+func (a constAgg) Add(gofeat.Event) {}
+func (a constAgg) Result() any      { return a.v }
+
+func TestServer_EncodeValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  any
+		status int
+		want   any
+	}{
+		{name: "nested non-finite", value: []float64{math.NaN(), 1, math.Inf(1)}, status: http.StatusOK, want: []any{nil, 1.0, nil}},
+		{
+			name:   "top-k with non-finite value",
+			value:  []gofeat.ValueCount{{Value: math.NaN(), Count: 2}},
+			status: http.StatusOK,
+			want:   []any{map[string]any{"value": nil, "count": 2.0}},
+		},
+		{name: "not encodable", value: make(chan int), status: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := gofeat.New(gofeat.Config{Features: []gofeat.Feature{
+				{Name: "value", Aggregate: func() gofeat.Aggregator { return constAgg{tt.value} }},
+			}})
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			ts := httptest.NewServer(server.New(store, server.Options{}))
+			defer ts.Close()
+
+			status, body := do(t, http.MethodGet, ts.URL+"/entities/u1/features", "")
+			if status != tt.status {
+				t.Fatalf("status got %d, want %d, body %v", status, tt.status, body)
+			}
+			if status != http.StatusOK {
+				if detail, _ := body["error"].(map[string]any); detail["code"] != server.CodeInternal {
+					t.Errorf("error body got %v", body)
+				}
+				return
+			}
+			features, _ := body["features"].(map[string]any)
+			if !reflect.DeepEqual(features["value"], tt.want) {
+				t.Errorf("got %#v, want %#v", features["value"], tt.want)
+			}
+		})
+	}
+}
+
+// rejectingStorage rejects every event as invalid.
+type rejectingStorage struct{ gofeat.Storage }
+
+func (s rejectingStorage) Push(context.Context, string, ...gofeat.Event) error {
+	return fmt.Errorf("%w: rejected by storage", gofeat.ErrInvalidEvent)
+}
+
+func TestServer_InvalidEvent(t *testing.T) {
+	store, err := gofeat.New(gofeat.Config{
+		Storage:  rejectingStorage{gofeat.NewMemoryStorage(0)},
+		Features: []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count}},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ts := httptest.NewServer(server.New(store, server.Options{}))
+	defer ts.Close()
+
+	status, body := do(t, http.MethodPost, ts.URL+"/entities/u1/events", `{"data": {}}`)
+	if detail, _ := body["error"].(map[string]any); status != http.StatusBadRequest || detail["code"] != server.CodeInvalidArgument {
+		t.Errorf("got status %d, body %v, want 400 %s", status, body, server.CodeInvalidArgument)
+	}
+}
+
+func TestServer_IngestAndBatchGet(t *testing.T) {
+	ts := newTestServer(t)
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	status, _ := do(t, http.MethodPost, ts.URL+"/events", `[
+		{"timestamp": "`+now+`", "data": {"card": "4111"}},
+		{"data": {"card": "4111"}},
+		{"data": {"card": "5500"}}
+	]`)
+	if status != http.StatusOK {
+		t.Fatalf("ingest: status %d", status)
+	}
+
+	status, body := do(t, http.MethodPost, ts.URL+"/features:batchGet",
+		`{"entity_type": "card", "entity_ids": ["4111", "5500", "0000"]}`)
+	if status != http.StatusOK {
+		t.Fatalf("batchGet: status %d, body %v", status, body)
+	}
+	results, _ := body["results"].(map[string]any)
+	for id, want := range map[string]float64{"4111": 2, "5500": 1, "0000": 0} {
+		features, _ := results[id].(map[string]any)
+		if features["card_count"] != want {
+			t.Errorf("%s: card_count got %v, want %v", id, features["card_count"], want)
+		}
+	}
+
+	status, body = do(t, http.MethodGet, ts.URL+"/entities/4111/features?type=card", "")
+	if status != http.StatusOK {
+		t.Fatalf("get typed: status %d, body %v", status, body)
+	}
+
+	status, body = do(t, http.MethodGet, ts.URL+"/stats", "")
+	if status != http.StatusOK || body["entities"] != 2.0 || body["total_events"] != 3.0 {
+		t.Errorf("stats: status %d, body %v", status, body)
+	}
+
+	status, _ = do(t, http.MethodPost, ts.URL+"/evict", "")
+	if status != http.StatusNoContent {
+		t.Errorf("evict: status %d", status)
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	ts := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "unknown route", method: http.MethodGet, path: "/nope", status: http.StatusNotFound, code: server.CodeNotFound},
+		{
+			name: "wrong method", method: http.MethodGet, path: "/entities/u1/events",
+			status: http.StatusMethodNotAllowed, code: server.CodeMethodNotAllowed,
+		},
+		{
+			name: "invalid json", method: http.MethodPost, path: "/entities/u1/events", body: `{"data":`,
+			status: http.StatusBadRequest, code: server.CodeInvalidArgument,
+		},
+		{
+			name: "invalid timestamp", method: http.MethodPost, path: "/entities/u1/events", body: `{"timestamp": "yesterday"}`,
+			status: http.StatusBadRequest, code: server.CodeInvalidArgument,
+		},
+		{
+			name: "body too large", method: http.MethodPost, path: "/entities/u1/events",
+			body:   `{"data": {"x": "` + strings.Repeat("a", 2048) + `"}}`,
+			status: http.StatusRequestEntityTooLarge, code: server.CodeInvalidArgument,
+		},
+		{
+			name: "invalid at", method: http.MethodGet, path: "/entities/u1/features?at=now",
+			status: http.StatusBadRequest, code: server.CodeInvalidArgument,
+		},
+		{
+			name: "unknown entity type", method: http.MethodGet, path: "/entities/u1/features?type=device",
+			status: http.StatusNotFound, code: server.CodeNotFound,
+		},
+		{
+			name: "batch unknown entity type", method: http.MethodPost, path: "/features:batchGet",
+			body:   `{"entity_type": "device", "entity_ids": ["d1"]}`,
+			status: http.StatusNotFound, code: server.CodeNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := do(t, tt.method, ts.URL+tt.path, tt.body)
+			if status != tt.status {
+				t.Errorf("status got %d, want %d", status, tt.status)
+			}
+			detail, _ := body["error"].(map[string]any)
+			if detail["code"] != tt.code || detail["message"] == "" {
+				t.Errorf("error body got %v, want code %q", body, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package gofeat
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// SlogObserverOptions configure NewSlogObserver.
+type SlogObserverOptions struct {
+	// SlowThreshold logs operations, storage calls and feature computations
+	// taking at least this long at slog.LevelWarn. Zero disables it.
+	SlowThreshold time.Duration
+
+	// Features logs every feature computation; otherwise only slow ones
+	// are logged.
+	Features bool
+}
+
+// NewSlogObserver returns an Observer logging to logger. Completed
+// operations and storage calls are logged at slog.LevelDebug, slow ones at
+// slog.LevelWarn, failed ones at slog.LevelError and evictions at
+// slog.LevelInfo.
+func NewSlogObserver(logger *slog.Logger, opts SlogObserverOptions) Observer {
+	return &slogObserver{logger: logger, opts: opts}
+}
+
+type slogObserver struct {
+	NopObserver
+	logger *slog.Logger
+	opts   SlogObserverOptions
+}
+
+func (o *slogObserver) level(d time.Duration, err error) slog.Level {
+	switch {
+	case err != nil:
+		return slog.LevelError
+	case o.opts.SlowThreshold > 0 && d >= o.opts.SlowThreshold:
+		return slog.LevelWarn
+	default:
+		return slog.LevelDebug
+	}
+}
+
+func (o *slogObserver) PushEnd(ctx context.Context, op PushOp, res OpResult) {
+	level := o.level(res.Duration, res.Err)
+	if !o.logger.Enabled(ctx, level) {
+		return
+	}
+	msg := "gofeat push"
+	attrs := []slog.Attr{slog.Int("events", op.Events), slog.Duration("duration", res.Duration)}
+	if op.Ingest {
+		msg = "gofeat ingest"
+	} else {
+		attrs = append(attrs, slog.String("entity_id", op.EntityID))
+	}
+	o.log(ctx, level, msg, res.Err, attrs...)
+}
+
+func (o *slogObserver) GetEnd(ctx context.Context, op GetOp, res OpResult) {
+	level := o.level(res.Duration, res.Err)
+	if !o.logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("entity_id", op.EntityID),
+		slog.Time("at", op.At),
+		slog.Duration("duration", res.Duration),
+	}
+	if op.EntityType != "" {
+		attrs = append(attrs, slog.String("entity_type", op.EntityType))
+	}
+	o.log(ctx, level, "gofeat get", res.Err, attrs...)
+}
+
+func (o *slogObserver) StorageCall(ctx context.Context, call StorageCall) {
+	level := o.level(call.Duration, call.Err)
+	if !o.logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{slog.String("op", call.Op), slog.Duration("duration", call.Duration)}
+	if call.Key != "" {
+		attrs = append(attrs, slog.String("key", call.Key))
+	}
+	if call.Op == StorageOpPush || call.Op == StorageOpGet {
+		attrs = append(attrs, slog.Int("events", call.Events))
+	}
+	o.log(ctx, level, "gofeat storage call", call.Err, attrs...)
+}
+
+func (o *slogObserver) FeatureComputed(ctx context.Context, f FeatureComputation) {
+	level := o.level(f.Duration, nil)
+	if (level == slog.LevelDebug && !o.opts.Features) || !o.logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("feature", f.Feature),
+		slog.String("entity_id", f.EntityID),
+		slog.Duration("duration", f.Duration),
+	}
+	if f.EntityType != "" {
+		attrs = append(attrs, slog.String("entity_type", f.EntityType))
+	}
+	o.log(ctx, level, "gofeat feature computed", nil, attrs...)
+}
+
+func (o *slogObserver) Evicted(ctx context.Context, ev Eviction) {
+	level := slog.LevelInfo
+	if ev.Err != nil {
+		level = slog.LevelError
+	}
+	if !o.logger.Enabled(ctx, level) {
+		return
+	}
+	o.log(ctx, level, "gofeat evict", ev.Err, slog.Int64("events", ev.Events), slog.Duration("duration", ev.Duration))
+}
+
+// log logs msg; callers check that level is enabled before building attrs.
+func (o *slogObserver) log(ctx context.Context, level slog.Level, msg string, err error, attrs ...slog.Attr) {
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	o.logger.LogAttrs(ctx, level, msg, attrs...)
+}
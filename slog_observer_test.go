@@ -0,0 +1,154 @@
+package gofeat_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+func TestSlogObserver(t *testing.T) {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+
+	tests := []struct {
+		name    string
+		level   slog.Level
+		opts    gofeat.SlogObserverOptions
+		call    func(o gofeat.Observer)
+		want    []string
+		notWant []string
+	}{
+		{
+			name:  "push debug",
+			level: slog.LevelDebug,
+			call: func(o gofeat.Observer) {
+				o.PushEnd(ctx, gofeat.PushOp{EntityID: "u1", Events: 2}, gofeat.OpResult{Duration: time.Millisecond})
+			},
+			want: []string{"level=DEBUG", `msg="gofeat push"`, "events=2", "entity_id=u1", "duration=1ms"},
+		},
+		{
+			name:  "ingest error",
+			level: slog.LevelInfo,
+			call: func(o gofeat.Observer) {
+				o.PushEnd(ctx, gofeat.PushOp{Ingest: true, Events: 1}, gofeat.OpResult{Err: errBoom})
+			},
+			want:    []string{"level=ERROR", `msg="gofeat ingest"`, "error=boom"},
+			notWant: []string{"entity_id"},
+		},
+		{
+			name:  "debug disabled",
+			level: slog.LevelInfo,
+			call: func(o gofeat.Observer) {
+				o.GetEnd(ctx, gofeat.GetOp{EntityID: "u1"}, gofeat.OpResult{Duration: time.Millisecond})
+			},
+			notWant: []string{"gofeat get"},
+		},
+		{
+			name:  "slow get",
+			level: slog.LevelInfo,
+			opts:  gofeat.SlogObserverOptions{SlowThreshold: time.Millisecond},
+			call: func(o gofeat.Observer) {
+				o.GetEnd(ctx, gofeat.GetOp{EntityType: "card", EntityID: "c1"}, gofeat.OpResult{Duration: 2 * time.Millisecond})
+			},
+			want: []string{"level=WARN", `msg="gofeat get"`, "entity_id=c1", "entity_type=card"},
+		},
+		{
+			name:  "storage call",
+			level: slog.LevelDebug,
+			call: func(o gofeat.Observer) {
+				o.StorageCall(ctx, gofeat.StorageCall{Op: gofeat.StorageOpGet, Key: "card/c1", Events: 3})
+			},
+			want: []string{`msg="gofeat storage call"`, "op=get", "key=card/c1", "events=3"},
+		},
+		{
+			name:  "feature not logged by default",
+			level: slog.LevelDebug,
+			call: func(o gofeat.Observer) {
+				o.FeatureComputed(ctx, gofeat.FeatureComputation{Feature: "count", EntityID: "u1"})
+			},
+			notWant: []string{"gofeat feature computed"},
+		},
+		{
+			name:  "slow feature",
+			level: slog.LevelInfo,
+			opts:  gofeat.SlogObserverOptions{SlowThreshold: time.Millisecond},
+			call: func(o gofeat.Observer) {
+				o.FeatureComputed(ctx, gofeat.FeatureComputation{Feature: "count", EntityID: "u1", Duration: time.Second})
+			},
+			want: []string{"level=WARN", `msg="gofeat feature computed"`, "feature=count"},
+		},
+		{
+			name:  "features",
+			level: slog.LevelDebug,
+			opts:  gofeat.SlogObserverOptions{Features: true},
+			call: func(o gofeat.Observer) {
+				o.FeatureComputed(ctx, gofeat.FeatureComputation{Feature: "count", EntityID: "u1"})
+			},
+			want: []string{"level=DEBUG", `msg="gofeat feature computed"`},
+		},
+		{
+			name:  "evict",
+			level: slog.LevelInfo,
+			call: func(o gofeat.Observer) {
+				o.Evicted(ctx, gofeat.Eviction{Events: 5})
+			},
+			want: []string{"level=INFO", `msg="gofeat evict"`, "events=5"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: tt.level}))
+			tt.call(gofeat.NewSlogObserver(logger, tt.opts))
+
+			out := buf.String()
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("missing %q in %q", want, out)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(out, notWant) {
+					t.Errorf("unexpected %q in %q", notWant, out)
+				}
+			}
+		})
+	}
+}
+
+func TestSlogObserver_Store(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	store, err := gofeat.New(gofeat.Config{
+		Features: []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count}},
+		Observer: gofeat.NewSlogObserver(logger, gofeat.SlogObserverOptions{}),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := store.Push(ctx, "u1", gofeat.Event{Timestamp: time.Now().UTC()}); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if _, err := store.Get(ctx, "u1"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 4:\n%s", len(lines), buf.String())
+	}
+	for i, msg := range []string{"gofeat storage call", "gofeat push", "gofeat storage call", "gofeat get"} {
+		if !strings.Contains(lines[i], `msg="`+msg+`"`) {
+			t.Errorf("line %d: %q, want msg %q", i, lines[i], msg)
+		}
+	}
+}
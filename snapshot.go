@@ -0,0 +1,184 @@
+package gofeat
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Snapshotter is implemented by storages that can checkpoint their contents.
+type Snapshotter interface {
+	// Snapshot writes all stored events to w.
+	Snapshot(ctx context.Context, w io.Writer) error
+
+	// Restore replaces the storage contents with a snapshot read from r.
+	Restore(ctx context.Context, r io.Reader) error
+}
+
+// ErrSnapshotUnsupported is returned by Store.Snapshot and Store.Restore
+// when the configured storage does not implement Snapshotter.
+var ErrSnapshotUnsupported = errors.New("gofeat: storage does not support snapshots")
+
+// Snapshot format:
+//
+//	magic "GOFEATSS" | version byte
+//	repeated: 0x01 | uvarint length | entity block
+//	0x00
+//
+// An entity block is the entity ID, the event count and the events, with
+// every Data value prefixed by its type tag (see codec.go).
+const (
+	snapshotMagic   = "GOFEATSS"
+	snapshotVersion = 1
+	blockEntity     = 1
+	blockEnd        = 0
+
+	maxSnapshotBlock = 1 << 30 // bytes per entity block
+)
+
+func (s *memoryStorage) Snapshot(ctx context.Context, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(snapshotMagic); err != nil {
+		return err
+	}
+	if err := bw.WriteByte(snapshotVersion); err != nil {
+		return err
+	}
+
+	var err error
+	var block []byte
+	s.entities.Load().Range(func(key, value any) bool {
+		if err = ctx.Err(); err != nil {
+			return false
+		}
+		entityID, okK := key.(string)
+		es, okV := value.(*entityStore)
+		if !okK || !okV {
+			return true
+		}
+
+		es.mu.RLock()
+		block = appendString(block[:0], entityID)
+		block = binary.AppendUvarint(block, uint64(len(es.events)))
+		for _, e := range es.events {
+			if block, err = appendEvent(block, e); err != nil {
+				break
+			}
+		}
+		es.mu.RUnlock()
+		if err != nil {
+			err = fmt.Errorf("gofeat: snapshot entity %q: %w", entityID, err)
+			return false
+		}
+
+		if len(block) > maxSnapshotBlock {
+			err = fmt.Errorf("gofeat: snapshot entity %q: block of %d bytes exceeds %d", entityID, len(block), maxSnapshotBlock)
+			return false
+		}
+
+		header := binary.AppendUvarint([]byte{blockEntity}, uint64(len(block)))
+		if _, err = bw.Write(header); err != nil {
+			return false
+		}
+		_, err = bw.Write(block)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := bw.WriteByte(blockEnd); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Restore replaces all entities with the snapshot contents. The current
+// data is left untouched if the snapshot cannot be read completely, and
+// concurrent calls see either the old or the new contents.
+func (s *memoryStorage) Restore(ctx context.Context, r io.Reader) error {
+	loaded, err := readSnapshot(ctx, r)
+	if err != nil {
+		return err
+	}
+	entities := &sync.Map{}
+	for entityID, events := range loaded {
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].Timestamp.Before(events[j].Timestamp)
+		})
+		entities.Store(entityID, &entityStore{events: events})
+	}
+	s.entities.Store(entities)
+	return nil
+}
+
+func readSnapshot(ctx context.Context, r io.Reader) (map[string][]Event, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(snapshotMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("gofeat: read snapshot header: %w", err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, errors.New("gofeat: not a snapshot")
+	}
+	if v := header[len(snapshotMagic)]; v != snapshotVersion {
+		return nil, fmt.Errorf("gofeat: unsupported snapshot version %d", v)
+	}
+
+	loaded := make(map[string][]Event)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		kind, err := br.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("gofeat: read snapshot: %w", err)
+		}
+		switch kind {
+		case blockEnd:
+			return loaded, nil
+		case blockEntity:
+		default:
+			return nil, fmt.Errorf("gofeat: unknown snapshot block %d", kind)
+		}
+
+		size, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("gofeat: read snapshot: %w", err)
+		}
+		if size > maxSnapshotBlock {
+			return nil, fmt.Errorf("gofeat: snapshot entity block of %d bytes exceeds %d", size, maxSnapshotBlock)
+		}
+		// Read through a LimitReader rather than allocating size bytes
+		// upfront, so that a corrupt size fails at the end of the stream
+		block, err := io.ReadAll(io.LimitReader(br, int64(size)))
+		if err != nil {
+			return nil, fmt.Errorf("gofeat: read snapshot: %w", err)
+		}
+		if uint64(len(block)) != size {
+			return nil, fmt.Errorf("gofeat: read snapshot: %w", io.ErrUnexpectedEOF)
+		}
+
+		d := &decoder{b: block}
+		entityID := d.string()
+		count := d.uvarint()
+		if count > uint64(len(d.b)) {
+			return nil, fmt.Errorf("gofeat: snapshot entity %q: %w", entityID, errShortBuffer)
+		}
+		events := make([]Event, 0, count)
+		for range count {
+			events = append(events, d.event())
+		}
+		if d.err != nil {
+			return nil, fmt.Errorf("gofeat: snapshot entity %q: %w", entityID, d.err)
+		}
+		loaded[entityID] = append(loaded[entityID], events...)
+	}
+}
@@ -0,0 +1,259 @@
+package gofeat_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+func newSnapshotStore(t *testing.T) *gofeat.Store {
+	t.Helper()
+	store, err := gofeat.New(gofeat.Config{
+		Features: []gofeat.Feature{
+			{Name: "count", Aggregate: gofeat.Count},
+			{Name: "sum", Aggregate: gofeat.Sum("amount")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return store
+}
+
+func TestStore_SnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	local := time.Date(2024, 1, 1, 9, 0, 0, 0, time.FixedZone("EST", -5*3600))
+
+	src := newSnapshotStore(t)
+	defer src.Close()
+	src.Push(ctx, "user1",
+		gofeat.Event{Timestamp: now, Data: map[string]any{
+			"amount": 100.5,
+			"n":      7,
+			"card":   "1234",
+			"ok":     true,
+			"seen":   local,
+			"none":   nil,
+		}},
+		gofeat.Event{Timestamp: now.Add(-time.Minute), Data: map[string]any{"amount": 50.0}},
+	)
+	src.Push(ctx, "user2", gofeat.Event{Timestamp: now, Data: map[string]any{"amount": 1.0}})
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(ctx, &buf); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	dst := newSnapshotStore(t)
+	defer dst.Close()
+	dst.Push(ctx, "stale", gofeat.Event{Timestamp: now})
+	if err := dst.Restore(ctx, &buf); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	stats, _ := dst.Stats(ctx)
+	if stats.Entities != 2 || stats.TotalEvents != 3 {
+		t.Errorf("stats after restore: got %+v", stats)
+	}
+
+	result, _ := dst.GetAt(ctx, "user1", now)
+	if got := result.IntOr("count", -1); got != 2 {
+		t.Errorf("count: got %d, want 2", got)
+	}
+	if got := result.FloatOr("sum", -1); got != 150.5 {
+		t.Errorf("sum: got %v, want 150.5", got)
+	}
+
+	storage := gofeat.NewMemoryStorage(0)
+	buf.Reset()
+	src.Snapshot(ctx, &buf)
+	storage.(gofeat.Snapshotter).Restore(ctx, &buf)
+	events, _ := storage.Get(ctx, "user1", now)
+	data := events[1].Data
+	if data["amount"] != 100.5 || data["n"] != 7 || data["card"] != "1234" || data["ok"] != true {
+		t.Errorf("scalar values not preserved: %#v", data)
+	}
+	if v, ok := data["none"]; !ok || v != nil {
+		t.Errorf("nil value not preserved: %#v", v)
+	}
+	seen, ok := data["seen"].(time.Time)
+	if !ok || !seen.Equal(local) {
+		t.Fatalf("time value not preserved: %#v", data["seen"])
+	}
+	if _, offset := seen.Zone(); offset != -5*3600 {
+		t.Errorf("time zone offset not preserved: %d", offset)
+	}
+}
+
+func TestStore_Restore_Invalid(t *testing.T) {
+	ctx := context.Background()
+	store := newSnapshotStore(t)
+	defer store.Close()
+
+	now := time.Now().UTC()
+	store.Push(ctx, "user1", gofeat.Event{Timestamp: now})
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "empty", data: ""},
+		{name: "bad magic", data: "NOTASNAP\x01\x00"},
+		{name: "bad version", data: "GOFEATSS\x09\x00"},
+		{name: "truncated", data: "GOFEATSS\x01\x01\x10abc"},
+		{name: "huge block", data: "GOFEATSS\x01\x01" + string(binary.AppendUvarint(nil, 1<<62)) + "abc"},
+		{name: "truncated large block", data: "GOFEATSS\x01\x01" + string(binary.AppendUvarint(nil, 1<<29)) + "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := store.Restore(ctx, strings.NewReader(tt.data)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	stats, _ := store.Stats(ctx)
+	if stats.TotalEvents != 1 {
+		t.Errorf("failed restore must keep existing data, got %d events", stats.TotalEvents)
+	}
+}
+
+func TestStore_Restore_Concurrent(t *testing.T) {
+	// Readers must see either the old or the restored contents, never an
+	// empty or partially restored store
+	ctx := context.Background()
+	now := time.Now().UTC()
+	features := []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count}}
+
+	snapshots := make([][]byte, 0, 2)
+	for _, n := range []int{3, 5} {
+		src, _ := gofeat.New(gofeat.Config{Features: features})
+		for i := range n {
+			for id := range 100 {
+				src.Push(ctx, strconv.Itoa(id), gofeat.Event{Timestamp: now.Add(-time.Duration(i) * time.Minute)})
+			}
+		}
+		var buf bytes.Buffer
+		if err := src.Snapshot(ctx, &buf); err != nil {
+			t.Fatalf("Snapshot failed: %v", err)
+		}
+		snapshots = append(snapshots, buf.Bytes())
+	}
+
+	store, _ := gofeat.New(gofeat.Config{Features: features})
+	if err := store.Restore(ctx, bytes.NewReader(snapshots[0])); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 200 {
+			if err := store.Restore(ctx, bytes.NewReader(snapshots[i%2])); err != nil {
+				t.Errorf("Restore failed: %v", err)
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		stats, _ := store.Stats(ctx)
+		if stats.TotalEvents != 300 && stats.TotalEvents != 500 {
+			t.Fatalf("got %d events during restore, want 300 or 500", stats.TotalEvents)
+		}
+	}
+}
+
+// blockingWriter blocks the first Write until release is closed.
+type blockingWriter struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	select {
+	case <-w.started:
+	default:
+		close(w.started)
+		<-w.release
+	}
+	return len(p), nil
+}
+
+func TestStore_Snapshot_SlowWriter(t *testing.T) {
+	// A Snapshot stuck on its writer must not block Restore, Push or Get
+	ctx := context.Background()
+	now := time.Now().UTC()
+	store := newSnapshotStore(t)
+	store.Push(ctx, "user1", gofeat.Event{Timestamp: now})
+	var snap bytes.Buffer
+	if err := store.Snapshot(ctx, &snap); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	w := &blockingWriter{started: make(chan struct{}), release: make(chan struct{})}
+	snapshotDone := make(chan error, 1)
+	go func() { snapshotDone <- store.Snapshot(ctx, w) }()
+	<-w.started
+
+	done := make(chan error, 1)
+	go func() {
+		if err := store.Restore(ctx, &snap); err != nil {
+			done <- err
+			return
+		}
+		if err := store.Push(ctx, "user1", gofeat.Event{Timestamp: now}); err != nil {
+			done <- err
+			return
+		}
+		_, err := store.Get(ctx, "user1")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Restore, Push or Get failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Restore, Push and Get blocked by a slow snapshot writer")
+	}
+
+	close(w.release)
+	if err := <-snapshotDone; err != nil {
+		t.Errorf("Snapshot failed: %v", err)
+	}
+	result, _ := store.Get(ctx, "user1")
+	if got := result.IntOr("count", -1); got != 2 {
+		t.Errorf("count: got %d, want 2", got)
+	}
+}
+
+func TestStore_Snapshot_Unsupported(t *testing.T) {
+	store, err := gofeat.New(gofeat.Config{
+		Storage:  &mockStorage{events: make(map[string][]gofeat.Event)},
+		Features: []gofeat.Feature{{Name: "count", Aggregate: gofeat.Count}},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := store.Snapshot(context.Background(), &buf); !errors.Is(err, gofeat.ErrSnapshotUnsupported) {
+		t.Errorf("Snapshot: expected ErrSnapshotUnsupported, got %v", err)
+	}
+	if err := store.Restore(context.Background(), &buf); !errors.Is(err, gofeat.ErrSnapshotUnsupported) {
+		t.Errorf("Restore: expected ErrSnapshotUnsupported, got %v", err)
+	}
+}
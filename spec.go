@@ -0,0 +1,741 @@
+package gofeat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AggregatorBuilder creates an aggregator factory from the parameters of
+// an aggregate spec in a JSON config.
+type AggregatorBuilder func(p *Params) (AggregatorFactory, error)
+
+// WindowBuilder creates a window from the parameters of a window spec in a
+// JSON config.
+type WindowBuilder func(p *Params) (Window, error)
+
+//nolint:gochecknoglobals // registry of named builders, guarded by registryMu
+var (
+	registryMu         sync.RWMutex
+	aggregatorBuilders = map[string]AggregatorBuilder{
+		"count":                 func(*Params) (AggregatorFactory, error) { return Count, nil },
+		"sum":                   fieldAggregator(Sum),
+		"min":                   fieldAggregator(Min),
+		"max":                   fieldAggregator(Max),
+		"last":                  fieldAggregator(Last),
+		"distinct_count":        fieldAggregator(DistinctCount),
+		"entropy":               fieldAggregator(Entropy),
+		"unique_ratio":          fieldAggregator(UniqueRatio),
+		"mean":                  fieldAggregator(Mean),
+		"standard_deviation":    fieldAggregator(StandardDeviation),
+		"time_since_first":      func(*Params) (AggregatorFactory, error) { return TimeSinceFirst(), nil },
+		"velocity":              durationAggregator("window", Velocity),
+		"session_count":         durationAggregator("gap", SessionCount),
+		"session_duration":      durationAggregator("gap", SessionDuration),
+		"percentile":            buildPercentile,
+		"approx_distinct_count": approxAggregator(ApproxDistinctCount),
+		"approx_unique_ratio":   approxAggregator(ApproxUniqueRatio),
+		"approx_percentile":     buildApproxPercentile,
+		"top_k":                 buildTopK,
+		"approx_top_k":          buildTopK,
+		"histogram":             buildHistogram,
+		"ewma":                  buildEWMA,
+		"decayed_count":         durationAggregator("half_life", DecayedCount),
+		"min_gap":               func(*Params) (AggregatorFactory, error) { return MinGap(), nil },
+		"mean_gap":              func(*Params) (AggregatorFactory, error) { return MeanGap(), nil },
+		"gap_std_dev":           func(*Params) (AggregatorFactory, error) { return GapStdDev(), nil },
+		"gap_coefficient_of_variation": func(*Params) (AggregatorFactory, error) {
+			return GapCoefficientOfVariation(), nil
+		},
+		"time_since_last": func(*Params) (AggregatorFactory, error) { return TimeSinceLast(), nil },
+	}
+	windowBuilders = map[string]WindowBuilder{
+		"lifetime":       func(*Params) (Window, error) { return Lifetime(), nil },
+		"sliding":        durationWindow("size", Sliding),
+		"session":        durationWindow("gap", Session),
+		"tumbling":       buildTumbling,
+		"hopping":        buildHopping,
+		"between":        buildBetween,
+		"last_n":         buildLastN,
+		"last_n_within":  buildLastNWithin,
+		"calendar_day":   buildCalendar,
+		"calendar_week":  buildCalendar,
+		"calendar_month": buildCalendar,
+	}
+)
+
+// RegisterAggregator makes a custom aggregator addressable by name in JSON
+// configs. Registering a name again replaces the previous builder, which
+// also allows overriding built-in kinds.
+func RegisterAggregator(name string, builder AggregatorBuilder) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	aggregatorBuilders[name] = builder
+}
+
+// RegisterWindow makes a custom window addressable by name in JSON configs.
+// Registering a name again replaces the previous builder.
+func RegisterWindow(name string, builder WindowBuilder) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	windowBuilders[name] = builder
+}
+
+// Params holds the parameters of an aggregate or window spec: all keys of
+// its JSON object except "kind". Builders read them with the typed getters;
+// LoadConfig rejects specs with parameters no getter asked for, so typos do
+// not go unnoticed.
+type Params struct {
+	kind string
+	raw  map[string]json.RawMessage
+	used map[string]bool
+}
+
+// Kind returns the kind of the spec, e.g. "sliding" or "calendar_week".
+func (p *Params) Kind() string { return p.kind }
+
+// Has reports whether the parameter is set.
+func (p *Params) Has(name string) bool {
+	_, ok := p.raw[name]
+	return ok
+}
+
+// Decode unmarshals a required parameter into v.
+func (p *Params) Decode(name string, v any) error {
+	raw, ok := p.raw[name]
+	if !ok {
+		return fmt.Errorf("missing parameter %q", name)
+	}
+	p.used[name] = true
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("parameter %q: %w", name, err)
+	}
+	return nil
+}
+
+// String returns a required string parameter.
+func (p *Params) String(name string) (string, error) {
+	var s string
+	err := p.Decode(name, &s)
+	return s, err
+}
+
+// Float returns a required numeric parameter.
+func (p *Params) Float(name string) (float64, error) {
+	var f float64
+	err := p.Decode(name, &f)
+	return f, err
+}
+
+// Int returns a required integer parameter.
+func (p *Params) Int(name string) (int, error) {
+	var n int
+	err := p.Decode(name, &n)
+	return n, err
+}
+
+// Duration returns a required duration parameter, written as a Go duration
+// string such as "90s" or "1h30m". A "d" suffix is accepted for days, e.g. "30d".
+func (p *Params) Duration(name string) (time.Duration, error) {
+	s, err := p.String(name)
+	if err != nil {
+		return 0, err
+	}
+	d, err := parseSpecDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("parameter %q: %w", name, err)
+	}
+	return d, nil
+}
+
+// unused returns the parameters no getter asked for, sorted.
+func (p *Params) unused() []string {
+	var names []string
+	for name := range p.raw {
+		if !p.used[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func parseSpecDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+// parsePositiveDuration parses a duration like parseSpecDuration and
+// requires it to be greater than zero.
+func parsePositiveDuration(s string) (time.Duration, error) {
+	d, err := parseSpecDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("must be positive, got %v", d)
+	}
+	return d, nil
+}
+
+// parseParams splits an aggregate or window spec into its kind and
+// parameters. A bare string is a kind without parameters.
+func parseParams(raw json.RawMessage) (*Params, error) {
+	p := &Params{used: make(map[string]bool)}
+	if err := json.Unmarshal(raw, &p.kind); err == nil {
+		return p, nil
+	}
+	if err := json.Unmarshal(raw, &p.raw); err != nil {
+		return nil, errors.New("expected a kind or an object")
+	}
+	kind, ok := p.raw["kind"]
+	if !ok {
+		return nil, errors.New("missing kind")
+	}
+	if err := json.Unmarshal(kind, &p.kind); err != nil {
+		return nil, errors.New("kind must be a string")
+	}
+	delete(p.raw, "kind")
+	return p, nil
+}
+
+// configSpec is the JSON representation of a Config read by LoadConfig.
+type configSpec struct {
+	TTL        string        `json:"ttl"`
+	BucketSize string        `json:"bucket_size"`
+	Entities   []entitySpec  `json:"entities"`
+	Features   []featureSpec `json:"features"`
+	Derived    []derivedSpec `json:"derived"`
+}
+
+type entitySpec struct {
+	Name  string `json:"name"`
+	Field string `json:"field"`
+}
+
+type derivedSpec struct {
+	Name   string `json:"name"`
+	Expr   string `json:"expr"`
+	Entity string `json:"entity"`
+}
+
+type featureSpec struct {
+	Name      string          `json:"name"`
+	Aggregate json.RawMessage `json:"aggregate"`
+	Window    json.RawMessage `json:"window"`
+	Filter    *filterSpec     `json:"filter"`
+	Entity    string          `json:"entity"`
+}
+
+type filterSpec struct {
+	Op        string       `json:"op"`
+	Field     string       `json:"field"`
+	Other     string       `json:"other"`
+	Value     any          `json:"value"`
+	Values    []any        `json:"values"`
+	Threshold *float64     `json:"threshold"`
+	Filters   []filterSpec `json:"filters"`
+}
+
+// LoadConfig reads a Config from a JSON feature spec:
+//
+//	{
+//	  "ttl": "30d",
+//	  "bucket_size": "1m",
+//	  "entities": [{"name": "card", "field": "card"}],
+//	  "features": [
+//	    {"name": "tx_1h", "aggregate": "count", "window": {"kind": "sliding", "size": "1h"}},
+//	    {"name": "declined_sum_1h", "aggregate": {"kind": "sum", "field": "amount"},
+//	     "window": {"kind": "sliding", "size": "1h"},
+//	     "filter": {"op": "field_equals", "field": "status", "value": "declined"}},
+//	    {"name": "card_users", "aggregate": {"kind": "distinct_count", "field": "user"}, "entity": "card"}
+//	  ],
+//	  "derived": [{"name": "declined_share", "expr": "declined_sum_1h / max(tx_1h, 1)"}]
+//	}
+//
+// Aggregate and window specs are a kind, optionally with parameters. The
+// built-in aggregate kinds are count, sum, min, max, last, distinct_count,
+// entropy, unique_ratio, mean, standard_deviation (field), percentile
+// (field, p, optional interpolation: lower, higher, nearest, linear or
+// midpoint), approx_percentile (field, ps), approx_distinct_count and
+// approx_unique_ratio (field, optional precision, default 14), top_k
+// (field, k), approx_top_k (field, k, capacity), histogram (field,
+// boundaries), ewma (field, half_life), decayed_count (half_life),
+// time_since_first, time_since_last, min_gap, mean_gap, gap_std_dev,
+// gap_coefficient_of_variation, velocity (window) and
+// session_count and session_duration (gap). The built-in window kinds are lifetime (the
+// default), sliding (size), session (gap), tumbling (size, origin),
+// hopping (size, hop, origin), between (from, to), last_n (n),
+// last_n_within (n, size) and calendar_day, calendar_week (start_day) and
+// calendar_month (location, location_field). Durations use Go syntax with
+// an optional "d" suffix for days. ttl, bucket_size, window sizes, gaps,
+// hops and n must be positive, and between needs from > to >= 0. Custom
+// kinds are added with RegisterAggregator and RegisterWindow.
+//
+// Filter ops are field_exists (field), field_equals (field, value),
+// field_in (field, values), field_greater_than (field, threshold),
+// fields_equal (field, other), and, or and not (filters).
+//
+// Storage is not part of the spec; set it on the returned Config.
+func LoadConfig(r io.Reader) (Config, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var spec configSpec
+	if err := dec.Decode(&spec); err != nil {
+		return Config{}, fmt.Errorf("gofeat: parse config: %w", err)
+	}
+
+	var cfg Config
+	var err error
+	if spec.TTL != "" {
+		if cfg.TTL, err = parsePositiveDuration(spec.TTL); err != nil {
+			return Config{}, fmt.Errorf("gofeat: config ttl: %w", err)
+		}
+	}
+	if spec.BucketSize != "" {
+		if cfg.BucketSize, err = parsePositiveDuration(spec.BucketSize); err != nil {
+			return Config{}, fmt.Errorf("gofeat: config bucket_size: %w", err)
+		}
+	}
+	for _, e := range spec.Entities {
+		cfg.Entities = append(cfg.Entities, EntityType{Name: e.Name, Field: e.Field})
+	}
+	for _, d := range spec.Derived {
+		cfg.Derived = append(cfg.Derived, DerivedFeature{Name: d.Name, Expr: d.Expr, Entity: d.Entity})
+	}
+
+	for _, fs := range spec.Features {
+		f, err := fs.build()
+		if err != nil {
+			return Config{}, fmt.Errorf("gofeat: feature %q: %w", fs.Name, err)
+		}
+		cfg.Features = append(cfg.Features, f)
+	}
+	return cfg, nil
+}
+
+func (fs featureSpec) build() (Feature, error) {
+	f := Feature{Name: fs.Name, Entity: fs.Entity}
+	if len(fs.Aggregate) == 0 {
+		return Feature{}, errors.New("aggregate required")
+	}
+
+	p, err := parseParams(fs.Aggregate)
+	if err != nil {
+		return Feature{}, fmt.Errorf("aggregate: %w", err)
+	}
+	registryMu.RLock()
+	aggBuilder, ok := aggregatorBuilders[p.kind]
+	registryMu.RUnlock()
+	if !ok {
+		return Feature{}, fmt.Errorf("aggregate: unknown kind %q", p.kind)
+	}
+	if f.Aggregate, err = aggBuilder(p); err != nil {
+		return Feature{}, fmt.Errorf("aggregate %s: %w", p.kind, err)
+	}
+	if unused := p.unused(); len(unused) > 0 {
+		return Feature{}, fmt.Errorf("aggregate %s: unknown parameters %s", p.kind, strings.Join(unused, ", "))
+	}
+
+	if len(fs.Window) > 0 && !bytes.Equal(fs.Window, []byte("null")) {
+		if p, err = parseParams(fs.Window); err != nil {
+			return Feature{}, fmt.Errorf("window: %w", err)
+		}
+		registryMu.RLock()
+		winBuilder, ok := windowBuilders[p.kind]
+		registryMu.RUnlock()
+		if !ok {
+			return Feature{}, fmt.Errorf("window: unknown kind %q", p.kind)
+		}
+		if f.Window, err = winBuilder(p); err != nil {
+			return Feature{}, fmt.Errorf("window %s: %w", p.kind, err)
+		}
+		if unused := p.unused(); len(unused) > 0 {
+			return Feature{}, fmt.Errorf("window %s: unknown parameters %s", p.kind, strings.Join(unused, ", "))
+		}
+	}
+
+	if fs.Filter != nil {
+		if f.Filter, err = fs.Filter.build(); err != nil {
+			return Feature{}, fmt.Errorf("filter: %w", err)
+		}
+	}
+	return f, nil
+}
+
+func (fs filterSpec) build() (Predicate, error) {
+	switch fs.Op {
+	case "field_exists", "field_equals", "field_in", "field_greater_than", "fields_equal":
+		if fs.Field == "" {
+			return nil, fmt.Errorf("%s: field required", fs.Op)
+		}
+	}
+
+	switch fs.Op {
+	case "field_exists":
+		return FieldExists(fs.Field), nil
+	case "field_equals":
+		return FieldEquals(fs.Field, fs.Value), nil
+	case "field_in":
+		return FieldIn(fs.Field, fs.Values...), nil
+	case "field_greater_than":
+		if fs.Threshold == nil {
+			return nil, errors.New("field_greater_than: threshold required")
+		}
+		return FieldGreaterThan(fs.Field, *fs.Threshold), nil
+	case "fields_equal":
+		if fs.Other == "" {
+			return nil, errors.New("fields_equal: other required")
+		}
+		return FieldsEqual(fs.Field, fs.Other), nil
+	case "and", "or", "not":
+		preds := make([]Predicate, 0, len(fs.Filters))
+		for _, sub := range fs.Filters {
+			pred, err := sub.build()
+			if err != nil {
+				return nil, err
+			}
+			preds = append(preds, pred)
+		}
+		switch fs.Op {
+		case "and":
+			return And(preds...), nil
+		case "or":
+			return Or(preds...), nil
+		}
+		if len(preds) != 1 {
+			return nil, errors.New("not: exactly one filter required")
+		}
+		return Not(preds[0]), nil
+	default:
+		return nil, fmt.Errorf("unknown op %q", fs.Op)
+	}
+}
+
+func fieldAggregator(fn func(field string) AggregatorFactory) AggregatorBuilder {
+	return func(p *Params) (AggregatorFactory, error) {
+		field, err := p.String("field")
+		if err != nil {
+			return nil, err
+		}
+		return fn(field), nil
+	}
+}
+
+func durationAggregator(name string, fn func(d time.Duration) AggregatorFactory) AggregatorBuilder {
+	return func(p *Params) (AggregatorFactory, error) {
+		d, err := p.Duration(name)
+		if err != nil {
+			return nil, err
+		}
+		return fn(d), nil
+	}
+}
+
+func buildPercentile(p *Params) (AggregatorFactory, error) {
+	field, err := p.String("field")
+	if err != nil {
+		return nil, err
+	}
+	q, err := p.Float("p")
+	if err != nil {
+		return nil, err
+	}
+	if q < 0 || q > 1 {
+		return nil, fmt.Errorf("p must be between 0 and 1, got %v", q)
+	}
+	if !p.Has("interpolation") {
+		return Percentile(field, q), nil
+	}
+	name, err := p.String("interpolation")
+	if err != nil {
+		return nil, err
+	}
+	mode, ok := map[string]Interpolation{
+		"lower":    InterpolationLower,
+		"higher":   InterpolationHigher,
+		"nearest":  InterpolationNearest,
+		"linear":   InterpolationLinear,
+		"midpoint": InterpolationMidpoint,
+	}[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown interpolation %q", name)
+	}
+	return Percentile(field, q, WithInterpolation(mode)), nil
+}
+
+func buildApproxPercentile(p *Params) (AggregatorFactory, error) {
+	field, err := p.String("field")
+	if err != nil {
+		return nil, err
+	}
+	var ps []float64
+	if err := p.Decode("ps", &ps); err != nil {
+		return nil, err
+	}
+	if len(ps) == 0 {
+		return nil, errors.New("ps must not be empty")
+	}
+	for _, q := range ps {
+		if q < 0 || q > 1 {
+			return nil, fmt.Errorf("ps must be between 0 and 1, got %v", q)
+		}
+	}
+	return ApproxPercentile(field, ps...), nil
+}
+
+// approxAggregator reads the field and the optional precision parameter
+// of a HyperLogLog aggregator.
+func approxAggregator(fn func(field string, precision int) AggregatorFactory) AggregatorBuilder {
+	return func(p *Params) (AggregatorFactory, error) {
+		field, err := p.String("field")
+		if err != nil {
+			return nil, err
+		}
+		precision := DefaultApproxPrecision
+		if p.Has("precision") {
+			if precision, err = p.Int("precision"); err != nil {
+				return nil, err
+			}
+			if precision < MinApproxPrecision || precision > MaxApproxPrecision {
+				return nil, fmt.Errorf("precision must be between %d and %d, got %d", MinApproxPrecision, MaxApproxPrecision, precision)
+			}
+		}
+		return fn(field, precision), nil
+	}
+}
+
+// buildTopK builds top_k and approx_top_k aggregators.
+func buildTopK(p *Params) (AggregatorFactory, error) {
+	field, err := p.String("field")
+	if err != nil {
+		return nil, err
+	}
+	k, err := p.Int("k")
+	if err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return nil, fmt.Errorf("k must be positive, got %d", k)
+	}
+	if p.Kind() == "top_k" {
+		return TopK(field, k), nil
+	}
+	capacity, err := p.Int("capacity")
+	if err != nil {
+		return nil, err
+	}
+	if capacity < k {
+		return nil, fmt.Errorf("capacity must be at least k, got %d", capacity)
+	}
+	return ApproxTopK(field, k, capacity), nil
+}
+
+func buildHistogram(p *Params) (AggregatorFactory, error) {
+	field, err := p.String("field")
+	if err != nil {
+		return nil, err
+	}
+	var boundaries []float64
+	if err := p.Decode("boundaries", &boundaries); err != nil {
+		return nil, err
+	}
+	if len(boundaries) == 0 {
+		return nil, errors.New("boundaries must not be empty")
+	}
+	if !slices.IsSorted(boundaries) {
+		return nil, errors.New("boundaries must be sorted")
+	}
+	return Histogram(field, boundaries...), nil
+}
+
+func buildEWMA(p *Params) (AggregatorFactory, error) {
+	field, err := p.String("field")
+	if err != nil {
+		return nil, err
+	}
+	halfLife, err := p.Duration("half_life")
+	if err != nil {
+		return nil, err
+	}
+	return EWMA(field, halfLife), nil
+}
+
+func durationWindow(name string, fn func(d time.Duration) Window) WindowBuilder {
+	return func(p *Params) (Window, error) {
+		d, err := positiveDuration(p, name)
+		if err != nil {
+			return nil, err
+		}
+		return fn(d), nil
+	}
+}
+
+// positiveDuration returns a required duration parameter that must be
+// greater than zero.
+func positiveDuration(p *Params, name string) (time.Duration, error) {
+	d, err := p.Duration(name)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be positive, got %v", name, d)
+	}
+	return d, nil
+}
+
+// positiveInt returns a required integer parameter that must be greater
+// than zero.
+func positiveInt(p *Params, name string) (int, error) {
+	n, err := p.Int(name)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("%s must be positive, got %d", name, n)
+	}
+	return n, nil
+}
+
+// originOpt reads the optional origin parameter of fixed-size windows.
+func originOpt(p *Params) ([]HoppingOption, error) {
+	if !p.Has("origin") {
+		return nil, nil
+	}
+	var origin time.Time
+	if err := p.Decode("origin", &origin); err != nil {
+		return nil, err
+	}
+	return []HoppingOption{WithOrigin(origin)}, nil
+}
+
+func buildTumbling(p *Params) (Window, error) {
+	size, err := positiveDuration(p, "size")
+	if err != nil {
+		return nil, err
+	}
+	opts, err := originOpt(p)
+	if err != nil {
+		return nil, err
+	}
+	return Tumbling(size, opts...), nil
+}
+
+func buildHopping(p *Params) (Window, error) {
+	size, err := positiveDuration(p, "size")
+	if err != nil {
+		return nil, err
+	}
+	hop, err := positiveDuration(p, "hop")
+	if err != nil {
+		return nil, err
+	}
+	opts, err := originOpt(p)
+	if err != nil {
+		return nil, err
+	}
+	return Hopping(size, hop, opts...), nil
+}
+
+func buildBetween(p *Params) (Window, error) {
+	from, err := positiveDuration(p, "from")
+	if err != nil {
+		return nil, err
+	}
+	to, err := p.Duration("to")
+	if err != nil {
+		return nil, err
+	}
+	if to < 0 {
+		return nil, fmt.Errorf("to must not be negative, got %v", to)
+	}
+	if from <= to {
+		return nil, fmt.Errorf("from must be greater than to, got %v and %v", from, to)
+	}
+	return Between(from, to), nil
+}
+
+func buildLastN(p *Params) (Window, error) {
+	n, err := positiveInt(p, "n")
+	if err != nil {
+		return nil, err
+	}
+	return LastN(n), nil
+}
+
+func buildLastNWithin(p *Params) (Window, error) {
+	n, err := positiveInt(p, "n")
+	if err != nil {
+		return nil, err
+	}
+	size, err := positiveDuration(p, "size")
+	if err != nil {
+		return nil, err
+	}
+	return LastNWithin(n, size), nil
+}
+
+// buildCalendar builds calendar_day, calendar_week and calendar_month
+// windows. location is an IANA time zone name and defaults to UTC.
+func buildCalendar(p *Params) (Window, error) {
+	loc := time.UTC
+	if p.Has("location") {
+		name, err := p.String("location")
+		if err != nil {
+			return nil, err
+		}
+		if loc, err = time.LoadLocation(name); err != nil {
+			return nil, fmt.Errorf("parameter %q: %w", "location", err)
+		}
+	}
+	var opts []CalendarOption
+	if p.Has("location_field") {
+		field, err := p.String("location_field")
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithLocationField(field))
+	}
+
+	switch p.kind {
+	case "calendar_week":
+		startDay := time.Monday
+		if p.Has("start_day") {
+			name, err := p.String("start_day")
+			if err != nil {
+				return nil, err
+			}
+			if startDay, err = parseWeekday(name); err != nil {
+				return nil, err
+			}
+		}
+		return CalendarWeek(loc, startDay, opts...), nil
+	case "calendar_month":
+		return CalendarMonth(loc, opts...), nil
+	default:
+		return CalendarDay(loc, opts...), nil
+	}
+}
+
+func parseWeekday(name string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(name, d.String()) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid start_day %q", name)
+}
@@ -0,0 +1,321 @@
+package gofeat_test
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+const specTestConfig = `{
+  "ttl": "30d",
+  "bucket_size": "1m",
+  "entities": [{"name": "card", "field": "card"}],
+  "features": [
+    {"name": "tx_count", "aggregate": "count"},
+    {"name": "tx_1h", "aggregate": {"kind": "count"}, "window": {"kind": "sliding", "size": "1h"}},
+    {"name": "declined_sum", "aggregate": {"kind": "sum", "field": "amount"},
+     "filter": {"op": "and", "filters": [
+       {"op": "field_equals", "field": "status", "value": "declined"},
+       {"op": "field_greater_than", "field": "amount", "threshold": 5}
+     ]}},
+    {"name": "foreign", "aggregate": "count",
+     "filter": {"op": "not", "filters": [{"op": "fields_equal", "field": "country", "other": "home_country"}]}},
+    {"name": "p50", "aggregate": {"kind": "percentile", "field": "amount", "p": 0.5}, "window": {"kind": "last_n", "n": 3}},
+    {"name": "baseline", "aggregate": "count", "window": {"kind": "between", "from": "1d", "to": "30m"}},
+    {"name": "today", "aggregate": "count",
+     "window": {"kind": "calendar_day", "location": "Europe/Berlin", "location_field": "tz"}},
+    {"name": "week", "aggregate": "count", "window": {"kind": "calendar_week", "start_day": "sunday"}},
+    {"name": "session", "aggregate": {"kind": "session_count", "gap": "15m"}},
+    {"name": "hop", "aggregate": "count",
+     "window": {"kind": "hopping", "size": "1h", "hop": "10m", "origin": "2024-01-01T00:05:00Z"}},
+    {"name": "card_users", "aggregate": {"kind": "distinct_count", "field": "user"}, "entity": "card"}
+  ],
+  "derived": [
+    {"name": "declined_share", "expr": "declined_sum / max(tx_count, 1)"},
+    {"name": "card_users_x2", "expr": "card_users * 2", "entity": "card"}
+  ]
+}`
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := gofeat.LoadConfig(strings.NewReader(specTestConfig))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.TTL != 30*24*time.Hour || cfg.BucketSize != time.Minute {
+		t.Errorf("ttl %v, bucket size %v", cfg.TTL, cfg.BucketSize)
+	}
+	if len(cfg.Features) != 11 || len(cfg.Derived) != 2 || len(cfg.Entities) != 1 {
+		t.Fatalf("got %d features, %d derived, %d entities", len(cfg.Features), len(cfg.Derived), len(cfg.Entities))
+	}
+
+	store, err := gofeat.New(cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	events := []gofeat.Event{
+		{Timestamp: now.Add(-2 * time.Hour), Data: map[string]any{"status": "declined", "amount": 10.0, "country": "US", "home_country": "US"}},
+		{Timestamp: now.Add(-20 * time.Minute), Data: map[string]any{"status": "declined", "amount": 1.0, "country": "DE", "home_country": "US"}},
+		{Timestamp: now.Add(-10 * time.Minute), Data: map[string]any{"status": "approved", "amount": 30.0, "country": "US", "home_country": "US"}},
+	}
+	store.Push(ctx, "user1", events...)
+
+	result, err := store.GetAt(ctx, "user1", now)
+	if err != nil {
+		t.Fatalf("GetAt failed: %v", err)
+	}
+	checks := map[string]float64{
+		"tx_1h":          2,
+		"declined_sum":   10,
+		"foreign":        1,
+		"p50":            10,
+		"baseline":       1,
+		"session":        2,
+		"declined_share": 10.0 / 3,
+	}
+	for name, want := range checks {
+		if got := floatValue(t, result, name); got != want {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+	}
+
+	store.Ingest(ctx, gofeat.Event{Timestamp: now, Data: map[string]any{"card": "4111", "user": "u1"}})
+	card, _ := store.GetEntityAt(ctx, "card", "4111", now)
+	if got := card.FloatOr("card_users_x2", -1); got != 2 {
+		t.Errorf("card_users_x2: got %v, want 2", got)
+	}
+}
+
+// floatValue reads a numeric feature regardless of its Go type.
+func floatValue(t *testing.T, r gofeat.Result, name string) float64 {
+	t.Helper()
+	if i, err := r.Int(name); err == nil {
+		return float64(i)
+	}
+	f, err := r.Float(name)
+	if err != nil {
+		t.Errorf("%s: %v", name, err)
+	}
+	return f
+}
+
+func TestRegisterAggregator(t *testing.T) {
+	gofeat.RegisterAggregator("test_median", func(p *gofeat.Params) (gofeat.AggregatorFactory, error) {
+		field, err := p.String("field")
+		if err != nil {
+			return nil, err
+		}
+		return func() gofeat.Aggregator { return &testMedian{field: field} }, nil
+	})
+	gofeat.RegisterWindow("test_last_hour", func(*gofeat.Params) (gofeat.Window, error) {
+		return gofeat.Sliding(time.Hour), nil
+	})
+
+	cfg, err := gofeat.LoadConfig(strings.NewReader(`{"features": [
+		{"name": "median", "aggregate": {"kind": "test_median", "field": "amount"}, "window": "test_last_hour"}
+	]}`))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	store, _ := gofeat.New(cfg)
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for i, amount := range []float64{100, 1, 3, 2} {
+		store.Push(ctx, "user1", gofeat.Event{
+			Timestamp: now.Add(-time.Duration(75-i*20) * time.Minute),
+			Data:      map[string]any{"amount": amount},
+		})
+	}
+	result, _ := store.GetAt(ctx, "user1", now)
+	if got := result.FloatOr("median", -1); got != 2 {
+		t.Errorf("median: got %v, want 2", got)
+	}
+}
+
+type testMedian struct {
+	field  string
+	values []float64
+}
+
+func (a *testMedian) Add(e gofeat.Event) {
+	if f, ok := e.Data[a.field].(float64); ok {
+		a.values = append(a.values, f)
+	}
+}
+
+func (a *testMedian) Result() any {
+	if len(a.values) == 0 {
+		return 0.0
+	}
+	sort.Float64s(a.values)
+	return a.values[len(a.values)/2]
+}
+
+func TestLoadConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr string
+	}{
+		{name: "invalid json", spec: `{`, wantErr: "parse config"},
+		{name: "unknown top-level field", spec: `{"featrues": []}`, wantErr: "unknown field"},
+		{name: "bad ttl", spec: `{"ttl": "soon"}`, wantErr: "ttl"},
+		{name: "zero ttl", spec: `{"ttl": "0s"}`, wantErr: "ttl: must be positive"},
+		{name: "negative bucket size", spec: `{"bucket_size": "-1m"}`, wantErr: "bucket_size: must be positive"},
+		{name: "missing aggregate", spec: `{"features": [{"name": "a"}]}`, wantErr: `feature "a": aggregate required`},
+		{name: "unknown aggregate", spec: `{"features": [{"name": "a", "aggregate": "median"}]}`, wantErr: `unknown kind "median"`},
+		{name: "missing kind", spec: `{"features": [{"name": "a", "aggregate": {"field": "x"}}]}`, wantErr: "missing kind"},
+		{name: "missing parameter", spec: `{"features": [{"name": "a", "aggregate": "sum"}]}`, wantErr: `missing parameter "field"`},
+		{
+			name:    "unknown parameter",
+			spec:    `{"features": [{"name": "a", "aggregate": {"kind": "sum", "field": "x", "feild": "y"}}]}`,
+			wantErr: "unknown parameters feild",
+		},
+		{
+			name:    "wrong parameter type",
+			spec:    `{"features": [{"name": "a", "aggregate": {"kind": "sum", "field": 1}}]}`,
+			wantErr: `parameter "field"`,
+		},
+		{
+			name:    "percentile out of range",
+			spec:    `{"features": [{"name": "a", "aggregate": {"kind": "percentile", "field": "x", "p": 95}}]}`,
+			wantErr: "between 0 and 1",
+		},
+		{
+			name:    "unknown interpolation",
+			spec:    `{"features": [{"name": "a", "aggregate": {"kind": "percentile", "field": "x", "p": 0.5, "interpolation": "cubic"}}]}`,
+			wantErr: `unknown interpolation "cubic"`,
+		},
+		{
+			name:    "approx percentile without ps",
+			spec:    `{"features": [{"name": "a", "aggregate": {"kind": "approx_percentile", "field": "x", "ps": []}}]}`,
+			wantErr: "ps must not be empty",
+		},
+		{
+			name:    "unsorted histogram boundaries",
+			spec:    `{"features": [{"name": "a", "aggregate": {"kind": "histogram", "field": "x", "boundaries": [10, 1]}}]}`,
+			wantErr: "boundaries must be sorted",
+		},
+		{
+			name:    "top_k with capacity",
+			spec:    `{"features": [{"name": "a", "aggregate": {"kind": "top_k", "field": "x", "k": 3, "capacity": 10}}]}`,
+			wantErr: "unknown parameters capacity",
+		},
+		{
+			name:    "approx precision out of range",
+			spec:    `{"features": [{"name": "a", "aggregate": {"kind": "approx_distinct_count", "field": "x", "precision": 20}}]}`,
+			wantErr: "precision must be between 4 and 16",
+		},
+		{
+			name:    "unknown window",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "window": "forever"}]}`,
+			wantErr: `window: unknown kind "forever"`,
+		},
+		{
+			name:    "bad duration",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "window": {"kind": "sliding", "size": "1 hour"}}]}`,
+			wantErr: `parameter "size"`,
+		},
+		{
+			name:    "zero tumbling size",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "window": {"kind": "tumbling", "size": "0s"}}]}`,
+			wantErr: "size must be positive",
+		},
+		{
+			name:    "negative sliding size",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "window": {"kind": "sliding", "size": "-1h"}}]}`,
+			wantErr: "size must be positive",
+		},
+		{
+			name:    "zero hop",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "window": {"kind": "hopping", "size": "1h", "hop": "0s"}}]}`,
+			wantErr: "hop must be positive",
+		},
+		{
+			name:    "between from not after to",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "window": {"kind": "between", "from": "1h", "to": "2h"}}]}`,
+			wantErr: "from must be greater than to",
+		},
+		{
+			name:    "between negative to",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "window": {"kind": "between", "from": "1h", "to": "-1h"}}]}`,
+			wantErr: "to must not be negative",
+		},
+		{
+			name:    "between zero from",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "window": {"kind": "between", "from": "0s", "to": "0s"}}]}`,
+			wantErr: "from must be positive",
+		},
+		{
+			name:    "zero last_n",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "window": {"kind": "last_n", "n": 0}}]}`,
+			wantErr: "n must be positive",
+		},
+		{
+			name:    "negative last_n_within n",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "window": {"kind": "last_n_within", "n": -1, "size": "1h"}}]}`,
+			wantErr: "n must be positive",
+		},
+		{
+			name:    "zero last_n_within size",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "window": {"kind": "last_n_within", "n": 5, "size": "0s"}}]}`,
+			wantErr: "size must be positive",
+		},
+		{
+			name:    "origin on sliding",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "window": {"kind": "sliding", "size": "1h", "origin": "2024-01-01T00:00:00Z"}}]}`,
+			wantErr: "unknown parameters origin",
+		},
+		{
+			name:    "location_field on tumbling",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "window": {"kind": "tumbling", "size": "1h", "location_field": "tz"}}]}`,
+			wantErr: "unknown parameters location_field",
+		},
+		{
+			name:    "origin on calendar_day",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "window": {"kind": "calendar_day", "origin": "2024-01-01T00:00:00Z"}}]}`,
+			wantErr: "unknown parameters origin",
+		},
+		{
+			name:    "bad location",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "window": {"kind": "calendar_day", "location": "Mars/Base"}}]}`,
+			wantErr: `parameter "location"`,
+		},
+		{
+			name:    "start day on calendar_day",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "window": {"kind": "calendar_day", "start_day": "monday"}}]}`,
+			wantErr: "unknown parameters start_day",
+		},
+		{
+			name:    "unknown filter op",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "filter": {"op": "like", "field": "x"}}]}`,
+			wantErr: `unknown op "like"`,
+		},
+		{
+			name:    "filter without field",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "filter": {"op": "field_exists"}}]}`,
+			wantErr: "field required",
+		},
+		{
+			name:    "not with two filters",
+			spec:    `{"features": [{"name": "a", "aggregate": "count", "filter": {"op": "not", "filters": [{"op": "and"}, {"op": "or"}]}}]}`,
+			wantErr: "exactly one filter",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := gofeat.LoadConfig(strings.NewReader(tt.spec))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
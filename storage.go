@@ -6,6 +6,7 @@ import (
 	"slices"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -28,6 +29,27 @@ type Storage interface {
 	Close() error
 }
 
+// BatchPusher is implemented by storages that can add events for several
+// entities at once. Either all events are stored or none are, and readers
+// never observe a partially applied batch.
+type BatchPusher interface {
+	PushBatch(ctx context.Context, batch map[string][]Event) error
+}
+
+// EvictCounter is implemented by storages that report how many events an
+// eviction removed. Store uses the count for Eviction.Events.
+type EvictCounter interface {
+	// EvictCount evicts like Evict and returns the number of events removed.
+	EvictCount(ctx context.Context) (int64, error)
+}
+
+// TTLStorage is implemented by storages that drop events older than a
+// fixed TTL. Store.Evict uses it to drop the matching bucket state of
+// incremental aggregation (Config.BucketSize).
+type TTLStorage interface {
+	TTL() time.Duration
+}
+
 type StorageStats struct {
 	Entities    int
 	TotalEvents int64
@@ -35,7 +57,7 @@ type StorageStats struct {
 
 // memoryStorage is an in-memory implementation of Storage.
 type memoryStorage struct {
-	entities sync.Map // string -> *entityStore
+	entities atomic.Pointer[sync.Map] // string -> *entityStore, replaced as a whole by Restore
 	ttl      time.Duration
 }
 
@@ -45,13 +67,17 @@ type entityStore struct {
 }
 
 func NewMemoryStorage(ttl time.Duration) Storage {
-	return &memoryStorage{
-		ttl: ttl,
-	}
+	return newMemoryStorage(ttl)
+}
+
+func newMemoryStorage(ttl time.Duration) *memoryStorage {
+	s := &memoryStorage{ttl: ttl}
+	s.entities.Store(&sync.Map{})
+	return s
 }
 
 func (s *memoryStorage) Push(ctx context.Context, entityID string, events ...Event) error {
-	v, _ := s.entities.LoadOrStore(entityID, &entityStore{})
+	v, _ := s.entities.Load().LoadOrStore(entityID, &entityStore{})
 	es, ok := v.(*entityStore)
 	if !ok {
 		return fmt.Errorf("unknown storage type: %v", v)
@@ -59,7 +85,46 @@ func (s *memoryStorage) Push(ctx context.Context, entityID string, events ...Eve
 
 	es.mu.Lock()
 	defer es.mu.Unlock()
+	es.insert(events)
+
+	return nil
+}
+
+func (s *memoryStorage) PushBatch(ctx context.Context, batch map[string][]Event) error {
+	// Lock in key order so that concurrent batches cannot deadlock
+	ids := make([]string, 0, len(batch))
+	for id, events := range batch {
+		if len(events) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	// Load the map once so that a concurrent Restore cannot split the batch
+	entities := s.entities.Load()
+	stores := make([]*entityStore, 0, len(ids))
+	for _, id := range ids {
+		v, _ := entities.LoadOrStore(id, &entityStore{})
+		es, ok := v.(*entityStore)
+		if !ok {
+			return fmt.Errorf("unknown storage type: %v", v)
+		}
+		stores = append(stores, es)
+	}
+
+	for _, es := range stores {
+		es.mu.Lock()
+		defer es.mu.Unlock()
+	}
+	for i, es := range stores {
+		es.insert(batch[ids[i]])
+	}
+
+	return nil
+}
 
+// insert adds events keeping them sorted by timestamp. Caller holds es.mu.
+func (es *entityStore) insert(events []Event) {
 	// Optimize for single event: binary insert O(log n) instead of full sort O(n log n)
 	if len(events) == 1 {
 		e := events[0]
@@ -74,12 +139,10 @@ func (s *memoryStorage) Push(ctx context.Context, entityID string, events ...Eve
 			return es.events[i].Timestamp.Before(es.events[j].Timestamp)
 		})
 	}
-
-	return nil
 }
 
 func (s *memoryStorage) Get(ctx context.Context, entityID string, at time.Time) ([]Event, error) {
-	v, ok := s.entities.Load(entityID)
+	v, ok := s.entities.Load().Load(entityID)
 	if !ok {
 		return nil, nil
 	}
@@ -114,13 +177,19 @@ func (s *memoryStorage) Get(ctx context.Context, entityID string, at time.Time)
 }
 
 func (s *memoryStorage) Evict(ctx context.Context) error {
+	_, err := s.EvictCount(ctx)
+	return err
+}
+
+func (s *memoryStorage) EvictCount(ctx context.Context) (int64, error) {
 	if s.ttl == 0 {
-		return nil
+		return 0, nil
 	}
 
 	before := time.Now().UTC().Add(-s.ttl)
 
-	s.entities.Range(func(key, value any) bool {
+	var evicted int64
+	s.entities.Load().Range(func(key, value any) bool {
 		es, ok := value.(*entityStore)
 		if !ok {
 			return true
@@ -131,19 +200,20 @@ func (s *memoryStorage) Evict(ctx context.Context) error {
 		})
 		if idx > 0 {
 			es.events = es.events[idx:]
+			evicted += int64(idx)
 		}
 		es.mu.Unlock()
 		return true
 	})
 
-	return nil
+	return evicted, nil
 }
 
 func (s *memoryStorage) Stats(ctx context.Context) (StorageStats, error) {
 	var entities int
 	var total int64
 
-	s.entities.Range(func(key, value any) bool {
+	s.entities.Load().Range(func(key, value any) bool {
 		entities++
 		es, ok := value.(*entityStore)
 		if !ok {
@@ -161,6 +231,10 @@ func (s *memoryStorage) Stats(ctx context.Context) (StorageStats, error) {
 	}, nil
 }
 
+func (s *memoryStorage) TTL() time.Duration {
+	return s.ttl
+}
+
 func (s *memoryStorage) Close() error {
 	return nil
 }
@@ -169,6 +169,26 @@ func TestMemoryStorage_Evict(t *testing.T) {
 	}
 }
 
+func TestMemoryStorage_EvictCount(t *testing.T) {
+	s := gofeat.NewMemoryStorage(time.Hour)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	s.Push(ctx, "user1", gofeat.Event{Timestamp: now.Add(-3 * time.Hour)}, gofeat.Event{Timestamp: now})
+	s.Push(ctx, "user2", gofeat.Event{Timestamp: now.Add(-2 * time.Hour)})
+
+	n, err := s.(gofeat.EvictCounter).EvictCount(ctx)
+	if err != nil {
+		t.Fatalf("EvictCount failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("evicted got %d, want 2", n)
+	}
+	if n, _ = s.(gofeat.EvictCounter).EvictCount(ctx); n != 0 {
+		t.Errorf("second eviction: got %d, want 0", n)
+	}
+}
+
 func TestMemoryStorage_Evict_NoTTL(t *testing.T) {
 	s := gofeat.NewMemoryStorage(0) // no TTL
 	ctx := context.Background()
@@ -327,3 +347,32 @@ func TestMemoryStorage_Close(t *testing.T) {
 		t.Errorf("Close returned error: %v", err)
 	}
 }
+
+func TestMemoryStorage_PushBatch(t *testing.T) {
+	storage := gofeat.NewMemoryStorage(0)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	bp, ok := storage.(gofeat.BatchPusher)
+	if !ok {
+		t.Fatal("memory storage must implement BatchPusher")
+	}
+	storage.Push(ctx, "user/u1", gofeat.Event{Timestamp: now, Data: map[string]any{"n": 2}})
+	err := bp.PushBatch(ctx, map[string][]gofeat.Event{
+		"user/u1":   {{Timestamp: now.Add(-time.Minute), Data: map[string]any{"n": 1}}},
+		"card/4111": {{Timestamp: now, Data: map[string]any{"n": 1}}},
+		"empty":     nil,
+	})
+	if err != nil {
+		t.Fatalf("PushBatch failed: %v", err)
+	}
+
+	events, _ := storage.Get(ctx, "user/u1", now)
+	if len(events) != 2 || events[0].Data["n"] != 1 {
+		t.Errorf("user/u1: unexpected events %v", events)
+	}
+	stats, _ := storage.Stats(ctx)
+	if stats.Entities != 2 || stats.TotalEvents != 3 {
+		t.Errorf("stats: got %+v", stats)
+	}
+}
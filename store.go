@@ -4,19 +4,89 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Store struct {
-	storage  Storage
+	storage    Storage
+	features   atomic.Pointer[featureSet]
+	writeMu    sync.RWMutex // shared by writers, exclusive while features are swapped
+	entities   []EntityType
+	derived    []DerivedFeature
+	bucketSize time.Duration
+	ttl        time.Duration // TTL reported by the storage (TTLStorage), 0 if none
+	backend    Storage       // storage, reporting calls to obs if set
+	obs        Observer      // nil if neither Config.Observer nor Config.Metrics is set
+}
+
+// featureSet is the immutable set of features used by a Store. It is
+// replaced as a whole by UpdateFeatures.
+type featureSet struct {
+	features []Feature                // as configured, with default windows set
+	groups   map[string]*featureGroup // entity type -> features, "" for Push/Get
+}
+
+// featureGroup holds the features computed for one entity type.
+type featureGroup struct {
 	features []Feature
+	derived  []derivedFeature // in evaluation order
+	buckets  *bucketIndex     // nil unless Config.BucketSize is set
 }
 
 func New(cfg Config) (*Store, error) {
-	if len(cfg.Features) == 0 {
+	if err := validateEntityTypes(cfg.Entities); err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		storage:    cfg.Storage,
+		entities:   cfg.Entities,
+		derived:    cfg.Derived,
+		bucketSize: cfg.BucketSize,
+	}
+	fs, err := s.newFeatureSet(cfg.Features)
+	if err != nil {
+		return nil, err
+	}
+	s.features.Store(fs)
+
+	if s.storage == nil {
+		s.storage = NewMemoryStorage(cfg.TTL)
+	}
+	if ts, ok := s.storage.(TTLStorage); ok {
+		s.ttl = ts.TTL()
+	}
+	s.backend = s.storage
+	if cfg.Metrics != nil {
+		if err := cfg.Metrics.attach(s); err != nil {
+			return nil, err
+		}
+		s.obs = MultiObserver(cfg.Metrics, cfg.Observer)
+	} else {
+		s.obs = cfg.Observer
+	}
+	if s.obs != nil {
+		s.backend = observedStorage{Storage: s.storage, obs: s.obs}
+	}
+	return s, nil
+}
+
+// newFeatureSet validates features and groups them by entity type.
+func (s *Store) newFeatureSet(features []Feature) (*featureSet, error) {
+	if len(features) == 0 {
 		return nil, errors.New("gofeat: at least one feature required")
 	}
-	for i, f := range cfg.Features {
+
+	features = slices.Clone(features)
+	byEntity := map[string][]Feature{"": nil}
+	for _, et := range s.entities {
+		byEntity[et.Name] = nil
+	}
+	for i, f := range features {
 		if f.Name == "" {
 			return nil, errors.New("gofeat: feature name required")
 		}
@@ -24,54 +94,162 @@ func New(cfg Config) (*Store, error) {
 			return nil, errors.New("gofeat: feature aggregate required")
 		}
 		if f.Window == nil {
-			cfg.Features[i].Window = Lifetime()
+			features[i].Window = Lifetime()
+		}
+		if _, ok := byEntity[f.Entity]; !ok {
+			return nil, fmt.Errorf("gofeat: feature %q: unknown entity type %q", f.Name, f.Entity)
+		}
+		byEntity[f.Entity] = append(byEntity[f.Entity], features[i])
+	}
+
+	derivedByEntity := make(map[string][]DerivedFeature)
+	for _, d := range s.derived {
+		if _, ok := byEntity[d.Entity]; !ok {
+			return nil, fmt.Errorf("gofeat: derived feature %q: unknown entity type %q", d.Name, d.Entity)
 		}
+		derivedByEntity[d.Entity] = append(derivedByEntity[d.Entity], d)
 	}
 
-	storage := cfg.Storage
-	if storage == nil {
-		storage = NewMemoryStorage(cfg.TTL)
+	groups := make(map[string]*featureGroup, len(byEntity))
+	for entity, group := range byEntity {
+		derived, err := compileDerived(group, derivedByEntity[entity])
+		if err != nil {
+			return nil, err
+		}
+		g := &featureGroup{features: group, derived: derived}
+		if s.bucketSize > 0 {
+			g.buckets = newBucketIndex(s.bucketSize, group)
+		}
+		groups[entity] = g
 	}
 
-	return &Store{
-		storage:  storage,
-		features: cfg.Features,
-	}, nil
+	return &featureSet{features: features, groups: groups}, nil
 }
 
 func (s *Store) Push(ctx context.Context, entityID string, events ...Event) error {
+	if s.obs == nil {
+		return s.push(ctx, entityID, events)
+	}
+	op := PushOp{EntityID: entityID, Events: len(events)}
+	ctx = s.obs.PushStart(ctx, op)
+	start := time.Now()
+	err := s.push(ctx, entityID, events)
+	s.obs.PushEnd(ctx, op, OpResult{Duration: time.Since(start), Err: err})
+	return err
+}
+
+func (s *Store) push(ctx context.Context, entityID string, events []Event) error {
 	for i, e := range events {
 		if err := s.validateEvent(e); err != nil {
-			return fmt.Errorf("invalid event %d: %w", i, err)
+			return fmt.Errorf("%w %d: %w", ErrInvalidEvent, i, err)
 		}
 	}
 
-	return s.storage.Push(ctx, entityID, events...)
+	s.writeMu.RLock()
+	defer s.writeMu.RUnlock()
+
+	g := s.features.Load().groups[""]
+	if g.buckets == nil {
+		return s.backend.Push(ctx, entityID, events...)
+	}
+
+	eb, err := g.buckets.load(ctx, s.backend, entityID)
+	if err != nil {
+		return err
+	}
+	if err := s.backend.Push(ctx, entityID, events...); err != nil {
+		return err
+	}
+	g.buckets.add(eb, events)
+	return nil
 }
 
 func (s *Store) Get(ctx context.Context, entityID string) (Result, error) {
 	return s.GetAt(ctx, entityID, time.Now().UTC())
 }
 
+// GetAt computes the features without an entity type for entityID.
 func (s *Store) GetAt(ctx context.Context, entityID string, at time.Time) (Result, error) {
-	events, err := s.storage.Get(ctx, entityID, at)
+	return s.compute(ctx, s.features.Load().groups[""], "", entityID, at)
+}
+
+// compute evaluates the features of g for an entity of entityType.
+func (s *Store) compute(ctx context.Context, g *featureGroup, entityType, id string, at time.Time) (Result, error) {
+	if s.obs == nil {
+		return s.computeFeatures(ctx, g, entityType, id, at)
+	}
+	op := GetOp{EntityType: entityType, EntityID: id, At: at}
+	ctx = s.obs.GetStart(ctx, op)
+	start := time.Now()
+	result, err := s.computeFeatures(ctx, g, entityType, id, at)
+	s.obs.GetEnd(ctx, op, OpResult{Duration: time.Since(start), Err: err})
+	return result, err
+}
+
+func (s *Store) computeFeatures(ctx context.Context, g *featureGroup, entityType, id string, at time.Time) (Result, error) {
+	key := id
+	if entityType != "" {
+		key = entityKey(entityType, id)
+	}
+	events, err := s.backend.Get(ctx, key, at)
 	if err != nil {
 		return Result{}, err
 	}
 
-	values := make(map[string]any, len(s.features))
-	for _, f := range s.features {
-		selected := f.Window.Select(events, at)
-		agg := f.Aggregate()
-		for _, e := range selected {
-			agg.Add(e)
+	var eb *entityBuckets
+	if g.buckets != nil && len(events) > 0 {
+		if eb, err = g.buckets.load(ctx, s.backend, key); err != nil {
+			return Result{}, err
 		}
-		values[f.Name] = agg.Result()
+	}
+
+	values := make(map[string]any, len(g.features)+len(g.derived))
+	for i, f := range g.features {
+		var start time.Time
+		if s.obs != nil {
+			start = time.Now()
+		}
+		v, err := g.aggregate(i, eb, events, at)
+		if err != nil {
+			return Result{}, err
+		}
+		values[f.Name] = v
+		if s.obs != nil {
+			s.obs.FeatureComputed(ctx, FeatureComputation{
+				Feature: f.Name, EntityType: entityType, EntityID: id, Duration: time.Since(start),
+			})
+		}
+	}
+	if err := evalDerived(g.derived, values); err != nil {
+		return Result{}, err
 	}
 
 	return newResult(values), nil
 }
 
+// aggregate computes feature i of g over events, from buckets if eb is set
+// and the feature is bucketed.
+func (g *featureGroup) aggregate(i int, eb *entityBuckets, events []Event, at time.Time) (any, error) {
+	if eb != nil && g.buckets.slots[i] >= 0 {
+		agg, err := g.buckets.aggregate(eb, i, events, at)
+		if err != nil {
+			return nil, err
+		}
+		return resultAt(agg, at), nil
+	}
+
+	f := g.features[i]
+	input := events
+	if f.Filter != nil {
+		input = filterEvents(events, f.Filter)
+	}
+	agg := f.Aggregate()
+	for _, e := range f.Window.Select(input, at) {
+		agg.Add(e)
+	}
+	return resultAt(agg, at), nil
+}
+
 func (s *Store) BatchGet(ctx context.Context, entityIDs ...string) (map[string]Result, error) {
 	return s.BatchGetAt(ctx, time.Now().UTC(), entityIDs...)
 }
@@ -90,20 +268,102 @@ func (s *Store) BatchGetAt(ctx context.Context, at time.Time, entityIDs ...strin
 }
 
 func (s *Store) Evict(ctx context.Context) error {
-	return s.storage.Evict(ctx)
+	if s.obs == nil {
+		_, err := s.evict(ctx)
+		return err
+	}
+	start := time.Now()
+	n, err := s.evict(ctx)
+	s.obs.Evicted(ctx, Eviction{Events: n, Duration: time.Since(start), Err: err})
+	return err
+}
+
+// evict evicts expired events and bucket state and returns the number of
+// events the storage removed.
+func (s *Store) evict(ctx context.Context) (int64, error) {
+	n, err := s.evictStorage(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if s.ttl > 0 {
+		cutoff := time.Now().UTC().Add(-s.ttl)
+		for _, g := range s.features.Load().groups {
+			if g.buckets != nil {
+				g.buckets.prune(cutoff)
+			}
+		}
+	}
+	return n, nil
+}
+
+func (s *Store) evictStorage(ctx context.Context) (int64, error) {
+	if _, ok := s.storage.(EvictCounter); ok {
+		ec, _ := s.backend.(EvictCounter)
+		return ec.EvictCount(ctx)
+	}
+	if s.obs == nil {
+		return 0, s.backend.Evict(ctx)
+	}
+
+	// Other storages do not report the count, so it is the difference of
+	// the event counts, which is approximate under concurrent pushes
+	before, statsErr := s.storage.Stats(ctx)
+	if err := s.backend.Evict(ctx); err != nil {
+		return 0, err
+	}
+	if statsErr != nil {
+		return 0, nil
+	}
+	after, err := s.storage.Stats(ctx)
+	if err != nil {
+		return 0, nil
+	}
+	return max(before.TotalEvents-after.TotalEvents, 0), nil
 }
 
 func (s *Store) Stats(ctx context.Context) (StorageStats, error) {
-	return s.storage.Stats(ctx)
+	return s.backend.Stats(ctx)
 }
 
 func (s *Store) Close() error {
 	return s.storage.Close()
 }
 
+// ErrInvalidEvent is returned by Push and Ingest for events that cannot be
+// stored, e.g. with a timestamp not in UTC.
+var ErrInvalidEvent = errors.New("gofeat: invalid event")
+
 func (s *Store) validateEvent(e Event) error {
 	if e.Timestamp.Location() != time.UTC {
 		return errors.New("timestamp must be in UTC")
 	}
 	return nil
 }
+
+// Snapshot writes all stored events to w.
+// Returns ErrSnapshotUnsupported if the storage does not implement Snapshotter.
+func (s *Store) Snapshot(ctx context.Context, w io.Writer) error {
+	sn, ok := s.storage.(Snapshotter)
+	if !ok {
+		return ErrSnapshotUnsupported
+	}
+	return sn.Snapshot(ctx, w)
+}
+
+// Restore replaces all stored events with a snapshot written by Snapshot.
+// Returns ErrSnapshotUnsupported if the storage does not implement Snapshotter.
+func (s *Store) Restore(ctx context.Context, r io.Reader) error {
+	sn, ok := s.storage.(Snapshotter)
+	if !ok {
+		return ErrSnapshotUnsupported
+	}
+	if err := sn.Restore(ctx, r); err != nil {
+		return err
+	}
+	for _, g := range s.features.Load().groups {
+		if g.buckets != nil {
+			g.buckets.reset()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package gofeat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// LabelRow is a labelled example of a training set: the entity, the time
+// the label refers to, the label and extra columns copied to the output
+// (e.g. a transaction ID).
+type LabelRow struct {
+	EntityID  string
+	Timestamp time.Time
+	Label     any
+	Extra     map[string]any
+}
+
+// TrainingSetOptions configure Store.BuildTrainingSet.
+type TrainingSetOptions struct {
+	// EntityType selects the features of an entity type declared in
+	// Config.Entities. Empty means the features used by Push and Get.
+	EntityType string
+
+	// Inclusive computes features at the label timestamp, so events at
+	// exactly that time are visible. By default features are computed 1ns
+	// before it: an event should not see itself when it is labelled.
+	Inclusive bool
+
+	// Features selects and orders the feature columns. Empty means all
+	// features, including derived ones, sorted by name.
+	Features []string
+
+	// Extra selects and orders the extra columns. Empty means the keys of
+	// the first row's Extra sorted by name. Rows missing a column get nil,
+	// keys of other rows that are not columns are an error.
+	Extra []string
+}
+
+// Training set columns that are not features.
+const (
+	ColumnEntityID  = "entity_id"
+	ColumnTimestamp = "timestamp"
+	ColumnLabel     = "label"
+)
+
+// RowWriter receives the rows of a training set. WriteHeader is called once
+// before the first row and Flush once after the last one.
+type RowWriter interface {
+	WriteHeader(columns []string) error
+	WriteRow(values []any) error
+	Flush() error
+}
+
+// BuildTrainingSet computes point-in-time features for every label and
+// writes one row per label to w. Columns are entity_id, timestamp, label,
+// the extra columns and the feature columns, in that order.
+//
+// Each row only sees events stored at its timestamp (see
+// TrainingSetOptions.Inclusive), so features do not leak information from
+// after the label. Labels may be in any order; rows are written as labels
+// are read.
+func (s *Store) BuildTrainingSet(ctx context.Context, labels iter.Seq[LabelRow], w RowWriter, opts TrainingSetOptions) error {
+	g, ok := s.features.Load().groups[opts.EntityType]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownEntityType, opts.EntityType)
+	}
+	features, err := trainingFeatures(g, opts.Features)
+	if err != nil {
+		return err
+	}
+
+	var extra []string
+	written := false
+	for row := range labels {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !written {
+			if extra, err = trainingExtra(row, opts.Extra, features); err != nil {
+				return err
+			}
+			if err := w.WriteHeader(trainingColumns(extra, features)); err != nil {
+				return err
+			}
+			written = true
+		}
+
+		values, err := s.trainingRow(ctx, g, row, extra, features, opts)
+		if err != nil {
+			return err
+		}
+		if err := w.WriteRow(values); err != nil {
+			return err
+		}
+	}
+
+	if !written {
+		extra = opts.Extra
+		if err := w.WriteHeader(trainingColumns(extra, features)); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func (s *Store) trainingRow(ctx context.Context, g *featureGroup, row LabelRow, extra, features []string, opts TrainingSetOptions) ([]any, error) {
+	for k := range row.Extra {
+		if !slices.Contains(extra, k) {
+			return nil, fmt.Errorf("gofeat: label for %q at %s: unexpected extra column %q",
+				row.EntityID, row.Timestamp.Format(time.RFC3339Nano), k)
+		}
+	}
+
+	at := row.Timestamp
+	if !opts.Inclusive {
+		at = at.Add(-time.Nanosecond)
+	}
+	result, err := s.compute(ctx, g, opts.EntityType, row.EntityID, at)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]any, 0, 3+len(extra)+len(features))
+	values = append(values, row.EntityID, row.Timestamp, row.Label)
+	for _, name := range extra {
+		values = append(values, row.Extra[name])
+	}
+	for _, name := range features {
+		values = append(values, result.values[name])
+	}
+	return values, nil
+}
+
+// trainingFeatures returns the feature columns of g.
+func trainingFeatures(g *featureGroup, selected []string) ([]string, error) {
+	all := make([]string, 0, len(g.features)+len(g.derived))
+	for _, f := range g.features {
+		all = append(all, f.Name)
+	}
+	for _, d := range g.derived {
+		all = append(all, d.name)
+	}
+	if len(selected) == 0 {
+		sort.Strings(all)
+		return all, nil
+	}
+
+	for i, name := range selected {
+		if !slices.Contains(all, name) {
+			return nil, fmt.Errorf("gofeat: unknown feature %q", name)
+		}
+		if slices.Contains(selected[:i], name) {
+			return nil, fmt.Errorf("gofeat: duplicate feature column %q", name)
+		}
+	}
+	return slices.Clone(selected), nil
+}
+
+// trainingExtra returns the extra columns and checks that no column name is
+// used twice.
+func trainingExtra(first LabelRow, selected, features []string) ([]string, error) {
+	extra := slices.Clone(selected)
+	if len(extra) == 0 {
+		for k := range first.Extra {
+			extra = append(extra, k)
+		}
+		sort.Strings(extra)
+	}
+
+	seen := map[string]bool{ColumnEntityID: true, ColumnTimestamp: true, ColumnLabel: true}
+	for _, name := range slices.Concat(extra, features) {
+		if seen[name] {
+			return nil, fmt.Errorf("gofeat: duplicate training set column %q", name)
+		}
+		seen[name] = true
+	}
+	return extra, nil
+}
+
+func trainingColumns(extra, features []string) []string {
+	return slices.Concat([]string{ColumnEntityID, ColumnTimestamp, ColumnLabel}, extra, features)
+}
+
+// NewCSVRowWriter returns a RowWriter that writes CSV with a header row.
+// Times are formatted as RFC 3339, durations as seconds, multi-valued
+// results such as percentiles, top-K values and histograms as JSON and nil
+// values as empty cells.
+func NewCSVRowWriter(w io.Writer) RowWriter {
+	return &csvRowWriter{w: csv.NewWriter(w)}
+}
+
+type csvRowWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (c *csvRowWriter) WriteHeader(columns []string) error {
+	return c.w.Write(columns)
+}
+
+func (c *csvRowWriter) WriteRow(values []any) error {
+	c.record = c.record[:0]
+	for _, v := range values {
+		c.record = append(c.record, formatCSV(v))
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvRowWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func formatCSV(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(t), 'g', -1, 32)
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	case time.Duration:
+		return strconv.FormatFloat(t.Seconds(), 'g', -1, 64)
+	case []float64, []int, []ValueCount:
+		if b, err := json.Marshal(v); err == nil {
+			return string(b)
+		}
+		return fmt.Sprint(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// NewJSONLRowWriter returns a RowWriter that writes one JSON object per row
+// with keys in column order. Times are formatted as RFC 3339, durations as
+// seconds and NaN or infinite floats as null.
+func NewJSONLRowWriter(w io.Writer) RowWriter {
+	return &jsonlRowWriter{w: bufio.NewWriter(w)}
+}
+
+type jsonlRowWriter struct {
+	w       *bufio.Writer
+	columns [][]byte     // JSON-encoded column names
+	row     bytes.Buffer // current row, written to w only once fully encoded
+}
+
+func (j *jsonlRowWriter) WriteHeader(columns []string) error {
+	j.columns = make([][]byte, len(columns))
+	for i, name := range columns {
+		b, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		j.columns[i] = b
+	}
+	return nil
+}
+
+func (j *jsonlRowWriter) WriteRow(values []any) error {
+	if len(values) != len(j.columns) {
+		return fmt.Errorf("gofeat: row has %d values, want %d", len(values), len(j.columns))
+	}
+	j.row.Reset()
+	j.row.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			j.row.WriteByte(',')
+		}
+		j.row.Write(j.columns[i])
+		j.row.WriteByte(':')
+		b, err := json.Marshal(jsonValue(v))
+		if err != nil {
+			return fmt.Errorf("gofeat: column %s: %w", j.columns[i], err)
+		}
+		j.row.Write(b)
+	}
+	j.row.WriteString("}\n")
+	_, err := j.w.Write(j.row.Bytes())
+	return err
+}
+
+func (j *jsonlRowWriter) Flush() error {
+	return j.w.Flush()
+}
+
+func jsonValue(v any) any {
+	switch t := v.(type) {
+	case time.Duration:
+		return t.Seconds()
+	case float64:
+		if math.IsNaN(t) || math.IsInf(t, 0) {
+			return nil
+		}
+	case float32:
+		if math.IsNaN(float64(t)) || math.IsInf(float64(t), 0) {
+			return nil
+		}
+	}
+	return v
+}
@@ -0,0 +1,220 @@
+package gofeat_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"iter"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w0rng/gofeat"
+)
+
+func newTrainingStore(t *testing.T) *gofeat.Store {
+	t.Helper()
+	store, err := gofeat.New(gofeat.Config{
+		Entities: []gofeat.EntityType{{Name: "card", Field: "card"}},
+		Features: []gofeat.Feature{
+			{Name: "tx_count_1h", Aggregate: gofeat.Count, Window: gofeat.Sliding(time.Hour)},
+			{Name: "tx_sum_1h", Aggregate: gofeat.Sum("amount"), Window: gofeat.Sliding(time.Hour)},
+			{Name: "card_count", Aggregate: gofeat.Count, Entity: "card"},
+		},
+		Derived: []gofeat.DerivedFeature{{Name: "avg_1h", Expr: "tx_sum_1h / tx_count_1h"}},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i, amount := range []float64{50, 75, 500} {
+		e := gofeat.Event{
+			Timestamp: base.Add(time.Duration(i) * 10 * time.Minute),
+			Data:      map[string]any{"amount": amount, "card": "4111"},
+		}
+		if err := store.Push(ctx, "u1", e); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+		if err := store.Ingest(ctx, e); err != nil {
+			t.Fatalf("Ingest failed: %v", err)
+		}
+	}
+	return store
+}
+
+func labelRows(rows ...gofeat.LabelRow) iter.Seq[gofeat.LabelRow] {
+	return slices.Values(rows)
+}
+
+func TestBuildTrainingSet_CSV(t *testing.T) {
+	store := newTrainingStore(t)
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		opts gofeat.TrainingSetOptions
+		want string
+	}{
+		{
+			name: "exclusive",
+			want: "entity_id,timestamp,label,tx_id,avg_1h,tx_count_1h,tx_sum_1h\n" +
+				"u1,2024-01-01T10:00:00Z,0,t1,NaN,0,0\n" +
+				"u1,2024-01-01T10:10:00Z,0,t2,50,1,50\n" +
+				"u1,2024-01-01T10:20:00Z,1,t3,62.5,2,125\n",
+		},
+		{
+			name: "inclusive",
+			opts: gofeat.TrainingSetOptions{Inclusive: true, Features: []string{"tx_count_1h"}},
+			want: "entity_id,timestamp,label,tx_id,tx_count_1h\n" +
+				"u1,2024-01-01T10:00:00Z,0,t1,1\n" +
+				"u1,2024-01-01T10:10:00Z,0,t2,2\n" +
+				"u1,2024-01-01T10:20:00Z,1,t3,3\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := labelRows(
+				gofeat.LabelRow{EntityID: "u1", Timestamp: base, Label: 0, Extra: map[string]any{"tx_id": "t1"}},
+				gofeat.LabelRow{EntityID: "u1", Timestamp: base.Add(10 * time.Minute), Label: 0, Extra: map[string]any{"tx_id": "t2"}},
+				gofeat.LabelRow{EntityID: "u1", Timestamp: base.Add(20 * time.Minute), Label: 1, Extra: map[string]any{"tx_id": "t3"}},
+			)
+			var buf bytes.Buffer
+			if err := store.BuildTrainingSet(context.Background(), labels, gofeat.NewCSVRowWriter(&buf), tt.opts); err != nil {
+				t.Fatalf("BuildTrainingSet failed: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildTrainingSet_JSONL(t *testing.T) {
+	store := newTrainingStore(t)
+	at := time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)
+
+	labels := labelRows(
+		gofeat.LabelRow{EntityID: "4111", Timestamp: at, Label: true, Extra: map[string]any{"tx_id": "t9", "amount": 20.0}},
+		gofeat.LabelRow{EntityID: "5500", Timestamp: at, Label: false, Extra: map[string]any{"tx_id": "t10"}},
+	)
+	var buf bytes.Buffer
+	opts := gofeat.TrainingSetOptions{EntityType: "card"}
+	if err := store.BuildTrainingSet(context.Background(), labels, gofeat.NewJSONLRowWriter(&buf), opts); err != nil {
+		t.Fatalf("BuildTrainingSet failed: %v", err)
+	}
+
+	want := `{"entity_id":"4111","timestamp":"2024-01-01T10:30:00Z","label":true,"amount":20,"tx_id":"t9","card_count":3}` + "\n" +
+		`{"entity_id":"5500","timestamp":"2024-01-01T10:30:00Z","label":false,"amount":null,"tx_id":"t10","card_count":0}` + "\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestBuildTrainingSet_NoLabels(t *testing.T) {
+	store := newTrainingStore(t)
+
+	var buf bytes.Buffer
+	opts := gofeat.TrainingSetOptions{Extra: []string{"tx_id"}, Features: []string{"tx_sum_1h"}}
+	if err := store.BuildTrainingSet(context.Background(), labelRows(), gofeat.NewCSVRowWriter(&buf), opts); err != nil {
+		t.Fatalf("BuildTrainingSet failed: %v", err)
+	}
+	if want := "entity_id,timestamp,label,tx_id,tx_sum_1h\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestBuildTrainingSet_Errors(t *testing.T) {
+	store := newTrainingStore(t)
+	at := time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC)
+	row := gofeat.LabelRow{EntityID: "u1", Timestamp: at, Extra: map[string]any{"tx_id": "t1"}}
+
+	tests := []struct {
+		name   string
+		labels []gofeat.LabelRow
+		opts   gofeat.TrainingSetOptions
+		want   string
+	}{
+		{name: "unknown entity type", opts: gofeat.TrainingSetOptions{EntityType: "device"}, want: "unknown entity type"},
+		{name: "unknown feature", opts: gofeat.TrainingSetOptions{Features: []string{"nope"}}, want: `unknown feature "nope"`},
+		{
+			name: "duplicate feature",
+			opts: gofeat.TrainingSetOptions{Features: []string{"tx_count_1h", "tx_count_1h"}},
+			want: "duplicate feature column",
+		},
+		{
+			name:   "extra clashes with column",
+			labels: []gofeat.LabelRow{{EntityID: "u1", Timestamp: at, Extra: map[string]any{"label": 1}}},
+			want:   `duplicate training set column "label"`,
+		},
+		{
+			name: "unexpected extra column",
+			labels: []gofeat.LabelRow{
+				row,
+				{EntityID: "u1", Timestamp: at, Extra: map[string]any{"merchant": "m1"}},
+			},
+			want: `unexpected extra column "merchant"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := store.BuildTrainingSet(context.Background(), labelRows(tt.labels...), gofeat.NewCSVRowWriter(&buf), tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := store.BuildTrainingSet(ctx, labelRows(row), gofeat.NewCSVRowWriter(&bytes.Buffer{}), gofeat.TrainingSetOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled context: got %v", err)
+	}
+}
+
+func TestCSVRowWriter_MultiValued(t *testing.T) {
+	var buf bytes.Buffer
+	w := gofeat.NewCSVRowWriter(&buf)
+	if err := w.WriteHeader([]string{"pcts", "hist", "top"}); err != nil {
+		t.Fatalf("WriteHeader failed: %v", err)
+	}
+	row := []any{[]float64{1.5, 9}, []int{0, 2, 1}, []gofeat.ValueCount{{Value: "US", Count: 2}}}
+	if err := w.WriteRow(row); err != nil {
+		t.Fatalf("WriteRow failed: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	want := "pcts,hist,top\n" + `"[1.5,9]","[0,2,1]","[{""value"":""US"",""count"":2}]"` + "\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestJSONLRowWriter_FailedRowNotWritten(t *testing.T) {
+	var buf bytes.Buffer
+	w := gofeat.NewJSONLRowWriter(&buf)
+	if err := w.WriteHeader([]string{"a", "b"}); err != nil {
+		t.Fatalf("WriteHeader failed: %v", err)
+	}
+	if err := w.WriteRow([]any{1, complex(1, 2)}); err == nil {
+		t.Fatal("WriteRow with unencodable value: expected error")
+	}
+	if err := w.WriteRow([]any{2, "ok"}); err != nil {
+		t.Fatalf("WriteRow failed: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if want := `{"a":2,"b":"ok"}` + "\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
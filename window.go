@@ -2,6 +2,7 @@ package gofeat
 
 import (
 	"sort"
+	"sync"
 	"time"
 )
 
@@ -43,3 +44,270 @@ func (w *lifetimeWindow) Select(events []Event, t time.Time) []Event {
 	})
 	return events[:idx]
 }
+
+type betweenWindow struct {
+	from time.Duration
+	to   time.Duration
+}
+
+// Between returns a window that selects events between from and to ago,
+// i.e. with timestamps in (t-from, t-to]. Use it for baselines such as
+// Between(30*24*time.Hour, 24*time.Hour) to compare against recent activity.
+// The arguments are swapped if from is less than to.
+func Between(from, to time.Duration) Window {
+	if from < to {
+		from, to = to, from
+	}
+	return &betweenWindow{from: from, to: to}
+}
+
+func (w *betweenWindow) Select(events []Event, t time.Time) []Event {
+	lo := sort.Search(len(events), func(i int) bool {
+		return events[i].Timestamp.After(t.Add(-w.from))
+	})
+	hi := sort.Search(len(events), func(i int) bool {
+		return events[i].Timestamp.After(t.Add(-w.to))
+	})
+	if lo >= hi {
+		return nil
+	}
+	return events[lo:hi]
+}
+
+type lastNWindow struct {
+	n        int
+	duration time.Duration // 0 means unbounded
+}
+
+// LastN returns a window that selects the n most recent events at or before t.
+func LastN(n int) Window {
+	return &lastNWindow{n: n}
+}
+
+// LastNWithin returns a window that selects at most n of the most recent
+// events at or before t that are also within the last duration d.
+func LastNWithin(n int, d time.Duration) Window {
+	return &lastNWindow{n: n, duration: d}
+}
+
+func (w *lastNWindow) Select(events []Event, t time.Time) []Event {
+	if w.n <= 0 {
+		return nil
+	}
+	end := sort.Search(len(events), func(i int) bool {
+		return events[i].Timestamp.After(t)
+	})
+	start := max(end-w.n, 0)
+	if w.duration > 0 {
+		cutoff := t.Add(-w.duration)
+		start += sort.Search(end-start, func(i int) bool {
+			return !events[start+i].Timestamp.Before(cutoff)
+		})
+	}
+	if start >= end {
+		return nil
+	}
+	return events[start:end]
+}
+
+type sessionWindow struct {
+	gap time.Duration
+}
+
+// Session returns a window that selects the current session at t: the
+// contiguous run of events ending with the latest event at or before t in
+// which consecutive timestamps are at most gap apart. The session ends,
+// and the window is empty, once more than gap has passed since that event.
+func Session(gap time.Duration) Window {
+	return &sessionWindow{gap: gap}
+}
+
+func (w *sessionWindow) Select(events []Event, t time.Time) []Event {
+	end := sort.Search(len(events), func(i int) bool {
+		return events[i].Timestamp.After(t)
+	})
+	if end == 0 || t.Sub(events[end-1].Timestamp) > w.gap {
+		return nil
+	}
+	start := end - 1
+	for start > 0 && events[start].Timestamp.Sub(events[start-1].Timestamp) <= w.gap {
+		start--
+	}
+	return events[start:end]
+}
+
+// HoppingOption configures optional parameters of Tumbling and Hopping
+// windows.
+type HoppingOption func(*hoppingWindow)
+
+// WithOrigin aligns bucket boundaries to origin instead of the Unix epoch.
+// For example, Tumbling(24*time.Hour, WithOrigin(time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)))
+// produces days starting at 06:00 UTC.
+func WithOrigin(origin time.Time) HoppingOption {
+	return func(w *hoppingWindow) {
+		w.origin = origin
+	}
+}
+
+// CalendarOption configures optional parameters of calendar windows.
+type CalendarOption func(*calendarWindow)
+
+// WithLocationField makes calendar windows use the time zone stored in the
+// given event field of the latest event at or before the query time. The
+// field holds an IANA name (e.g. "Europe/Berlin") or a *time.Location.
+// The window's own location is used when the field is missing or invalid.
+func WithLocationField(field string) CalendarOption {
+	return func(w *calendarWindow) {
+		w.locationField = field
+	}
+}
+
+type hoppingWindow struct {
+	size   time.Duration
+	hop    time.Duration
+	origin time.Time
+}
+
+// Tumbling returns a window over fixed, non-overlapping buckets of the given
+// size. It selects the events of the bucket containing t, up to t.
+func Tumbling(size time.Duration, opts ...HoppingOption) Window {
+	return Hopping(size, size, opts...)
+}
+
+// Hopping returns a window over buckets of the given size that start every
+// hop. Several buckets contain t; the window selects the events of the
+// oldest one, i.e. the bucket starting at the first hop boundary after
+// t-size, up to t. A hop that is not positive or larger than size is
+// treated as size. A size that is not positive is treated as one
+// nanosecond, so that only events at t are selected.
+func Hopping(size, hop time.Duration, opts ...HoppingOption) Window {
+	size = max(size, time.Nanosecond)
+	if hop <= 0 || hop > size {
+		hop = size
+	}
+	w := &hoppingWindow{size: size, hop: hop, origin: time.Unix(0, 0).UTC()}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+func (w *hoppingWindow) Select(events []Event, t time.Time) []Event {
+	return selectRange(events, w.start(t), t)
+}
+
+// start returns the first hop boundary strictly after t-size.
+func (w *hoppingWindow) start(t time.Time) time.Time {
+	offset := int64(t.Add(-w.size).Sub(w.origin))
+	hops := floorDiv(offset, int64(w.hop)) + 1
+	return w.origin.Add(time.Duration(hops * int64(w.hop)))
+}
+
+// selectRange returns the sorted events with from <= timestamp <= to.
+func selectRange(events []Event, from, to time.Time) []Event {
+	lo := sort.Search(len(events), func(i int) bool {
+		return !events[i].Timestamp.Before(from)
+	})
+	hi := sort.Search(len(events), func(i int) bool {
+		return events[i].Timestamp.After(to)
+	})
+	if lo >= hi {
+		return nil
+	}
+	return events[lo:hi]
+}
+
+type calendarUnit int
+
+const (
+	calendarDay calendarUnit = iota
+	calendarWeek
+	calendarMonth
+)
+
+type calendarWindow struct {
+	unit          calendarUnit
+	loc           *time.Location
+	weekStart     time.Weekday
+	locationField string
+	locations     sync.Map // string -> *time.Location
+}
+
+// CalendarDay returns a window that selects events since local midnight of
+// the day containing t, in loc (UTC if nil). Events stay in UTC; only the
+// boundaries follow the local calendar, including DST transitions.
+func CalendarDay(loc *time.Location, opts ...CalendarOption) Window {
+	return newCalendarWindow(calendarDay, loc, time.Sunday, opts)
+}
+
+// CalendarWeek returns a window that selects events since local midnight of
+// the most recent startDay, in loc (UTC if nil).
+func CalendarWeek(loc *time.Location, startDay time.Weekday, opts ...CalendarOption) Window {
+	return newCalendarWindow(calendarWeek, loc, startDay, opts)
+}
+
+// CalendarMonth returns a window that selects events since local midnight of
+// the first day of the month containing t, in loc (UTC if nil).
+func CalendarMonth(loc *time.Location, opts ...CalendarOption) Window {
+	return newCalendarWindow(calendarMonth, loc, time.Sunday, opts)
+}
+
+func newCalendarWindow(unit calendarUnit, loc *time.Location, weekStart time.Weekday, opts []CalendarOption) *calendarWindow {
+	if loc == nil {
+		loc = time.UTC
+	}
+	w := &calendarWindow{unit: unit, loc: loc, weekStart: weekStart}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+func (w *calendarWindow) Select(events []Event, t time.Time) []Event {
+	return selectRange(events, w.start(t.In(w.location(events, t))), t)
+}
+
+// start returns the beginning of the calendar period containing local.
+func (w *calendarWindow) start(local time.Time) time.Time {
+	y, m, d := local.Date()
+	switch w.unit {
+	case calendarWeek:
+		d -= (int(local.Weekday()) - int(w.weekStart) + 7) % 7
+	case calendarMonth:
+		d = 1
+	case calendarDay:
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, local.Location())
+}
+
+func (w *calendarWindow) location(events []Event, t time.Time) *time.Location {
+	if w.locationField == "" {
+		return w.loc
+	}
+	idx := sort.Search(len(events), func(i int) bool {
+		return events[i].Timestamp.After(t)
+	})
+	if idx == 0 {
+		return w.loc
+	}
+
+	switch v := events[idx-1].Data[w.locationField].(type) {
+	case *time.Location:
+		if v != nil {
+			return v
+		}
+	case string:
+		if cached, ok := w.locations.Load(v); ok {
+			if loc, ok := cached.(*time.Location); ok {
+				return loc
+			}
+		}
+		loc, err := time.LoadLocation(v)
+		if err != nil {
+			return w.loc
+		}
+		w.locations.Store(v, loc)
+		return loc
+	}
+	return w.loc
+}
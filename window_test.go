@@ -1,6 +1,7 @@
 package gofeat_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -294,3 +295,352 @@ func TestWindow_Integration(t *testing.T) {
 		t.Errorf("lifetime window: got %d events, want 4", len(selectedLifetime))
 	}
 }
+
+func TestTumblingWindow(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	events := []gofeat.Event{
+		{Timestamp: base.Add(-time.Minute), Data: map[string]any{"id": 1}},
+		{Timestamp: base, Data: map[string]any{"id": 2}},
+		{Timestamp: base.Add(30 * time.Minute), Data: map[string]any{"id": 3}},
+		{Timestamp: base.Add(59 * time.Minute), Data: map[string]any{"id": 4}},
+		{Timestamp: base.Add(time.Hour), Data: map[string]any{"id": 5}},
+	}
+
+	window := gofeat.Tumbling(time.Hour)
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want []int
+	}{
+		{name: "bucket start", at: base, want: []int{2}},
+		{name: "mid bucket", at: base.Add(45 * time.Minute), want: []int{2, 3}},
+		{name: "bucket end", at: base.Add(time.Hour - time.Nanosecond), want: []int{2, 3, 4}},
+		{name: "next bucket", at: base.Add(90 * time.Minute), want: []int{5}},
+		{name: "previous bucket", at: base.Add(-time.Second), want: []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertEventIDs(t, window.Select(events, tt.at), tt.want)
+		})
+	}
+}
+
+func TestTumblingWindow_Origin(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	events := []gofeat.Event{
+		{Timestamp: base.Add(5 * time.Hour), Data: map[string]any{"id": 1}},
+		{Timestamp: base.Add(7 * time.Hour), Data: map[string]any{"id": 2}},
+		{Timestamp: base.Add(20 * time.Hour), Data: map[string]any{"id": 3}},
+	}
+
+	// Business days starting at 06:00
+	window := gofeat.Tumbling(24*time.Hour, gofeat.WithOrigin(base.Add(6*time.Hour)))
+	assertEventIDs(t, window.Select(events, base.Add(21*time.Hour)), []int{2, 3})
+	assertEventIDs(t, window.Select(events, base.Add(5*time.Hour+30*time.Minute)), []int{1})
+}
+
+func TestHoppingWindow(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	events := []gofeat.Event{
+		{Timestamp: base.Add(-10 * time.Minute), Data: map[string]any{"id": 1}},
+		{Timestamp: base.Add(-7 * time.Minute), Data: map[string]any{"id": 2}},
+		{Timestamp: base.Add(-5*time.Minute - 30*time.Second), Data: map[string]any{"id": 3}},
+		{Timestamp: base.Add(-time.Minute), Data: map[string]any{"id": 4}},
+		{Timestamp: base.Add(time.Minute), Data: map[string]any{"id": 5}},
+	}
+
+	// 10-minute buckets hopping every minute. At 12:03:30 the oldest bucket
+	// containing t starts at 11:54, so events from 11:54 to 12:03:30 count.
+	window := gofeat.Hopping(10*time.Minute, time.Minute)
+	assertEventIDs(t, window.Select(events, base.Add(3*time.Minute+30*time.Second)), []int{3, 4, 5})
+
+	// At 11:57 the bucket starts at 11:48
+	assertEventIDs(t, window.Select(events, base.Add(-3*time.Minute)), []int{1, 2, 3})
+}
+
+func TestHoppingWindow_InvalidHop(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []gofeat.Event{
+		{Timestamp: base.Add(-time.Minute), Data: map[string]any{"id": 1}},
+		{Timestamp: base.Add(time.Minute), Data: map[string]any{"id": 2}},
+	}
+
+	// Non-positive hops behave like Tumbling
+	window := gofeat.Hopping(time.Hour, 0)
+	assertEventIDs(t, window.Select(events, base.Add(10*time.Minute)), []int{2})
+}
+
+func TestHoppingWindow_InvalidSize(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []gofeat.Event{
+		{Timestamp: base.Add(-time.Minute), Data: map[string]any{"id": 1}},
+		{Timestamp: base, Data: map[string]any{"id": 2}},
+		{Timestamp: base.Add(time.Minute), Data: map[string]any{"id": 3}},
+	}
+
+	// Non-positive sizes select only the events at t
+	for _, window := range []gofeat.Window{gofeat.Tumbling(0), gofeat.Tumbling(-time.Hour), gofeat.Hopping(-time.Hour, time.Minute)} {
+		assertEventIDs(t, window.Select(events, base), []int{2})
+		assertEventIDs(t, window.Select(events, base.Add(time.Second)), nil)
+	}
+}
+
+func TestTumblingWindow_PointInTime(t *testing.T) {
+	store, err := gofeat.New(gofeat.Config{
+		Features: []gofeat.Feature{
+			{Name: "tx_this_hour", Aggregate: gofeat.Count, Window: gofeat.Tumbling(time.Hour)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, m := range []int{-5, 10, 20, 50, 70} {
+		store.Push(ctx, "user1", gofeat.Event{Timestamp: base.Add(time.Duration(m) * time.Minute)})
+	}
+
+	result, _ := store.GetAt(ctx, "user1", base.Add(30*time.Minute))
+	if got := result.IntOr("tx_this_hour", -1); got != 2 {
+		t.Errorf("count at 12:30: got %d, want 2", got)
+	}
+	result, _ = store.GetAt(ctx, "user1", base.Add(75*time.Minute))
+	if got := result.IntOr("tx_this_hour", -1); got != 1 {
+		t.Errorf("count at 13:15: got %d, want 1", got)
+	}
+}
+
+func assertEventIDs(t *testing.T, events []gofeat.Event, want []int) {
+	t.Helper()
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d", len(want), len(events))
+	}
+	for i, e := range events {
+		if e.Data["id"] != want[i] {
+			t.Errorf("event %d: got id %v, want %d", i, e.Data["id"], want[i])
+		}
+	}
+}
+
+func TestSessionWindow(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	events := []gofeat.Event{
+		{Timestamp: base, Data: map[string]any{"id": 1}},
+		{Timestamp: base.Add(5 * time.Minute), Data: map[string]any{"id": 2}},
+		{Timestamp: base.Add(40 * time.Minute), Data: map[string]any{"id": 3}},
+		{Timestamp: base.Add(50 * time.Minute), Data: map[string]any{"id": 4}},
+		{Timestamp: base.Add(60 * time.Minute), Data: map[string]any{"id": 5}},
+	}
+
+	window := gofeat.Session(10 * time.Minute)
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want []int
+	}{
+		{name: "latest session", at: base.Add(65 * time.Minute), want: []int{3, 4, 5}},
+		{name: "latest session ended", at: base.Add(2 * time.Hour), want: nil},
+		{name: "gap equal to limit continues session", at: base.Add(50 * time.Minute), want: []int{3, 4}},
+		{name: "first session", at: base.Add(15 * time.Minute), want: []int{1, 2}},
+		{name: "first session ended", at: base.Add(30 * time.Minute), want: nil},
+		{name: "single event", at: base.Add(time.Minute), want: []int{1}},
+		{name: "before first event", at: base.Add(-time.Minute), want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertEventIDs(t, window.Select(events, tt.at), tt.want)
+		})
+	}
+}
+
+func TestCalendarDay_DST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+
+	// 2024-03-10: clocks jump from 02:00 to 03:00, local midnight is 05:00 UTC
+	events := []gofeat.Event{
+		{Timestamp: time.Date(2024, 3, 10, 4, 30, 0, 0, time.UTC), Data: map[string]any{"id": 1}},
+		{Timestamp: time.Date(2024, 3, 10, 5, 30, 0, 0, time.UTC), Data: map[string]any{"id": 2}},
+		{Timestamp: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC), Data: map[string]any{"id": 3}},
+		// 2024-03-11 00:30 EDT, the next local day begins at 04:00 UTC
+		{Timestamp: time.Date(2024, 3, 11, 4, 30, 0, 0, time.UTC), Data: map[string]any{"id": 4}},
+	}
+
+	window := gofeat.CalendarDay(ny)
+	assertEventIDs(t, window.Select(events, time.Date(2024, 3, 10, 20, 0, 0, 0, time.UTC)), []int{2, 3})
+	assertEventIDs(t, window.Select(events, time.Date(2024, 3, 11, 4, 45, 0, 0, time.UTC)), []int{4})
+
+	// The same instants form one UTC day
+	assertEventIDs(t, gofeat.CalendarDay(nil).Select(events, time.Date(2024, 3, 10, 23, 0, 0, 0, time.UTC)), []int{1, 2, 3})
+}
+
+func TestCalendarWeek(t *testing.T) {
+	// 2024-01-01 is a Monday
+	events := []gofeat.Event{
+		{Timestamp: time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC), Data: map[string]any{"id": 1}},
+		{Timestamp: time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC), Data: map[string]any{"id": 2}},
+		{Timestamp: time.Date(2024, 1, 3, 1, 0, 0, 0, time.UTC), Data: map[string]any{"id": 3}},
+	}
+	at := time.Date(2024, 1, 4, 12, 0, 0, 0, time.UTC)
+
+	assertEventIDs(t, gofeat.CalendarWeek(time.UTC, time.Monday).Select(events, at), []int{2, 3})
+	assertEventIDs(t, gofeat.CalendarWeek(time.UTC, time.Sunday).Select(events, at), []int{1, 2, 3})
+	// Query on the start day itself
+	assertEventIDs(t, gofeat.CalendarWeek(time.UTC, time.Wednesday).Select(events, at), []int{3})
+}
+
+func TestCalendarMonth(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*3600)
+
+	events := []gofeat.Event{
+		{Timestamp: time.Date(2024, 1, 31, 14, 0, 0, 0, time.UTC), Data: map[string]any{"id": 1}}, // Jan 31 23:00 JST
+		{Timestamp: time.Date(2024, 1, 31, 16, 0, 0, 0, time.UTC), Data: map[string]any{"id": 2}}, // Feb 1 01:00 JST
+		{Timestamp: time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC), Data: map[string]any{"id": 3}},
+	}
+	at := time.Date(2024, 2, 20, 0, 0, 0, 0, time.UTC)
+
+	assertEventIDs(t, gofeat.CalendarMonth(tokyo).Select(events, at), []int{2, 3})
+	assertEventIDs(t, gofeat.CalendarMonth(time.UTC).Select(events, at), []int{3})
+}
+
+func TestCalendarDay_LocationField(t *testing.T) {
+	events := []gofeat.Event{
+		{Timestamp: time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC), Data: map[string]any{"id": 1}},
+		{Timestamp: time.Date(2024, 1, 2, 2, 0, 0, 0, time.UTC), Data: map[string]any{"id": 2, "tz": "Asia/Tokyo"}},
+		{Timestamp: time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC), Data: map[string]any{"id": 3, "tz": "Bad/Zone"}},
+	}
+
+	window := gofeat.CalendarDay(time.UTC, gofeat.WithLocationField("tz"))
+
+	// Tokyo day starts at 2024-01-01 15:00 UTC
+	assertEventIDs(t, window.Select(events, time.Date(2024, 1, 2, 2, 30, 0, 0, time.UTC)), []int{1, 2})
+	// Invalid zone falls back to the window location
+	assertEventIDs(t, window.Select(events, time.Date(2024, 1, 2, 3, 30, 0, 0, time.UTC)), []int{2, 3})
+
+	loc := time.FixedZone("UTC-8", -8*3600)
+	events[2].Data["tz"] = loc
+	assertEventIDs(t, window.Select(events, time.Date(2024, 1, 2, 3, 30, 0, 0, time.UTC)), []int{1, 2, 3})
+}
+
+func TestBetweenWindow(t *testing.T) {
+	now := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	events := []gofeat.Event{
+		{Timestamp: now.Add(-40 * day), Data: map[string]any{"id": 1}},
+		{Timestamp: now.Add(-30 * day), Data: map[string]any{"id": 2}},
+		{Timestamp: now.Add(-10 * day), Data: map[string]any{"id": 3}},
+		{Timestamp: now.Add(-day), Data: map[string]any{"id": 4}},
+		{Timestamp: now.Add(-time.Hour), Data: map[string]any{"id": 5}},
+	}
+
+	// (t-30d, t-1d]: lower bound exclusive, upper bound inclusive
+	assertEventIDs(t, gofeat.Between(30*day, day).Select(events, now), []int{3, 4})
+	// Swapped arguments select the same range
+	assertEventIDs(t, gofeat.Between(day, 30*day).Select(events, now), []int{3, 4})
+	assertEventIDs(t, gofeat.Between(50*day, 45*day).Select(events, now), nil)
+}
+
+func TestBetweenWindow_Baseline(t *testing.T) {
+	features := func() []gofeat.Feature {
+		return []gofeat.Feature{
+			{Name: "count_1h", Aggregate: gofeat.Count, Window: gofeat.Sliding(time.Hour)},
+			{Name: "count_baseline", Aggregate: gofeat.Count, Window: gofeat.Between(30*24*time.Hour, 24*time.Hour)},
+			{Name: "mean_baseline", Aggregate: gofeat.Mean("amount"), Window: gofeat.Between(30*24*time.Hour, 24*time.Hour)},
+		}
+	}
+
+	ctx := context.Background()
+	now := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	for _, bucket := range []time.Duration{0, time.Hour} {
+		store, err := gofeat.New(gofeat.Config{Features: features(), BucketSize: bucket})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		for i := range 60 {
+			store.Push(ctx, "user1", gofeat.Event{
+				Timestamp: now.Add(-time.Duration(i) * 12 * time.Hour),
+				Data:      map[string]any{"amount": float64(i)},
+			})
+		}
+
+		result, _ := store.GetAt(ctx, "user1", now)
+		// Events at 12h steps in (t-30d, t-1d]: i = 2..59, excluding i = 60
+		if got := result.IntOr("count_baseline", -1); got != 58 {
+			t.Errorf("bucket %v: count_baseline got %d, want 58", bucket, got)
+		}
+		if got := result.FloatOr("mean_baseline", -1); got != 30.5 {
+			t.Errorf("bucket %v: mean_baseline got %v, want 30.5", bucket, got)
+		}
+		if got := result.IntOr("count_1h", -1); got != 1 {
+			t.Errorf("bucket %v: count_1h got %d, want 1", bucket, got)
+		}
+	}
+}
+
+func TestLastNWindow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	events := []gofeat.Event{
+		{Timestamp: now.Add(-5 * time.Hour), Data: map[string]any{"id": 1}},
+		{Timestamp: now.Add(-4 * time.Hour), Data: map[string]any{"id": 2}},
+		{Timestamp: now.Add(-3 * time.Hour), Data: map[string]any{"id": 3}},
+		{Timestamp: now.Add(-time.Hour), Data: map[string]any{"id": 4}},
+		{Timestamp: now.Add(time.Hour), Data: map[string]any{"id": 5}},
+	}
+
+	tests := []struct {
+		name   string
+		window gofeat.Window
+		at     time.Time
+		want   []int
+	}{
+		{name: "last 3", window: gofeat.LastN(3), at: now, want: []int{2, 3, 4}},
+		{name: "more than available", window: gofeat.LastN(10), at: now, want: []int{1, 2, 3, 4}},
+		{name: "point in time", window: gofeat.LastN(2), at: now.Add(-3 * time.Hour), want: []int{2, 3}},
+		{name: "zero", window: gofeat.LastN(0), at: now, want: nil},
+		{name: "capped by duration", window: gofeat.LastNWithin(3, 200*time.Minute), at: now, want: []int{3, 4}},
+		{name: "capped by count", window: gofeat.LastNWithin(1, 200*time.Minute), at: now, want: []int{4}},
+		{name: "nothing recent", window: gofeat.LastNWithin(3, time.Minute), at: now, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertEventIDs(t, tt.window.Select(events, tt.at), tt.want)
+		})
+	}
+}
+
+func TestLastNWindow_Mean(t *testing.T) {
+	store, err := gofeat.New(gofeat.Config{
+		Features: []gofeat.Feature{
+			{Name: "mean_last_3", Aggregate: gofeat.Mean("amount"), Window: gofeat.LastN(3)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, amount := range []float64{1000, 10, 20, 30} {
+		store.Push(ctx, "user1", gofeat.Event{
+			Timestamp: base.Add(time.Duration(i) * 24 * time.Hour),
+			Data:      map[string]any{"amount": amount},
+		})
+	}
+
+	result, _ := store.GetAt(ctx, "user1", base.Add(30*24*time.Hour))
+	if got := result.FloatOr("mean_last_3", -1); got != 20 {
+		t.Errorf("mean_last_3: got %v, want 20", got)
+	}
+}